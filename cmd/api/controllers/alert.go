@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -8,13 +9,15 @@ import (
 	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
 	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
 	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
-	"github.com/shadowbane/weather-alert/pkg/application"
 	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
 	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
 // offsetRegex matches UTC offset formats: +08:00, -05:30, +0800, -0530
@@ -45,15 +48,43 @@ func parseTimezone(tz string) string {
 	return "Etc/GMT+" + strconv.Itoa(hours)
 }
 
+// parseLang returns the language of the lang parameter, or the one preferred by the Accept-Language header
+func parseLang(r *http.Request) (string, error) {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if !i18n.Supported(lang) {
+			return "", fmt.Errorf("lang must be one of: %s", strings.Join(i18n.Languages(), ", "))
+		}
+		return lang, nil
+	}
+	return i18n.Negotiate(r.Header.Get("Accept-Language")), nil
+}
+
 // TidalFloodRisk represents the tidal flood risk assessment
 type TidalFloodRisk struct {
-	HasRisk     bool      `json:"has_risk"`
-	RiskLevel   string    `json:"risk_level"`    // "none", "moderate", "high"
-	TideType    string    `json:"tide_type"`     // "high" or "low"
-	TideTime    time.Time `json:"tide_time"`     // When the high tide occurs
-	TideHeightM float64   `json:"tide_height_m"` // Height in meters
-	HeavyRain   bool      `json:"heavy_rain"`    // Whether heavy rain is expected
-	Message     string    `json:"message"`       // Human-readable risk message
+	HasRisk             bool       `json:"has_risk"`
+	RiskLevel           string     `json:"risk_level"`                 // "none", "low", "moderate", "high", "extreme", "unknown"
+	RiskScore           int        `json:"risk_score"`                 // 0-100, the level is the band of the score
+	Location            string     `json:"location"`                   // Tide station used for the assessment
+	Home                string     `json:"home,omitempty"`             // Home the assessment was computed for
+	TideType            string     `json:"tide_type"`                  // "high" or "low"
+	TideTime            time.Time  `json:"tide_time"`                  // When the high tide occurs
+	TideHeightM         float64    `json:"tide_height_m"`              // Astronomical height in meters
+	SurgeM              float64    `json:"surge_m"`                    // Surge offset of the alert event
+	AnomalyM            float64    `json:"anomaly_m"`                  // Reported observed-minus-predicted sea level anomaly
+	AdjustedTideHeightM float64    `json:"adjusted_tide_height_m"`     // Height with the surge and anomaly
+	WaterLevelM         float64    `json:"water_level_m"`              // Highest predicted water level in the alert window, in meters
+	AdjustedWaterLevelM float64    `json:"adjusted_water_level_m"`     // Water level with the surge and anomaly
+	WaterLevelTime      *time.Time `json:"water_level_time,omitempty"` // When the highest water level is predicted
+	HeavyRain           bool       `json:"heavy_rain"`                 // Whether heavy rain is expected
+	RainIntensity       string     `json:"rain_intensity"`             // "none", "light", "moderate", "heavy", "very_heavy" or "extreme"
+	Message             string     `json:"message"`                    // Human-readable risk message
+	Rule                string     `json:"rule"`                       // Name of the risk rule that fired
+	ComputedAt          time.Time  `json:"computed_at"`                // When the assessment was computed
+	ObservedLevelM      *float64   `json:"observed_level_m,omitempty"` // Live water level of the home sensor
+	ObservedAt          *time.Time `json:"observed_at,omitempty"`      // When the live water level was read
+
+	// Evaluation of every risk rule, only in dry-run mode
+	RuleTrace []floodrisk.RuleTrace `json:"rule_trace,omitempty"`
 }
 
 // AlertDetailResponse is the response DTO for alert details
@@ -121,116 +152,208 @@ func toResponse(detail weathermodels.AlertDetail, timezone string, floodRisk *Ti
 	}
 }
 
-// Buffer time to account for rising sea level before high tide peak
-const tideBufferDuration = 2 * time.Hour
-
-// calculateTidalFloodRisk calculates the risk of tidal flooding based on alert and tide data
-// Risk conditions: heavy rain + high tide (>2.5m) where tide_time overlaps with alert period
-// Sea level rises gradually, so we add a buffer after alert expires to catch rising water scenarios
-func calculateTidalFloodRisk(db *gorm.DB, alert weathermodels.AlertDetail, timezone string) *TidalFloodRisk {
-	// Check if alert description contains "heavy rain" or "heavy rainfall"
-	descLower := strings.ToLower(alert.Description)
-	hasHeavyRain := strings.Contains(descLower, "heavy rain")
-
-	if !hasHeavyRain {
-		return &TidalFloodRisk{
-			HasRisk:   false,
-			RiskLevel: "none",
-			HeavyRain: false,
-			Message:   "No heavy rain expected",
-			TideTime:  basetraits.FormatTimeWithTimezone(time.Now().UTC(), timezone),
+// calculateTidalFloodRisks returns the stored flood risk assessments of the alerts, stations[i] being the station
+// of alerts[i], computing the missing ones in a single batch. With a home, the home station and flood threshold
+// are used instead. Dry-run mode always evaluates the rules and includes the per-rule trace. Messages are in lang.
+func calculateTidalFloodRisks(app *application.Application, home *models.Home, stations []config.TideStation, alerts []weathermodels.AlertDetail, timezone, lang string, dryRun bool) []*TidalFloodRisk {
+	rules := app.RiskRules
+	names := make([]string, len(stations))
+	for i, station := range stations {
+		names[i] = station.Name
+		if home != nil {
+			names[i] = floodrisk.HomeStation(app.Cfg, *home).Name
 		}
 	}
+	if home != nil {
+		rules = app.Assessor.RulesFor(*home)
+	}
+
+	risks := make([]*TidalFloodRisk, len(alerts))
 
-	// Extend the check window by buffer to account for rising sea level
-	// Sea level rises gradually before high tide peak, so if high tide is shortly after
-	// the alert expires, there's still risk from rising water during the alert period
-	expiresWithBuffer := alert.Expires.Add(tideBufferDuration)
-
-	// Query tide data for high tides (>2.5m) within alert period + buffer
-	var tideData []models.TideData
-	result := db.Where("tide_type = ? AND height_m > ? AND tide_time >= ? AND tide_time <= ?",
-		models.TideTypeHigh, 2.6, alert.Effective, expiresWithBuffer).
-		Order("height_m DESC").
-		Find(&tideData)
-
-	if result.Error != nil {
-		zap.S().Errorf("Failed to query tide data: %v", result.Error)
-		return &TidalFloodRisk{
-			HasRisk:   false,
-			RiskLevel: "unknown",
-			HeavyRain: hasHeavyRain,
-			Message:   "Unable to determine tidal flood risk",
-			TideTime:  basetraits.FormatTimeWithTimezone(time.Now().UTC(), timezone),
+	if dryRun {
+		var assessments []floodrisk.Assessment
+		var err error
+		if home != nil {
+			assessments, err = app.Assessor.AssessForHome(*home, alerts)
+		} else {
+			assessments, err = rules.AssessBatch(app.Tides, alerts, names)
+		}
+		if err != nil {
+			zap.S().Errorf("Failed to assess tidal flood risk: %v", err)
+			return unknownTidalFloodRisks(names, alerts, timezone, lang)
+		}
+
+		for i, assessment := range assessments {
+			risks[i] = toTidalFloodRisk(floodrisk.NewRecord(alerts[i].ID, names[i], rules.Version, assessment), timezone)
+			risks[i].Message = rules.LocalizedMessage(assessment.Rule, assessment.HeavyRain, assessment.Message, lang)
+			risks[i].RuleTrace = assessment.Trace
+		}
+	} else {
+		var records []models.FloodRiskAssessment
+		var err error
+		if home != nil {
+			records, err = app.Assessor.FindAllForHome(*home, alerts)
+		} else {
+			records, err = app.Assessor.FindAll(alerts, names)
+		}
+		if err != nil {
+			zap.S().Errorf("Failed to get flood risk assessments: %v", err)
+			return unknownTidalFloodRisks(names, alerts, timezone, lang)
+		}
+
+		for i, record := range records {
+			risks[i] = toTidalFloodRisk(record, timezone)
+			risks[i].Message = rules.LocalizedMessage(record.Rule, record.HeavyRain, record.Message, lang)
 		}
 	}
 
-	if len(tideData) == 0 {
-		// No high tide > 2.6m during the alert period or buffer
-		return &TidalFloodRisk{
-			HasRisk:   false,
-			RiskLevel: "none",
-			HeavyRain: hasHeavyRain,
-			Message:   "No tidal flood risk: No high tide (>2.6m) during or near alert period",
-			TideTime:  basetraits.FormatTimeWithTimezone(time.Now().UTC(), timezone),
+	if home != nil {
+		reading := liveReading(app, *home)
+		for _, risk := range risks {
+			risk.Home = home.Name
+			if reading != nil {
+				applyReading(risk, *reading, timezone, lang)
+			}
 		}
 	}
 
-	highestTide := tideData[0]
-
-	// Determine risk level based on whether high tide is within alert period or in buffer zone
-	if highestTide.TideTime.After(alert.Expires) {
-		// High tide is in the buffer zone (after alert expires but within 2 hours)
-		// Still risky because sea level is already rising during the alert
-		return &TidalFloodRisk{
-			HasRisk:     true,
-			RiskLevel:   "moderate",
-			TideType:    string(highestTide.TideType),
-			TideTime:    highestTide.TideTime,
-			TideHeightM: highestTide.HeightM,
-			HeavyRain:   hasHeavyRain,
-			Message:     "MODERATE RISK: Heavy rain with high tide (>2.6m) shortly after - Sea level rising during alert period",
+	return risks
+}
+
+// liveReading returns the latest sensor reading of a home within the live period, nil without one or without MQTT
+func liveReading(app *application.Application, home models.Home) *models.SensorReading {
+	reading, err := floodrisk.LiveReading(app.DB, app.Cfg, home.ID, time.Now().UTC())
+	if err != nil {
+		zap.S().Errorf("Failed to get the live reading of %s: %v", home.Name, err)
+		return nil
+	}
+	return reading
+}
+
+// applyReading adds the live reading to a risk, the assessor already overrode the risk when the reading is flooded
+func applyReading(risk *TidalFloodRisk, reading models.SensorReading, timezone, lang string) {
+	level := reading.LevelM
+	readAt := basetraits.FormatTimeWithTimezone(reading.ReadAt, timezone)
+	risk.ObservedLevelM = &level
+	risk.ObservedAt = &readAt
+
+	if risk.Rule == floodrisk.RuleObservedFlooding {
+		risk.Message = floodrisk.ObservedMessage(reading, lang)
+	}
+}
+
+// observedWindow returns the observed flooding window of a home, nil without home or flooding
+func observedWindow(app *application.Application, home *models.Home) *floodrisk.Window {
+	if home == nil {
+		return nil
+	}
+
+	observed, err := floodrisk.ObservedWindow(app.DB, app.Cfg, *home, time.Now().UTC())
+	if err != nil {
+		zap.S().Errorf("Failed to get the observed flooding of %s: %v", home.Name, err)
+		return nil
+	}
+	return observed
+}
+
+// unknownTidalFloodRisks returns an unknown risk per station when the assessment failed,
+// the rain intensity is still classified from the alert
+func unknownTidalFloodRisks(stations []string, alerts []weathermodels.AlertDetail, timezone, lang string) []*TidalFloodRisk {
+	risks := make([]*TidalFloodRisk, len(stations))
+	for i, station := range stations {
+		risks[i] = &TidalFloodRisk{
+			Location:      station,
+			RiskLevel:     floodrisk.LevelUnknown,
+			RainIntensity: string(floodrisk.ClassifyRain(alerts[i])),
+			Message:       i18n.T(lang, "risk.unknown"),
+			TideTime:      basetraits.FormatTimeWithTimezone(time.Now().UTC(), timezone),
 		}
 	}
 
-	// High tide > 2.6m during the alert period with heavy rain = high risk
-	return &TidalFloodRisk{
-		HasRisk:     true,
-		RiskLevel:   "high",
-		TideType:    string(highestTide.TideType),
-		TideTime:    highestTide.TideTime,
-		TideHeightM: highestTide.HeightM,
-		HeavyRain:   hasHeavyRain,
-		Message:     "HIGH RISK: Heavy rain expected during high tide (>2.6m) - Flash flood possible!",
+	return risks
+}
+
+// toTidalFloodRisk converts a FloodRiskAssessment to TidalFloodRisk with optional timezone formatting
+func toTidalFloodRisk(record models.FloodRiskAssessment, timezone string) *TidalFloodRisk {
+	risk := &TidalFloodRisk{
+		Location:      record.Location,
+		HasRisk:       record.HasRisk,
+		RiskLevel:     record.Level,
+		RiskScore:     record.Score,
+		Rule:          record.Rule,
+		HeavyRain:     record.HeavyRain,
+		RainIntensity: record.RainIntensity,
+		SurgeM:        record.SurgeM,
+		AnomalyM:      record.AnomalyM,
+		Message:       record.Message,
+		TideTime:      basetraits.FormatTimeWithTimezone(time.Now().UTC(), timezone),
+		ComputedAt:    basetraits.FormatTimeWithTimezone(record.ComputedAt, timezone),
+	}
+
+	if record.TideTime != nil {
+		risk.TideType = string(models.TideTypeHigh)
+		risk.TideTime = basetraits.FormatTimeWithTimezone(*record.TideTime, timezone)
+		risk.TideHeightM = record.TideHeightM
+		risk.AdjustedTideHeightM = record.TideHeightM + record.SurgeM + record.AnomalyM
+	}
+
+	if record.WaterLevelAt != nil {
+		waterLevelTime := basetraits.FormatTimeWithTimezone(*record.WaterLevelAt, timezone)
+		risk.WaterLevelM = record.WaterLevelM
+		risk.AdjustedWaterLevelM = record.WaterLevelM + record.SurgeM + record.AnomalyM
+		risk.WaterLevelTime = &waterLevelTime
 	}
+
+	return risk
 }
 
 func Index(app *application.Application) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		// Parse pagination parameters
-		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page, limit, offset := parsePagination(r)
 		timezone := parseTimezone(r.URL.Query().Get("timezone"))
 		activeFilter := r.URL.Query().Get("active")
 		locationFilter := r.URL.Query().Get("location")
+		provinceFilter := r.URL.Query().Get("province")
 		asCard := r.URL.Query().Get("as-card")
+		dryRun := r.URL.Query().Get("dry-run") == "true"
 
-		// Set defaults
-		if page < 1 {
-			page = 1
-		}
-		if limit < 1 || limit > 100 {
-			limit = 20
+		lang, err := parseLang(r)
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
 		}
 
-		offset := (page - 1) * limit
-
 		var alertDetails []weathermodels.AlertDetail
 		var total int64
 
-		// Build query with home location
+		// Point-in-polygon filter on a coordinate or a registered home
+		area, err := parseAreaFilter(app, r)
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Risk and cards are evaluated for the home when one is requested
+		var home *models.Home
+		if area != nil {
+			home = area.home
+		}
+
+		// Restrict to the configured provinces, or to the requested one
+		provinces := app.Cfg.GetProvinces()
+		if provinceFilter != "" {
+			province, ok := app.Cfg.FindProvince(provinceFilter)
+			if !ok {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest,
+					"province must be one of: "+strings.Join(provinces, ", "))
+				return
+			}
+			provinces = []string{province}
+		}
+
 		query := app.DB.Model(&weathermodels.AlertDetail{}).
-			Where("area_description = ?", "Kep. Riau")
+			Where("area_description IN ?", provinces)
 
 		// Apply active filter if requested
 		if activeFilter == "true" {
@@ -244,100 +367,133 @@ func Index(app *application.Application) httprouter.Handle {
 			query = query.Where("description LIKE ?", "%"+locationFilter+",%")
 		}
 
-		// Check if card format is requested
-		isCardMode := asCard == "html" || asCard == "html-dark"
+		// Check if card format is requested, html-dark is kept as a shortcut for the dark theme
+		isCardMode := isCardFormat(asCard)
 
-		// Get total count (skip for card mode since we only need 1)
-		if !isCardMode {
-			query.Count(&total)
+		var cards *cardRenderer
+		cardCount := 1
+		if isCardMode {
+			if cards, err = parseCardRenderer(app, r, asCard, lang); err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			if cardCount, err = parseCardCount(r); err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
 		}
 
-		// Get results - limit to 1 for card mode
+		// The tide chart plots the tides of the station, shading the alerts of the query
+		if asCard == cardFormatTideChart {
+			writeTideChart(app, w, r, cards, query, area, locationFilter, timezone)
+			return
+		}
+
+		// A card list shows the active alerts, all of them are loaded to be ranked by risk
+		isCardList := isCardMode && cardCount > 1
+		if isCardList && activeFilter != "true" {
+			now := time.Now().UTC()
+			query = query.Where("effective <= ? AND expires >= ?", now, now)
+		}
+
+		// Get results - limit to 1 for card mode, no limit (-1) for a card list
 		queryLimit := limit
 		queryOffset := offset
 		if isCardMode {
 			queryLimit = 1
 			queryOffset = 0
+			if isCardList {
+				queryLimit = -1
+			}
 		}
 
-		result := query.Order("sent DESC").
-			Offset(queryOffset).
-			Limit(queryLimit).
-			Find(&alertDetails)
+		if area != nil {
+			// Polygons are matched in memory, so the page is cut after filtering
+			var candidates []weathermodels.AlertDetail
+			if err := query.Order("sent DESC").Find(&candidates).Error; err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
 
-		if result.Error != nil {
-			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, result.Error.Error())
-			return
+			matched := area.filter(candidates)
+			total = int64(len(matched))
+			if queryLimit < 0 {
+				queryLimit = len(matched)
+			}
+			alertDetails = matched[min(queryOffset, len(matched)):min(queryOffset+queryLimit, len(matched))]
+		} else {
+			// Get total count (skip for card mode since we only need 1)
+			if !isCardMode {
+				query.Count(&total)
+			}
+
+			result := query.Order("sent DESC").
+				Offset(queryOffset).
+				Limit(queryLimit).
+				Find(&alertDetails)
+
+			if result.Error != nil {
+				basetraits.WriteErrorResponse(w, http.StatusInternalServerError, result.Error.Error())
+				return
+			}
 		}
 
 		// Handle card format response
 		if isCardMode {
+			// Cards are titled with the requested location, or the home
+			cardLocation := locationFilter
+			if home != nil && cardLocation == "" {
+				cardLocation = home.Name
+			}
+
 			if len(alertDetails) == 0 {
-				// Render "no alert" card instead of error
-				switch asCard {
-				case "html":
-					traits.WriteHTMLResponse(w, traits.RenderNoAlertCard(locationFilter))
-				case "html-dark":
-					traits.WriteHTMLResponse(w, traits.RenderNoAlertCardDark(locationFilter))
+				// Flooding observed at the home is shown even without alert
+				if observed := observedWindow(app, home); observed != nil {
+					cards.writeAlert(w, toObservedCardData(*observed, timezone, cardLocation, lang))
+					return
 				}
+
+				// Render "no alert" card instead of error
+				cards.writeNoAlert(w, cardLocation)
 				return
 			}
 
-			// Calculate flood risk for card
-			floodRisk := calculateTidalFloodRisk(app.DB, alertDetails[0], timezone)
-
-			// Convert to traits.TidalFloodRisk for card rendering
-			var cardFloodRisk *traits.TidalFloodRisk
-			if floodRisk != nil && floodRisk.HasRisk {
-				cardFloodRisk = &traits.TidalFloodRisk{
-					HasRisk:     floodRisk.HasRisk,
-					RiskLevel:   floodRisk.RiskLevel,
-					TideTime:    floodRisk.TideTime,
-					TideHeightM: floodRisk.TideHeightM,
-					Message:     floodRisk.Message,
-				}
+			// Calculate flood risk for the cards
+			stations := make([]config.TideStation, len(alertDetails))
+			for i, detail := range alertDetails {
+				stations[i] = app.Cfg.GetTideStationFor(locationFilter, detail.Description)
 			}
+			floodRisks := calculateTidalFloodRisks(app, home, stations, alertDetails, timezone, lang, false)
 
-			card := traits.AlertCardData{
-				Event:           alertDetails[0].Event,
-				Effective:       alertDetails[0].Effective,
-				Expires:         alertDetails[0].Expires,
-				AreaDescription: alertDetails[0].AreaDescription,
-				Description:     alertDetails[0].Description,
-				Timezone:        timezone,
-				FloodRisk:       cardFloodRisk,
-				Location:        locationFilter,
+			if !isCardList {
+				cards.writeAlert(w, toCardData(alertDetails[0], floodRisks[0], timezone, cardLocation, lang))
+				return
 			}
 
-			switch asCard {
-			case "html":
-				traits.WriteHTMLResponse(w, traits.RenderHTMLCard(card))
-			case "html-dark":
-				traits.WriteHTMLResponse(w, traits.RenderHTMLCardDark(card))
+			// Most important alerts first: highest flood risk, then highest severity
+			sortCardAlerts(alertDetails, floodRisks)
+			list := traits.AlertListData{Total: len(alertDetails), Location: cardLocation, Lang: lang}
+			for i := range alertDetails[:min(cardCount, len(alertDetails))] {
+				list.Cards = append(list.Cards, toCardData(alertDetails[i], floodRisks[i], timezone, cardLocation, lang))
 			}
+
+			cards.writeList(w, list)
 			return
 		}
 
-		// Convert to response DTOs with tidal flood risk calculation
-		responses := make([]AlertDetailResponse, len(alertDetails))
+		// Evaluate the tidal flood risk of the whole page at once
+		stations := make([]config.TideStation, len(alertDetails))
 		for i, detail := range alertDetails {
-			floodRisk := calculateTidalFloodRisk(app.DB, detail, timezone)
-			responses[i] = toResponse(detail, timezone, floodRisk)
-		}
-
-		// Calculate total pages
-		totalPages := int(total) / limit
-		if int(total)%limit > 0 {
-			totalPages++
+			stations[i] = app.Cfg.GetTideStationFor(locationFilter, detail.Description)
 		}
+		floodRisks := calculateTidalFloodRisks(app, home, stations, alertDetails, timezone, lang, dryRun)
 
-		pagination := basetraits.Pagination{
-			Page:       page,
-			Limit:      limit,
-			Total:      total,
-			TotalPages: totalPages,
+		// Convert to response DTOs
+		responses := make([]AlertDetailResponse, len(alertDetails))
+		for i, detail := range alertDetails {
+			responses[i] = toResponse(detail, timezone, floodRisks[i])
 		}
 
-		basetraits.WritePaginatedResponse(w, responses, pagination)
+		basetraits.WritePaginatedResponse(w, responses, newPagination(page, limit, total))
 	}
 }
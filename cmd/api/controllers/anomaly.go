@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+)
+
+// Sea level anomaly limits
+const (
+	defaultAnomalyValidity = 24 * time.Hour
+	maxAnomalyM            = 3.0
+)
+
+// AnomalyRequest is the body of a reported sea level anomaly
+type AnomalyRequest struct {
+	// Location picks the tide station, defaulting to the first configured station
+	Location string   `json:"location"`
+	AnomalyM *float64 `json:"anomaly_m"`
+	Note     string   `json:"note"`
+	// ValidFrom defaults to now, ValidUntil to 24 hours after ValidFrom
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// AnomalyResponse is the response DTO for a sea level anomaly
+type AnomalyResponse struct {
+	ID         string    `json:"id"`
+	Location   string    `json:"location"`
+	AnomalyM   float64   `json:"anomaly_m"`
+	Note       string    `json:"note"`
+	ValidFrom  time.Time `json:"valid_from"`
+	ValidUntil time.Time `json:"valid_until"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// toAnomalyResponse converts SeaLevelAnomaly to AnomalyResponse with optional timezone formatting
+func toAnomalyResponse(anomaly models.SeaLevelAnomaly, timezone string) AnomalyResponse {
+	return AnomalyResponse{
+		ID:         anomaly.ID,
+		Location:   anomaly.Location,
+		AnomalyM:   anomaly.AnomalyM,
+		Note:       anomaly.Note,
+		ValidFrom:  basetraits.FormatTimeWithTimezone(anomaly.ValidFrom, timezone),
+		ValidUntil: basetraits.FormatTimeWithTimezone(anomaly.ValidUntil, timezone),
+		CreatedAt:  basetraits.FormatTimeWithTimezone(anomaly.CreatedAt, timezone),
+	}
+}
+
+// AnomalyIndex lists the reported sea level anomalies, latest first
+// Filters: location (tide station), active=true (valid now)
+func AnomalyIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		page, limit, offset := parsePagination(r)
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+
+		query := app.DB.Model(&models.SeaLevelAnomaly{})
+
+		if locationFilter := r.URL.Query().Get("location"); locationFilter != "" {
+			station, err := findStation(app, locationFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query = query.Where("location = ?", station.Name)
+		}
+
+		if r.URL.Query().Get("active") == "true" {
+			now := time.Now().UTC()
+			query = query.Where("valid_from <= ? AND valid_until >= ?", now, now)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var anomalies []models.SeaLevelAnomaly
+		if err := query.Order("valid_from DESC").Offset(offset).Limit(limit).Find(&anomalies).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		responses := make([]AnomalyResponse, len(anomalies))
+		for i, anomaly := range anomalies {
+			responses[i] = toAnomalyResponse(anomaly, timezone)
+		}
+
+		basetraits.WritePaginatedResponse(w, responses, newPagination(page, limit, total))
+	}
+}
+
+// AnomalyCreate stores a manually reported sea level anomaly (observed minus predicted water level).
+// It is added to the predicted tide heights of the station while valid, the assessments are recomputed right away.
+func AnomalyCreate(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request AnomalyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		if request.AnomalyM == nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "anomaly_m is required")
+			return
+		}
+		if math.Abs(*request.AnomalyM) > maxAnomalyM {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "anomaly_m must be between -3 and 3")
+			return
+		}
+
+		station, err := findStation(app, request.Location)
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		anomaly := models.SeaLevelAnomaly{
+			Location:  station.Name,
+			AnomalyM:  *request.AnomalyM,
+			Note:      request.Note,
+			ValidFrom: time.Now().UTC(),
+		}
+		if request.ValidFrom != nil {
+			anomaly.ValidFrom = request.ValidFrom.UTC()
+		}
+		anomaly.ValidUntil = anomaly.ValidFrom.Add(defaultAnomalyValidity)
+		if request.ValidUntil != nil {
+			anomaly.ValidUntil = request.ValidUntil.UTC()
+		}
+		if !anomaly.ValidUntil.After(anomaly.ValidFrom) {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "valid_until must be after valid_from")
+			return
+		}
+
+		if err := app.DB.Create(&anomaly).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		app.Tides.Invalidate()
+		go app.Assessor.Run()
+
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		traits.WriteJSONResponse(w, http.StatusCreated, toAnomalyResponse(anomaly, timezone))
+	}
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/geo"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// areaFilter matches alerts whose polygons cover a point, or the alerts covering a registered home
+type areaFilter struct {
+	point geo.Point
+	home  *models.Home
+}
+
+// parseAreaFilter reads the lat/lon or home query parameters, nil when neither is given
+func parseAreaFilter(app *application.Application, r *http.Request) (*areaFilter, error) {
+	lat := r.URL.Query().Get("lat")
+	lon := r.URL.Query().Get("lon")
+	homeName := r.URL.Query().Get("home")
+
+	if homeName != "" {
+		if lat != "" || lon != "" {
+			return nil, fmt.Errorf("home cannot be combined with lat and lon")
+		}
+
+		home, err := findHome(app, homeName)
+		if err != nil {
+			return nil, err
+		}
+		return &areaFilter{point: home.Point(), home: home}, nil
+	}
+
+	if lat == "" && lon == "" {
+		return nil, nil
+	}
+	if lat == "" || lon == "" {
+		return nil, fmt.Errorf("lat and lon must be given together")
+	}
+
+	point := geo.Point{}
+	var err error
+	if point.Lat, err = strconv.ParseFloat(lat, 64); err != nil {
+		return nil, fmt.Errorf("invalid lat '%s'", lat)
+	}
+	if point.Lon, err = strconv.ParseFloat(lon, 64); err != nil {
+		return nil, fmt.Errorf("invalid lon '%s'", lon)
+	}
+	if !point.Valid() {
+		return nil, fmt.Errorf("lat must be between -90 and 90 and lon between -180 and 180")
+	}
+
+	return &areaFilter{point: point}, nil
+}
+
+// matches reports whether the alert covers the filter, alerts without a valid polygon never match a point
+func (f *areaFilter) matches(detail weathermodels.AlertDetail) bool {
+	if f.home != nil {
+		return f.home.MatchesAlert(detail)
+	}
+
+	polygons, err := geo.ParsePolygons(detail.Polygon)
+	if err != nil {
+		zap.S().Debugf("Skipping alert %s with invalid polygon: %v", detail.ID, err)
+		return false
+	}
+
+	for _, polygon := range polygons {
+		if polygon.Contains(f.point) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filter returns the alerts matching the filter, keeping their order
+func (f *areaFilter) filter(details []weathermodels.AlertDetail) []weathermodels.AlertDetail {
+	matched := make([]weathermodels.AlertDetail, 0, len(details))
+	for _, detail := range details {
+		if f.matches(detail) {
+			matched = append(matched, detail)
+		}
+	}
+	return matched
+}
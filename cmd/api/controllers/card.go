@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Card formats of the as-card parameter
+const (
+	cardFormatHTML      = "html"
+	cardFormatHTMLDark  = "html-dark"
+	cardFormatSVG       = "svg"
+	cardFormatPNG       = "png"
+	cardFormatTideChart = "tide-chart"
+)
+
+// cardRenderer renders cards in the requested format and theme
+type cardRenderer struct {
+	format  string
+	theme   traits.Theme
+	lang    string
+	options traits.ImageOptions
+}
+
+// maxCards is the largest cards parameter of a stacked card list
+const maxCards = 10
+
+// parseCardCount reads the cards parameter, 1 (a single card) by default
+func parseCardCount(r *http.Request) (int, error) {
+	value := r.URL.Query().Get("cards")
+	if value == "" {
+		return 1, nil
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 1 || count > maxCards {
+		return 0, fmt.Errorf("cards must be between 1 and %d", maxCards)
+	}
+	return count, nil
+}
+
+// toCardData converts an alert and its flood risk to card data
+func toCardData(detail weathermodels.AlertDetail, floodRisk *TidalFloodRisk, timezone, location, lang string) traits.AlertCardData {
+	var cardFloodRisk *traits.TidalFloodRisk
+	if floodRisk != nil && floodRisk.HasRisk {
+		cardFloodRisk = &traits.TidalFloodRisk{
+			HasRisk:     floodRisk.HasRisk,
+			RiskLevel:   floodRisk.RiskLevel,
+			RiskScore:   floodRisk.RiskScore,
+			TideTime:    floodRisk.TideTime,
+			TideHeightM: floodRisk.TideHeightM,
+			Message:     floodRisk.Message,
+		}
+	}
+
+	return traits.AlertCardData{
+		Event:           detail.Event,
+		Effective:       detail.Effective,
+		Expires:         detail.Expires,
+		AreaDescription: detail.AreaDescription,
+		Description:     detail.Description,
+		Timezone:        timezone,
+		FloodRisk:       cardFloodRisk,
+		Location:        location,
+		Lang:            lang,
+	}
+}
+
+// toObservedCardData converts the observed flooding window of a home to card data, shown instead of the all clear card
+func toObservedCardData(window floodrisk.Window, timezone, location, lang string) traits.AlertCardData {
+	message := floodrisk.ObservedMessage(*window.Observed, lang)
+
+	return traits.AlertCardData{
+		Event:       i18n.T(lang, "card.observed_event"),
+		Effective:   window.Start,
+		Expires:     window.End,
+		Description: message,
+		Timezone:    timezone,
+		FloodRisk: &traits.TidalFloodRisk{
+			HasRisk:     true,
+			RiskLevel:   window.Level,
+			RiskScore:   window.Score,
+			TideTime:    window.Observed.ReadAt,
+			TideHeightM: window.Observed.LevelM,
+			Message:     message,
+		},
+		Location: location,
+		Lang:     lang,
+	}
+}
+
+// sortCardAlerts orders the alerts and their flood risks by risk level, then severity, keeping the sent order on ties
+func sortCardAlerts(alerts []weathermodels.AlertDetail, risks []*TidalFloodRisk) {
+	rank := func(risk *TidalFloodRisk) int {
+		if risk == nil {
+			return 0
+		}
+		return floodrisk.LevelRank(risk.RiskLevel)
+	}
+
+	order := make([]int, len(alerts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		i, j := order[a], order[b]
+		if rank(risks[i]) != rank(risks[j]) {
+			return rank(risks[i]) > rank(risks[j])
+		}
+		return floodrisk.SeverityRank(alerts[i].Severity) > floodrisk.SeverityRank(alerts[j].Severity)
+	})
+
+	sortedAlerts := make([]weathermodels.AlertDetail, len(alerts))
+	sortedRisks := make([]*TidalFloodRisk, len(risks))
+	for position, i := range order {
+		sortedAlerts[position], sortedRisks[position] = alerts[i], risks[i]
+	}
+	copy(alerts, sortedAlerts)
+	copy(risks, sortedRisks)
+}
+
+// isCardFormat reports whether the as-card value is a supported card format
+func isCardFormat(format string) bool {
+	switch format {
+	case cardFormatHTML, cardFormatHTMLDark, cardFormatSVG, cardFormatPNG, cardFormatTideChart:
+		return true
+	}
+	return false
+}
+
+// parseCardRenderer reads the theme and, for image formats, the width, height and mono parameters
+func parseCardRenderer(app *application.Application, r *http.Request, format, lang string) (*cardRenderer, error) {
+	themeName := r.URL.Query().Get("theme")
+	if format == cardFormatHTMLDark {
+		themeName = traits.ThemeDark
+	} else if themeName == "" {
+		themeName = traits.ThemeLight
+	}
+
+	theme, ok := app.Themes.Get(themeName)
+	if !ok {
+		return nil, fmt.Errorf("theme must be one of: %s", strings.Join(app.Themes.Names(), ", "))
+	}
+
+	renderer := &cardRenderer{format: format, theme: theme, lang: lang}
+	if format != cardFormatSVG && format != cardFormatPNG {
+		return renderer, nil
+	}
+
+	renderer.options = traits.ImageOptions{
+		Width:      traits.DefaultImageWidth,
+		Monochrome: r.URL.Query().Get("mono") == "true",
+	}
+	for name, target := range map[string]*int{"width": &renderer.options.Width, "height": &renderer.options.Height} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+
+		size, err := strconv.Atoi(value)
+		if err != nil || size < traits.MinImageSize || size > traits.MaxImageSize {
+			return nil, fmt.Errorf("%s must be between %d and %d", name, traits.MinImageSize, traits.MaxImageSize)
+		}
+		*target = size
+	}
+
+	return renderer, nil
+}
+
+// writeAlert writes the card of a single alert
+func (c *cardRenderer) writeAlert(w http.ResponseWriter, data traits.AlertCardData) {
+	switch c.format {
+	case cardFormatSVG:
+		traits.WriteSVGResponse(w, traits.RenderSVGCard(data, c.theme, c.options))
+	case cardFormatPNG:
+		content, err := traits.RenderPNGCard(data, c.theme, c.options)
+		writePNGCard(w, content, err)
+	default:
+		content, err := traits.RenderCard(data, c.theme)
+		writeHTMLCard(w, content, err)
+	}
+}
+
+// writeList writes the stacked cards of several alerts
+func (c *cardRenderer) writeList(w http.ResponseWriter, list traits.AlertListData) {
+	switch c.format {
+	case cardFormatSVG:
+		traits.WriteSVGResponse(w, traits.RenderSVGCardList(list, c.theme, c.options))
+	case cardFormatPNG:
+		content, err := traits.RenderPNGCardList(list, c.theme, c.options)
+		writePNGCard(w, content, err)
+	default:
+		content, err := traits.RenderCardList(list, c.theme)
+		writeHTMLCard(w, content, err)
+	}
+}
+
+// writeNoAlert writes the "all clear" card
+func (c *cardRenderer) writeNoAlert(w http.ResponseWriter, location string) {
+	switch c.format {
+	case cardFormatSVG:
+		traits.WriteSVGResponse(w, traits.RenderSVGNoAlert(location, c.theme, c.lang, c.options))
+	case cardFormatPNG:
+		content, err := traits.RenderPNGNoAlert(location, c.theme, c.lang, c.options)
+		writePNGCard(w, content, err)
+	default:
+		content, err := traits.RenderNoAlert(location, c.theme, c.lang)
+		writeHTMLCard(w, content, err)
+	}
+}
+
+// writeHTMLCard writes a rendered card, or an error response when rendering failed
+func writeHTMLCard(w http.ResponseWriter, content string, err error) {
+	if err != nil {
+		zap.S().Errorf("Failed to render card: %v", err)
+		basetraits.WriteErrorResponse(w, http.StatusInternalServerError, "failed to render card")
+		return
+	}
+	traits.WriteHTMLResponse(w, content)
+}
+
+// writePNGCard writes a rendered PNG card, or an error response when encoding failed
+func writePNGCard(w http.ResponseWriter, content []byte, err error) {
+	if err != nil {
+		zap.S().Errorf("Failed to render card: %v", err)
+		basetraits.WriteErrorResponse(w, http.StatusInternalServerError, "failed to render card")
+		return
+	}
+	traits.WritePNGResponse(w, content)
+}
+
+// Tide chart period: from a little before now to the requested hours ahead
+const (
+	tideChartBehind       = 2 * time.Hour
+	tideChartDefaultHours = 24
+	tideChartMaxHours     = 48
+	tideChartStep         = 10 * time.Minute
+)
+
+// writeTideChart writes the tide chart card of the station of the location or home.
+// The alerts of the query overlapping the chart are shaded, the flood threshold is the lowest risk rule tide height.
+func writeTideChart(app *application.Application, w http.ResponseWriter, r *http.Request, cards *cardRenderer,
+	query *gorm.DB, area *areaFilter, locationFilter, timezone string) {
+	hours := tideChartDefaultHours
+	if hoursParam := r.URL.Query().Get("hours"); hoursParam != "" {
+		parsed, err := strconv.Atoi(hoursParam)
+		if err != nil || parsed < 1 || parsed > tideChartMaxHours {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("hours must be between 1 and %d", tideChartMaxHours))
+			return
+		}
+		hours = parsed
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-tideChartBehind)
+	to := now.Add(time.Duration(hours) * time.Hour)
+
+	station := app.Cfg.GetTideStationFor(locationFilter)
+	rules := app.RiskRules
+	location := locationFilter
+	if area != nil && area.home != nil {
+		station = floodrisk.HomeStation(app.Cfg, *area.home)
+		rules = app.Assessor.RulesFor(*area.home)
+		if location == "" {
+			location = area.home.Name
+		}
+	}
+
+	var alertDetails []weathermodels.AlertDetail
+	if err := query.Where("effective <= ? AND expires >= ?", to, from).Order("effective ASC").Find(&alertDetails).Error; err != nil {
+		basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if area != nil {
+		alertDetails = area.filter(alertDetails)
+	}
+
+	curve, err := floodrisk.LoadTideCurve(app.DB, station.Name, from, to)
+	if err != nil {
+		basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	chart := traits.TideChartData{
+		Station:    station.Name,
+		Location:   location,
+		From:       from,
+		To:         to,
+		Now:        now,
+		ThresholdM: rules.MinTideHeight(),
+		Timezone:   timezone,
+		Lang:       cards.lang,
+	}
+	for _, point := range curve.Sample(from, to, tideChartStep) {
+		chart.Points = append(chart.Points, traits.TideChartPoint{Time: point.Time, HeightM: point.HeightM})
+	}
+	for _, detail := range alertDetails {
+		chart.Alerts = append(chart.Alerts, traits.TideChartAlert{Event: detail.Event, Start: detail.Effective, End: detail.Expires})
+	}
+
+	content, err := traits.RenderTideChart(chart, cards.theme)
+	writeHTMLCard(w, content, err)
+}
@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+)
+
+// maxFloodRiskHours limits how far ahead the flood risk timeline can be evaluated
+const maxFloodRiskHours = 168
+
+// RiskAlertResponse is a compact view of the alert contributing to a risk window
+type RiskAlertResponse struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	Headline  string    `json:"headline"`
+	Severity  string    `json:"severity"`
+	Urgency   string    `json:"urgency"`
+	Effective time.Time `json:"effective"`
+	Expires   time.Time `json:"expires"`
+}
+
+// RiskWindowResponse is the response DTO for a flood risk window
+type RiskWindowResponse struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Level   string    `json:"level"`
+	Score   int       `json:"score"`
+	Message string    `json:"message"`
+	Rule    string    `json:"rule"`
+	// Tide has the astronomical height, AdjustedHeightM adds the surge and anomaly (the observed level for observed windows)
+	Tide            *TideDataResponse  `json:"tide"`
+	SurgeM          float64            `json:"surge_m"`
+	AnomalyM        float64            `json:"anomaly_m"`
+	AdjustedHeightM float64            `json:"adjusted_height_m"`
+	Alert           *RiskAlertResponse `json:"alert"`
+	// Observed is the flooded sensor reading of an observed window, which has no tide
+	Observed *ObservedReadingResponse `json:"observed,omitempty"`
+}
+
+// FloodRiskResponse is the response DTO for the flood risk timeline
+type FloodRiskResponse struct {
+	Location   string               `json:"location"`
+	Home       string               `json:"home,omitempty"`
+	From       time.Time            `json:"from"`
+	To         time.Time            `json:"to"`
+	NextWindow *RiskWindowResponse  `json:"next_window"`
+	Windows    []RiskWindowResponse `json:"windows"`
+	// Observed is the live sensor reading of the home, a flooded reading also opens the first window
+	Observed *ObservedReadingResponse `json:"observed,omitempty"`
+}
+
+// ObservedReadingResponse is the live water level reading of a home sensor
+type ObservedReadingResponse struct {
+	Sensor     string    `json:"sensor"`
+	LevelM     float64   `json:"level_m"`
+	ThresholdM float64   `json:"threshold_m"`
+	Flooded    bool      `json:"flooded"`
+	Level      string    `json:"level"`
+	Message    string    `json:"message"`
+	ReadAt     time.Time `json:"read_at"`
+}
+
+// toRiskWindowResponse converts a flood risk Window to RiskWindowResponse with its localized message and optional timezone formatting
+func toRiskWindowResponse(window floodrisk.Window, message, timezone, lang string) RiskWindowResponse {
+	response := RiskWindowResponse{
+		Start:    basetraits.FormatTimeWithTimezone(window.Start, timezone),
+		End:      basetraits.FormatTimeWithTimezone(window.End, timezone),
+		Level:    window.Level,
+		Score:    window.Score,
+		Message:  message,
+		Rule:     window.Rule,
+		SurgeM:   window.SurgeM,
+		AnomalyM: window.AnomalyM,
+	}
+
+	if window.Observed != nil {
+		response.AdjustedHeightM = window.Observed.LevelM
+		response.Observed = toObservedReadingResponse(*window.Observed, timezone, lang)
+	} else {
+		tide := toTideResponse(window.Tide, timezone)
+		response.Tide = &tide
+		response.AdjustedHeightM = window.Tide.HeightM + window.SurgeM + window.AnomalyM
+	}
+
+	if window.Alert != nil {
+		response.Alert = &RiskAlertResponse{
+			ID:        window.Alert.ID,
+			Event:     window.Alert.Event,
+			Headline:  window.Alert.Headline,
+			Severity:  window.Alert.Severity,
+			Urgency:   window.Alert.Urgency,
+			Effective: basetraits.FormatTimeWithTimezone(window.Alert.Effective, timezone),
+			Expires:   basetraits.FormatTimeWithTimezone(window.Alert.Expires, timezone),
+		}
+	}
+
+	return response
+}
+
+// FloodRisk returns the flood risk windows of the next N hours, with or without active alerts
+// Parameters: hours (default 24, max 168), location (alert location and tide station), home, timezone, lang
+func FloodRisk(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		locationFilter := r.URL.Query().Get("location")
+
+		lang, err := parseLang(r)
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		hours := 24
+		if hoursParam := r.URL.Query().Get("hours"); hoursParam != "" {
+			parsed, err := strconv.Atoi(hoursParam)
+			if err != nil || parsed < 1 || parsed > maxFloodRiskHours {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "hours must be between 1 and 168")
+				return
+			}
+			hours = parsed
+		}
+
+		from := time.Now().UTC()
+		to := from.Add(time.Duration(hours) * time.Hour)
+		station := app.Cfg.GetTideStationFor(locationFilter)
+		rules := app.RiskRules
+
+		// A home brings its own station and flood threshold
+		var home *models.Home
+		if homeFilter := r.URL.Query().Get("home"); homeFilter != "" {
+			found, err := findHome(app, homeFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			home = found
+			station = floodrisk.HomeStation(app.Cfg, *home)
+			rules = app.Assessor.RulesFor(*home)
+		}
+
+		// Alerts that can overlap any tide in the evaluated period
+		query := app.RiskRules.TimelineAlertsQuery(app.DB, app.Cfg.GetProvinces(), from, to)
+
+		if locationFilter != "" {
+			query = query.Where("description LIKE ?", "%"+locationFilter+",%")
+		}
+
+		var alertDetails []weathermodels.AlertDetail
+		if err := query.Order("sent DESC").Find(&alertDetails).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		var windows []floodrisk.Window
+		if home != nil {
+			homeAlerts := make([]weathermodels.AlertDetail, 0, len(alertDetails))
+			for _, alert := range alertDetails {
+				if home.MatchesAlert(alert) {
+					homeAlerts = append(homeAlerts, alert)
+				}
+			}
+
+			// Flooding observed at the home comes first, ahead of the predicted windows
+			windows, err = app.Assessor.HomeTimeline(*home, homeAlerts, from, to)
+		} else {
+			windows, err = rules.Timeline(app.Tides, station.Name, alertDetails, from, to)
+		}
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := FloodRiskResponse{
+			Location: station.Name,
+			Home:     homeName(home),
+			From:     basetraits.FormatTimeWithTimezone(from, timezone),
+			To:       basetraits.FormatTimeWithTimezone(to, timezone),
+			Windows:  make([]RiskWindowResponse, len(windows)),
+		}
+
+		for i, window := range windows {
+			response.Windows[i] = toRiskWindowResponse(window, rules.WindowMessage(window, lang), timezone, lang)
+		}
+
+		// Windows are sorted by tide time, the first one is ongoing or upcoming (or the observed flooding)
+		if len(response.Windows) > 0 {
+			response.NextWindow = &response.Windows[0]
+		}
+
+		if home != nil {
+			if reading := liveReading(app, *home); reading != nil {
+				response.Observed = toObservedReadingResponse(*reading, timezone, lang)
+			}
+		}
+
+		traits.WriteJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+// FloodRiskAssessmentResponse is the response DTO for stored flood risk assessments
+type FloodRiskAssessmentResponse struct {
+	ID            string     `json:"id"`
+	AlertDetailID string     `json:"alert_detail_id"`
+	Location      string     `json:"location"`
+	HomeID        string     `json:"home_id,omitempty"`
+	TideDataID    *string    `json:"tide_data_id"`
+	TideTime      *time.Time `json:"tide_time"`
+	TideHeightM   float64    `json:"tide_height_m"`
+	WaterLevelM   float64    `json:"water_level_m"`
+	WaterLevelAt  *time.Time `json:"water_level_at"`
+	SurgeM        float64    `json:"surge_m"`
+	AnomalyM      float64    `json:"anomaly_m"`
+	HasRisk       bool       `json:"has_risk"`
+	Level         string     `json:"level"`
+	Score         int        `json:"score"`
+	HeavyRain     bool       `json:"heavy_rain"`
+	RainIntensity string     `json:"rain_intensity"`
+	Rule          string     `json:"rule"`
+	Message       string     `json:"message"`
+	RuleVersion   string     `json:"rule_version"`
+	ComputedAt    time.Time  `json:"computed_at"`
+}
+
+// toAssessmentResponse converts FloodRiskAssessment to FloodRiskAssessmentResponse with optional timezone formatting
+func toAssessmentResponse(record models.FloodRiskAssessment, timezone string) FloodRiskAssessmentResponse {
+	response := FloodRiskAssessmentResponse{
+		ID:            record.ID,
+		AlertDetailID: record.AlertDetailID,
+		Location:      record.Location,
+		HomeID:        record.HomeID,
+		TideDataID:    record.TideDataID,
+		TideHeightM:   record.TideHeightM,
+		WaterLevelM:   record.WaterLevelM,
+		SurgeM:        record.SurgeM,
+		AnomalyM:      record.AnomalyM,
+		HasRisk:       record.HasRisk,
+		Level:         record.Level,
+		Score:         record.Score,
+		HeavyRain:     record.HeavyRain,
+		RainIntensity: record.RainIntensity,
+		Rule:          record.Rule,
+		Message:       record.Message,
+		RuleVersion:   record.RuleVersion,
+		ComputedAt:    basetraits.FormatTimeWithTimezone(record.ComputedAt, timezone),
+	}
+
+	if record.TideTime != nil {
+		tideTime := basetraits.FormatTimeWithTimezone(*record.TideTime, timezone)
+		response.TideTime = &tideTime
+	}
+
+	if record.WaterLevelAt != nil {
+		waterLevelAt := basetraits.FormatTimeWithTimezone(*record.WaterLevelAt, timezone)
+		response.WaterLevelAt = &waterLevelAt
+	}
+
+	return response
+}
+
+// AssessmentIndex lists the stored flood risk assessments, most recent tide first
+// Filters: location, home (station assessments when omitted), level (minimum level),
+// from, to (tide time, RFC3339 or YYYY-MM-DD, inclusive)
+func AssessmentIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		// Parse pagination parameters
+		page, limit, offset := parsePagination(r)
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		locationFilter := r.URL.Query().Get("location")
+		levelFilter := r.URL.Query().Get("level")
+		fromFilter := r.URL.Query().Get("from")
+		toFilter := r.URL.Query().Get("to")
+
+		loc := loadTimezone(timezone)
+		query := app.DB.Model(&models.FloodRiskAssessment{})
+
+		if locationFilter != "" {
+			query = query.Where("location = ?", locationFilter)
+		}
+
+		homeID := ""
+		if homeFilter := r.URL.Query().Get("home"); homeFilter != "" {
+			home, err := findHome(app, homeFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			homeID = home.ID
+		}
+		query = query.Where("home_id = ?", homeID)
+
+		if levelFilter != "" {
+			if floodrisk.LevelRank(levelFilter) == 0 {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "level must be one of low, moderate, high, extreme")
+				return
+			}
+
+			// Every level ranked at least as high as the requested one
+			levels := make([]string, 0)
+			for _, level := range []string{floodrisk.LevelLow, floodrisk.LevelModerate, floodrisk.LevelHigh, floodrisk.LevelExtreme} {
+				if floodrisk.LevelRank(level) >= floodrisk.LevelRank(levelFilter) {
+					levels = append(levels, level)
+				}
+			}
+			query = query.Where("level IN ?", levels)
+		}
+
+		if fromFilter != "" {
+			from, _, err := parseTimeParam(fromFilter, loc)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query = query.Where("tide_time >= ?", from)
+		}
+
+		if toFilter != "" {
+			to, isDate, err := parseTimeParam(toFilter, loc)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			// A plain date includes the whole day
+			if isDate {
+				query = query.Where("tide_time < ?", to.AddDate(0, 0, 1))
+			} else {
+				query = query.Where("tide_time <= ?", to)
+			}
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var records []models.FloodRiskAssessment
+		result := query.Order("tide_time DESC").
+			Order("computed_at DESC").
+			Offset(offset).
+			Limit(limit).
+			Find(&records)
+
+		if result.Error != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, result.Error.Error())
+			return
+		}
+
+		responses := make([]FloodRiskAssessmentResponse, len(records))
+		for i, record := range records {
+			responses[i] = toAssessmentResponse(record, timezone)
+		}
+
+		basetraits.WritePaginatedResponse(w, responses, newPagination(page, limit, total))
+	}
+}
+
+// toObservedReadingResponse converts a live SensorReading to ObservedReadingResponse, localizing the flooding message
+func toObservedReadingResponse(reading models.SensorReading, timezone, lang string) *ObservedReadingResponse {
+	response := &ObservedReadingResponse{
+		Sensor:     reading.Sensor,
+		LevelM:     reading.LevelM,
+		ThresholdM: reading.ThresholdM,
+		Flooded:    reading.Flooded,
+		Level:      floodrisk.LevelNone,
+		ReadAt:     basetraits.FormatTimeWithTimezone(reading.ReadAt, timezone),
+	}
+	if reading.Flooded {
+		response.Level = floodrisk.LevelExtreme
+		response.Message = floodrisk.ObservedMessage(reading, lang)
+	}
+	return response
+}
+
+// homeName returns the name of the home, empty without home
+func homeName(home *models.Home) string {
+	if home == nil {
+		return ""
+	}
+	return home.Name
+}
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/geo"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+
+	"gorm.io/gorm"
+)
+
+// HomeRequest is the request body to create or replace a home
+type HomeRequest struct {
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	// Area is an optional polygon ("lat,lon lat,lon ..."), the coordinates default to its center
+	Area            string   `json:"area"`
+	Station         string   `json:"station"`
+	FloodThresholdM float64  `json:"flood_threshold_m"`
+	Keywords        []string `json:"keywords"`
+}
+
+// HomeResponse is the response DTO for homes
+type HomeResponse struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Latitude        float64  `json:"latitude"`
+	Longitude       float64  `json:"longitude"`
+	Area            string   `json:"area"`
+	Station         string   `json:"station"`
+	FloodThresholdM float64  `json:"flood_threshold_m"`
+	Keywords        []string `json:"keywords"`
+	// TideStation is the station used for the home, the configured one or the one matching the keywords
+	TideStation string    `json:"tide_station"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// toHomeResponse converts Home to HomeResponse with optional timezone formatting
+func toHomeResponse(app *application.Application, home models.Home, timezone string) HomeResponse {
+	keywords := home.Keywords
+	if keywords == nil {
+		keywords = []string{}
+	}
+
+	return HomeResponse{
+		ID:              home.ID,
+		Name:            home.Name,
+		Latitude:        home.Latitude,
+		Longitude:       home.Longitude,
+		Area:            home.Area,
+		Station:         home.Station,
+		FloodThresholdM: home.FloodThresholdM,
+		Keywords:        keywords,
+		TideStation:     floodrisk.HomeStation(app.Cfg, home).Name,
+		CreatedAt:       basetraits.FormatTimeWithTimezone(home.CreatedAt, timezone),
+		UpdatedAt:       basetraits.FormatTimeWithTimezone(home.UpdatedAt, timezone),
+	}
+}
+
+// errUnknownHome is returned by findHome when no home has the name
+var errUnknownHome = errors.New("unknown home")
+
+// findHome returns the home with the given name (case-insensitive)
+func findHome(app *application.Application, name string) (*models.Home, error) {
+	var home models.Home
+	err := app.DB.Where("LOWER(name) = ?", strings.ToLower(strings.TrimSpace(name))).First(&home).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w '%s'", errUnknownHome, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &home, nil
+}
+
+// applyHomeRequest validates the request and copies it to the home
+func applyHomeRequest(app *application.Application, home *models.Home, request HomeRequest) error {
+	home.Name = strings.TrimSpace(request.Name)
+	if home.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	home.Area = strings.TrimSpace(request.Area)
+	if home.Area != "" {
+		polygons, err := geo.ParsePolygons(home.Area)
+		if err != nil {
+			return fmt.Errorf("invalid area: %v", err)
+		}
+		if len(polygons) != 1 {
+			return fmt.Errorf("area must be a single polygon")
+		}
+
+		center := polygons[0].Center()
+		home.Latitude, home.Longitude = center.Lat, center.Lon
+	}
+
+	if request.Latitude != nil || request.Longitude != nil {
+		if request.Latitude == nil || request.Longitude == nil {
+			return fmt.Errorf("latitude and longitude must be given together")
+		}
+		home.Latitude, home.Longitude = *request.Latitude, *request.Longitude
+	} else if home.Area == "" {
+		return fmt.Errorf("latitude and longitude, or area, are required")
+	}
+
+	if !home.Point().Valid() {
+		return fmt.Errorf("latitude must be between -90 and 90 and longitude between -180 and 180")
+	}
+
+	home.Station = strings.TrimSpace(request.Station)
+	if home.Station != "" {
+		known := make([]string, 0)
+		for _, station := range app.Cfg.GetTideStations() {
+			known = append(known, station.Name)
+		}
+		if !containsString(known, home.Station) {
+			return fmt.Errorf("station must be one of: %s", strings.Join(known, ", "))
+		}
+	}
+
+	if request.FloodThresholdM < 0 {
+		return fmt.Errorf("flood_threshold_m must not be negative")
+	}
+	home.FloodThresholdM = request.FloodThresholdM
+
+	home.Keywords = make([]string, 0, len(request.Keywords))
+	for _, keyword := range request.Keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			home.Keywords = append(home.Keywords, keyword)
+		}
+	}
+
+	// Names are used in the ?home= filters, so they must be unique
+	var count int64
+	if err := app.DB.Model(&models.Home{}).
+		Where("LOWER(name) = ? AND id <> ?", strings.ToLower(home.Name), home.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errHomeNameTaken
+	}
+
+	return nil
+}
+
+// errHomeNameTaken is returned when another home already uses the name
+var errHomeNameTaken = errors.New("a home with this name already exists")
+
+// containsString reports whether the list contains the value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// writeHomeRequestError writes a validation error, conflicts for duplicate names
+func writeHomeRequestError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errHomeNameTaken) {
+		basetraits.WriteErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+}
+
+// loadHome loads the home of the id route parameter, writing a 404 when missing
+func loadHome(app *application.Application, w http.ResponseWriter, p httprouter.Params) (*models.Home, bool) {
+	var home models.Home
+	err := app.DB.Where("id = ?", p.ByName("id")).First(&home).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		basetraits.WriteErrorResponse(w, http.StatusNotFound, "home not found")
+		return nil, false
+	}
+	if err != nil {
+		basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return &home, true
+}
+
+// HomeIndex lists the registered homes by name
+func HomeIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		page, limit, offset := parsePagination(r)
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+
+		var total int64
+		query := app.DB.Model(&models.Home{})
+		query.Count(&total)
+
+		var homes []models.Home
+		if err := query.Order("name ASC").Offset(offset).Limit(limit).Find(&homes).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		responses := make([]HomeResponse, len(homes))
+		for i, home := range homes {
+			responses[i] = toHomeResponse(app, home, timezone)
+		}
+
+		basetraits.WritePaginatedResponse(w, responses, newPagination(page, limit, total))
+	}
+}
+
+// HomeShow returns a single home
+func HomeShow(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		home, ok := loadHome(app, w, p)
+		if !ok {
+			return
+		}
+
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		traits.WriteJSONResponse(w, http.StatusOK, toHomeResponse(app, *home, timezone))
+	}
+}
+
+// HomeCreate registers a new home
+func HomeCreate(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request HomeRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		var home models.Home
+		if err := applyHomeRequest(app, &home, request); err != nil {
+			writeHomeRequestError(w, err)
+			return
+		}
+
+		if err := app.DB.Create(&home).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		traits.WriteJSONResponse(w, http.StatusCreated, toHomeResponse(app, home, timezone))
+	}
+}
+
+// HomeUpdate replaces a home, its stored flood risk assessments are recomputed on next use
+func HomeUpdate(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		home, ok := loadHome(app, w, p)
+		if !ok {
+			return
+		}
+
+		var request HomeRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		stationBefore := floodrisk.HomeStation(app.Cfg, *home).Name
+		if err := applyHomeRequest(app, home, request); err != nil {
+			writeHomeRequestError(w, err)
+			return
+		}
+
+		err := app.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(home).Error; err != nil {
+				return err
+			}
+
+			// Assessments at the previous station no longer apply
+			if stationAfter := floodrisk.HomeStation(app.Cfg, *home).Name; stationAfter != stationBefore {
+				return tx.Where("home_id = ?", home.ID).Delete(&models.FloodRiskAssessment{}).Error
+			}
+			return nil
+		})
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		traits.WriteJSONResponse(w, http.StatusOK, toHomeResponse(app, *home, timezone))
+	}
+}
+
+// HomeDelete removes a home and its flood risk assessments
+func HomeDelete(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		home, ok := loadHome(app, w, p)
+		if !ok {
+			return
+		}
+
+		err := app.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("home_id = ?", home.ID).Delete(&models.FloodRiskAssessment{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(home).Error
+		})
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
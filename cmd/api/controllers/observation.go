@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+)
+
+// observationClockSkew tolerates observation times slightly ahead of the server clock
+const observationClockSkew = 5 * time.Minute
+
+// ObservationRequest is the body of a water level observation
+type ObservationRequest struct {
+	// ObservedAt defaults to now
+	ObservedAt *time.Time `json:"observed_at"`
+	// Location is a home name, or an area picking the tide station when it isn't one
+	Location string   `json:"location"`
+	LevelM   *float64 `json:"level_m"`
+	Flooded  bool     `json:"flooded"`
+	PhotoURL string   `json:"photo_url"`
+	Note     string   `json:"note"`
+}
+
+// ObservationResponse is the response DTO for water level observations
+type ObservationResponse struct {
+	ID         string    `json:"id"`
+	HomeID     string    `json:"home_id,omitempty"`
+	Location   string    `json:"location"`
+	ObservedAt time.Time `json:"observed_at"`
+	LevelM     float64   `json:"level_m"`
+	Flooded    bool      `json:"flooded"`
+	PhotoURL   string    `json:"photo_url"`
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CalibrationPointResponse compares an observation with the predicted tide
+type CalibrationPointResponse struct {
+	ObservationID string    `json:"observation_id"`
+	ObservedAt    time.Time `json:"observed_at"`
+	LevelM        float64   `json:"level_m"`
+	PredictedM    float64   `json:"predicted_m"`
+	ResidualM     float64   `json:"residual_m"`
+	Flooded       bool      `json:"flooded"`
+}
+
+// CalibrationResponse is the calibration report of a home
+type CalibrationResponse struct {
+	Home     string `json:"home"`
+	Location string `json:"location"`
+	// CurrentThresholdM is the home flood threshold, or the lowest rule threshold when the home has none
+	CurrentThresholdM   float64                    `json:"current_threshold_m"`
+	SuggestedThresholdM *float64                   `json:"suggested_threshold_m"`
+	Observations        int                        `json:"observations"`
+	Flooded             int                        `json:"flooded"`
+	Skipped             int                        `json:"skipped"`
+	Conflicts           int                        `json:"conflicts"`
+	LowestFloodedM      *float64                   `json:"lowest_flooded_m"`
+	HighestDryM         *float64                   `json:"highest_dry_m"`
+	MeanResidualM       float64                    `json:"mean_residual_m"`
+	RMSResidualM        float64                    `json:"rms_residual_m"`
+	Points              []CalibrationPointResponse `json:"points"`
+}
+
+// toObservationResponse converts WaterObservation to ObservationResponse with optional timezone formatting
+func toObservationResponse(observation models.WaterObservation, timezone string) ObservationResponse {
+	return ObservationResponse{
+		ID:         observation.ID,
+		HomeID:     observation.HomeID,
+		Location:   observation.Location,
+		ObservedAt: basetraits.FormatTimeWithTimezone(observation.ObservedAt, timezone),
+		LevelM:     observation.LevelM,
+		Flooded:    observation.Flooded,
+		PhotoURL:   observation.PhotoURL,
+		Note:       observation.Note,
+		CreatedAt:  basetraits.FormatTimeWithTimezone(observation.CreatedAt, timezone),
+	}
+}
+
+// ObservationIndex lists the water level observations, latest first
+// Filters: home (name), location (tide station)
+func ObservationIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		page, limit, offset := parsePagination(r)
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+
+		query := app.DB.Model(&models.WaterObservation{})
+
+		if homeFilter := r.URL.Query().Get("home"); homeFilter != "" {
+			home, err := findHome(app, homeFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query = query.Where("home_id = ?", home.ID)
+		}
+
+		if locationFilter := r.URL.Query().Get("location"); locationFilter != "" {
+			station, err := findStation(app, locationFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query = query.Where("location = ?", station.Name)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var observations []models.WaterObservation
+		if err := query.Order("observed_at DESC").Offset(offset).Limit(limit).Find(&observations).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		responses := make([]ObservationResponse, len(observations))
+		for i, observation := range observations {
+			responses[i] = toObservationResponse(observation, timezone)
+		}
+
+		basetraits.WritePaginatedResponse(w, responses, newPagination(page, limit, total))
+	}
+}
+
+// ObservationCreate records a water level observation. A location naming a home ties the observation
+// to the home and its station, any other location picks the tide station.
+func ObservationCreate(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		var request ObservationRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+			return
+		}
+
+		if request.LevelM == nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, "level_m is required")
+			return
+		}
+
+		observation := models.WaterObservation{
+			ObservedAt: time.Now().UTC(),
+			LevelM:     *request.LevelM,
+			Flooded:    request.Flooded,
+			PhotoURL:   strings.TrimSpace(request.PhotoURL),
+			Note:       request.Note,
+		}
+
+		if request.ObservedAt != nil {
+			observation.ObservedAt = request.ObservedAt.UTC()
+			if observation.ObservedAt.After(time.Now().UTC().Add(observationClockSkew)) {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "observed_at must not be in the future")
+				return
+			}
+		}
+
+		if observation.PhotoURL != "" {
+			parsed, err := url.Parse(observation.PhotoURL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "photo_url must be an http(s) URL")
+				return
+			}
+		}
+
+		// Only a location that is not a home picks the tide station, a failed lookup is not a miss
+		home, err := findHome(app, request.Location)
+		if err != nil && !errors.Is(err, errUnknownHome) {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if home != nil {
+			observation.HomeID = home.ID
+			observation.Location = floodrisk.HomeStation(app.Cfg, *home).Name
+		} else {
+			station, err := findStation(app, request.Location)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			observation.Location = station.Name
+		}
+
+		if err := app.DB.Create(&observation).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		traits.WriteJSONResponse(w, http.StatusCreated, toObservationResponse(observation, timezone))
+	}
+}
+
+// ObservationCalibration compares the observations of every home (or the home parameter) with the tide
+// predictions of its station, and suggests the flood threshold separating flooded from dry observations
+func ObservationCalibration(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+
+		var homes []models.Home
+		if homeFilter := r.URL.Query().Get("home"); homeFilter != "" {
+			home, err := findHome(app, homeFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			homes = append(homes, *home)
+		} else if err := app.DB.Order("name ASC").Find(&homes).Error; err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		responses := make([]CalibrationResponse, 0, len(homes))
+		for _, home := range homes {
+			var observations []models.WaterObservation
+			if err := app.DB.Where("home_id = ?", home.ID).Order("observed_at ASC").Find(&observations).Error; err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if len(observations) == 0 {
+				continue
+			}
+
+			station := floodrisk.HomeStation(app.Cfg, home).Name
+			curve, err := floodrisk.LoadTideCurve(app.DB, station, observations[0].ObservedAt, observations[len(observations)-1].ObservedAt)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			calibration := floodrisk.Calibrate(curve, observations)
+			responses = append(responses, toCalibrationResponse(app, home, station, calibration, timezone))
+		}
+
+		traits.WriteJSONResponse(w, http.StatusOK, responses)
+	}
+}
+
+// toCalibrationResponse converts a Calibration of a home to CalibrationResponse with optional timezone formatting
+func toCalibrationResponse(app *application.Application, home models.Home, station string, calibration floodrisk.Calibration, timezone string) CalibrationResponse {
+	response := CalibrationResponse{
+		Home:                home.Name,
+		Location:            station,
+		CurrentThresholdM:   home.FloodThresholdM,
+		SuggestedThresholdM: calibration.SuggestedThresholdM,
+		Observations:        len(calibration.Points),
+		Flooded:             calibration.Flooded,
+		Skipped:             calibration.Skipped,
+		Conflicts:           calibration.Conflicts,
+		LowestFloodedM:      roundLevelPtr(calibration.LowestFloodedM),
+		HighestDryM:         roundLevelPtr(calibration.HighestDryM),
+		MeanResidualM:       roundLevel(calibration.MeanResidualM),
+		RMSResidualM:        roundLevel(calibration.RMSResidualM),
+		Points:              make([]CalibrationPointResponse, len(calibration.Points)),
+	}
+	if response.CurrentThresholdM <= 0 {
+		response.CurrentThresholdM = app.RiskRules.MinTideHeight()
+	}
+
+	for i, point := range calibration.Points {
+		response.Points[i] = CalibrationPointResponse{
+			ObservationID: point.Observation.ID,
+			ObservedAt:    basetraits.FormatTimeWithTimezone(point.Observation.ObservedAt, timezone),
+			LevelM:        point.Observation.LevelM,
+			PredictedM:    roundLevel(point.PredictedM),
+			ResidualM:     roundLevel(point.ResidualM),
+			Flooded:       point.Observation.Flooded,
+		}
+	}
+
+	return response
+}
+
+// roundLevel rounds a water level to millimetres for the responses
+func roundLevel(level float64) float64 {
+	return math.Round(level*1000) / 1000
+}
+
+// roundLevelPtr rounds an optional water level to millimetres
+func roundLevelPtr(level *float64) *float64 {
+	if level == nil {
+		return nil
+	}
+	rounded := roundLevel(*level)
+	return &rounded
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+)
+
+// parsePagination reads the page and limit query parameters and returns page, limit and offset
+func parsePagination(r *http.Request) (int, int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	// Set defaults
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	return page, limit, (page - 1) * limit
+}
+
+// newPagination builds the pagination envelope for the given total number of records
+func newPagination(page, limit int, total int64) basetraits.Pagination {
+	// Calculate total pages
+	totalPages := int(total) / limit
+	if int(total)%limit > 0 {
+		totalPages++
+	}
+
+	return basetraits.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: totalPages,
+	}
+}
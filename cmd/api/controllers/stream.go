@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/events"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+
+	"go.uber.org/zap"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+// Stream sends alert.created, alert.updated, tide.updated and risk.changed as server-sent events.
+// Clients resume with the Last-Event-ID header (or last_event_id parameter) from the buffered events.
+func Stream(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, "streaming not supported")
+			return
+		}
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+
+		var lastID uint64
+		if lastEventID != "" {
+			parsed, err := strconv.ParseUint(lastEventID, 10, 64)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "invalid Last-Event-ID")
+				return
+			}
+			lastID = parsed
+		}
+
+		missed, ch, unsubscribe := app.Events.Subscribe(lastID)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		// Ask clients to wait a little before reconnecting
+		fmt.Fprint(w, "retry: 5000\n\n")
+		for _, event := range missed {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-ch:
+				if !ok {
+					// Dropped for being too slow, the client resumes from the buffer
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes a single event in the server-sent events format
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		zap.S().Errorf("Failed to encode %s event: %v", event.Type, err)
+		return nil
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+)
+
+// TideDataResponse is the response DTO for tide data
+type TideDataResponse struct {
+	ID        string          `json:"id"`
+	Location  string          `json:"location"`
+	Date      string          `json:"date"`
+	TideType  models.TideType `json:"tide_type"`
+	TideTime  time.Time       `json:"tide_time"`
+	HeightM   float64         `json:"height_m"`
+	HeightFt  float64         `json:"height_ft"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// NextTideResponse is the response DTO for the next high and low tide of a station
+type NextTideResponse struct {
+	Location string            `json:"location"`
+	High     *TideDataResponse `json:"high"`
+	Low      *TideDataResponse `json:"low"`
+}
+
+// TideLevelResponse is the response DTO for the predicted water level of a station at a moment
+type TideLevelResponse struct {
+	Location string           `json:"location"`
+	At       time.Time        `json:"at"`
+	HeightM  float64          `json:"height_m"`
+	Trend    string           `json:"trend"` // "rising" or "falling"
+	Previous TideDataResponse `json:"previous"`
+	Next     TideDataResponse `json:"next"`
+}
+
+// toTideResponse converts TideData to TideDataResponse with optional timezone formatting
+func toTideResponse(data models.TideData, timezone string) TideDataResponse {
+	return TideDataResponse{
+		ID:        data.ID,
+		Location:  data.Location,
+		Date:      data.Date.Format("2006-01-02"),
+		TideType:  data.TideType,
+		TideTime:  basetraits.FormatTimeWithTimezone(data.TideTime, timezone),
+		HeightM:   data.HeightM,
+		HeightFt:  data.HeightFt,
+		CreatedAt: basetraits.FormatTimeWithTimezone(data.CreatedAt, timezone),
+		UpdatedAt: basetraits.FormatTimeWithTimezone(data.UpdatedAt, timezone),
+	}
+}
+
+// loadTimezone returns the location for an IANA timezone name, falling back to UTC
+func loadTimezone(timezone string) *time.Location {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseTimeParam parses a query parameter as RFC3339 timestamp or as a plain date (2006-01-02) in loc.
+// The returned bool reports whether a plain date was given.
+func parseTimeParam(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), false, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid time '%s', expected RFC3339 or YYYY-MM-DD", value)
+	}
+	return t.UTC(), true, nil
+}
+
+// TideIndex lists the stored tide data
+// Filters: location (resolved to its tide station like /tides/next and /tides/level),
+// from, to (RFC3339 or YYYY-MM-DD, inclusive), type (high/low), min_height (meters)
+func TideIndex(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		// Parse pagination parameters
+		page, limit, offset := parsePagination(r)
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		locationFilter := r.URL.Query().Get("location")
+		fromFilter := r.URL.Query().Get("from")
+		toFilter := r.URL.Query().Get("to")
+		typeFilter := r.URL.Query().Get("type")
+		minHeightFilter := r.URL.Query().Get("min_height")
+
+		loc := loadTimezone(timezone)
+		query := app.DB.Model(&models.TideData{})
+
+		if locationFilter != "" {
+			station, err := findStation(app, locationFilter)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query = query.Where("location = ?", station.Name)
+		}
+
+		if fromFilter != "" {
+			from, _, err := parseTimeParam(fromFilter, loc)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			query = query.Where("tide_time >= ?", from)
+		}
+
+		if toFilter != "" {
+			to, isDate, err := parseTimeParam(toFilter, loc)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			// A plain date includes the whole day
+			if isDate {
+				query = query.Where("tide_time < ?", to.AddDate(0, 0, 1))
+			} else {
+				query = query.Where("tide_time <= ?", to)
+			}
+		}
+
+		if typeFilter != "" {
+			tideType := models.TideType(typeFilter)
+			if tideType != models.TideTypeHigh && tideType != models.TideTypeLow {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "type must be 'high' or 'low'")
+				return
+			}
+			query = query.Where("tide_type = ?", tideType)
+		}
+
+		if minHeightFilter != "" {
+			minHeight, err := strconv.ParseFloat(minHeightFilter, 64)
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, "min_height must be a number")
+				return
+			}
+			query = query.Where("height_m >= ?", minHeight)
+		}
+
+		var total int64
+		query.Count(&total)
+
+		var tideData []models.TideData
+		result := query.Order("tide_time ASC").
+			Offset(offset).
+			Limit(limit).
+			Find(&tideData)
+
+		if result.Error != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, result.Error.Error())
+			return
+		}
+
+		responses := make([]TideDataResponse, len(tideData))
+		for i, data := range tideData {
+			responses[i] = toTideResponse(data, timezone)
+		}
+
+		basetraits.WritePaginatedResponse(w, responses, newPagination(page, limit, total))
+	}
+}
+
+// TideNext returns the next high and low tide of a station
+// The station is picked from the location parameter, defaulting to the first configured station
+func TideNext(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		station, err := findStation(app, r.URL.Query().Get("location"))
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		now := time.Now().UTC()
+
+		response := NextTideResponse{Location: station.Name}
+
+		for _, tideType := range []models.TideType{models.TideTypeHigh, models.TideTypeLow} {
+			var tide models.TideData
+			result := app.DB.Where("location = ? AND tide_type = ? AND tide_time >= ?", station.Name, tideType, now).
+				Order("tide_time ASC").
+				Limit(1).
+				Find(&tide)
+
+			if result.Error != nil {
+				basetraits.WriteErrorResponse(w, http.StatusInternalServerError, result.Error.Error())
+				return
+			}
+
+			// No upcoming tide of this type stored yet
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			tideResponse := toTideResponse(tide, timezone)
+			if tideType == models.TideTypeHigh {
+				response.High = &tideResponse
+			} else {
+				response.Low = &tideResponse
+			}
+		}
+
+		traits.WriteJSONResponse(w, http.StatusOK, response)
+	}
+}
+
+// TideLevel returns the predicted water level of a station at a moment, interpolated between the stored tides
+// The moment is the at parameter (RFC3339 or YYYY-MM-DD), defaulting to now. The station is picked from the location parameter.
+func TideLevel(app *application.Application) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		timezone := parseTimezone(r.URL.Query().Get("timezone"))
+		station, err := findStation(app, r.URL.Query().Get("location"))
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		at := time.Now().UTC()
+		if value := r.URL.Query().Get("at"); value != "" {
+			parsed, _, err := parseTimeParam(value, loadTimezone(timezone))
+			if err != nil {
+				basetraits.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			at = parsed
+		}
+
+		curve, err := floodrisk.LoadTideCurve(app.DB, station.Name, at, at)
+		if err != nil {
+			basetraits.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		height, ok := curve.Level(at)
+		if !ok {
+			basetraits.WriteErrorResponse(w, http.StatusNotFound,
+				fmt.Sprintf("no tide data around %s for %s", at.Format(time.RFC3339), station.Name))
+			return
+		}
+		previous, next, _ := curve.Around(at)
+
+		trend := "rising"
+		if next.HeightM < previous.HeightM {
+			trend = "falling"
+		}
+
+		traits.WriteJSONResponse(w, http.StatusOK, TideLevelResponse{
+			Location: station.Name,
+			At:       basetraits.FormatTimeWithTimezone(at, timezone),
+			HeightM:  math.Round(height*100) / 100,
+			Trend:    trend,
+			Previous: toTideResponse(previous, timezone),
+			Next:     toTideResponse(next, timezone),
+		})
+	}
+}
+
+// findStation returns the tide station named by a location parameter, the first configured station when empty.
+// Unlike the station of an alert, an unknown location does not fall back to another station.
+func findStation(app *application.Application, location string) (config.TideStation, error) {
+	if strings.TrimSpace(location) == "" {
+		return app.Cfg.GetTideStations()[0], nil
+	}
+
+	station, ok := app.Cfg.FindTideStation(location)
+	if !ok {
+		return config.TideStation{}, fmt.Errorf("unknown location '%s'", location)
+	}
+	return station, nil
+}
@@ -4,15 +4,42 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/shadowbane/home-tidal-flood-warning/pkg/application"
 
-	// Import controllers directly from weather-alert
-	alertcontroller "github.com/shadowbane/home-tidal-flood-warning/cmd/api/controllers"
+	"github.com/shadowbane/home-tidal-flood-warning/cmd/api/controllers"
 )
 
 func Api(app *application.Application) *httprouter.Router {
 	mux := httprouter.New()
 
-	// Weather Alerts (from BMKG) - using weather-alert controllers directly
-	mux.GET("/api/v1/alerts", alertcontroller.Index(app.Application))
+	// Weather Alerts (from BMKG)
+	mux.GET("/api/v1/alerts", controllers.Index(app))
+
+	// Tide Data (from the configured tide sources)
+	mux.GET("/api/v1/tides", controllers.TideIndex(app))
+	mux.GET("/api/v1/tides/next", controllers.TideNext(app))
+	mux.GET("/api/v1/tides/level", controllers.TideLevel(app))
+
+	// Sea level anomalies (observed minus predicted), added to the predicted tides while valid
+	mux.GET("/api/v1/tides/anomalies", controllers.AnomalyIndex(app))
+	mux.POST("/api/v1/tides/anomalies", controllers.AnomalyCreate(app))
+
+	// Flood Risk timeline (tides and alerts)
+	mux.GET("/api/v1/flood-risk", controllers.FloodRisk(app))
+	mux.GET("/api/v1/flood-risk/assessments", controllers.AssessmentIndex(app))
+
+	// Homes, each with its own tide station and flood threshold
+	mux.GET("/api/v1/homes", controllers.HomeIndex(app))
+	mux.POST("/api/v1/homes", controllers.HomeCreate(app))
+	mux.GET("/api/v1/homes/:id", controllers.HomeShow(app))
+	mux.PUT("/api/v1/homes/:id", controllers.HomeUpdate(app))
+	mux.DELETE("/api/v1/homes/:id", controllers.HomeDelete(app))
+
+	// Water level observations, compared with the tide predictions to calibrate the home flood thresholds
+	mux.GET("/api/v1/observations", controllers.ObservationIndex(app))
+	mux.POST("/api/v1/observations", controllers.ObservationCreate(app))
+	mux.GET("/api/v1/observations/calibration", controllers.ObservationCalibration(app))
+
+	// Live alert, tide and risk events (server-sent events)
+	mux.GET("/api/v1/stream", controllers.Stream(app))
 
 	return mux
 }
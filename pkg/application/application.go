@@ -1,13 +1,23 @@
 package application
 
 import (
+	"time"
+
 	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/events"
 	"github.com/shadowbane/home-tidal-flood-warning/pkg/fetcher"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/homeassistant"
 	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/mqttclient"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/notifier"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/sensor"
+	traits "github.com/shadowbane/home-tidal-flood-warning/pkg/traits/controller-traits"
 	baseapp "github.com/shadowbane/weather-alert/pkg/application"
 	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
 
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type Application struct {
@@ -19,6 +29,33 @@ type Application struct {
 
 	// Additional fetchers for this app
 	TidalFetcher *fetcher.TidalFloodFetcher
+
+	// Flood risk rules, validated at startup
+	RiskRules *floodrisk.RuleSet
+
+	// High tides kept in memory for risk evaluation, invalidated after every tide fetch
+	Tides *floodrisk.TideCache
+
+	// Stores flood risk assessments after every fetch
+	Assessor *floodrisk.Assessor
+
+	// Notifies flood risk transitions after every fetch
+	Notifier *notifier.Notifier
+
+	// Publishes alert, tide and risk events to the stream subscribers
+	Events *events.Broker
+
+	// Card themes, built-in and user-supplied
+	Themes traits.ThemeSet
+
+	// MQTT connection, nil when no broker is configured
+	MQTT *mqttclient.Client
+
+	// Water level sensors subscribed on MQTT, nil without broker or sensors
+	Sensors *sensor.Subscriber
+
+	// Publishes the flood risk of every home to Home Assistant after every fetch, nil without broker or discovery prefix
+	HomeAssistant *homeassistant.Publisher
 }
 
 func Start() (*Application, error) {
@@ -33,8 +70,16 @@ func Start() (*Application, error) {
 
 	zap.S().Info("Extending with Home Tidal Flood Warning")
 
+	// Load and validate the flood risk rules
+	riskRules, err := floodrisk.LoadRuleSet(cfg.GetRiskRulesFile())
+	if err != nil {
+		return nil, err
+	}
+	zap.S().Infof("Loaded %d flood risk rules (version %s)", len(riskRules.Rules), riskRules.Version)
+
 	// Replace the base BMKG fetcher with our custom filtered version
-	baseApp.Fetcher = fetcher.NewBMKGFetcher(baseApp.DB)
+	bmkgFetcher := fetcher.NewBMKGFetcher(baseApp.DB, cfg.GetProvinces())
+	baseApp.Fetcher = bmkgFetcher
 
 	// Run additional migrations for tidal flood models
 	zap.S().Debug("Running additional migrations")
@@ -44,19 +89,132 @@ func Start() (*Application, error) {
 		&weathermodels.AlertDetail{},
 		// Tidal flood models (local)
 		&models.TideData{},
+		&models.RiskNotificationState{},
+		&models.FloodRiskAssessment{},
+		&models.Home{},
+		&models.SeaLevelAnomaly{},
+		&models.WaterObservation{},
+		&models.SensorReading{},
 	}...)
 	if err != nil {
 		zap.S().Fatalf("Error running auto migration: %v", err)
 		panic(err)
 	}
 
+	// Assessments are unique per home now, the old index would reject a second home on the same station
+	if baseApp.DB.Migrator().HasIndex(&models.FloodRiskAssessment{}, models.FloodRiskAssessmentLegacyIndex) {
+		if err := baseApp.DB.Migrator().DropIndex(&models.FloodRiskAssessment{}, models.FloodRiskAssessmentLegacyIndex); err != nil {
+			zap.S().Fatalf("Error dropping legacy flood risk assessment index: %v", err)
+			panic(err)
+		}
+	}
+
+	// Register the homes configured in the environment
+	registerHomes(baseApp.DB, cfg.GetHomes())
+
+	// Initialize tide sources, tried in the configured order
+	tideSources := make([]fetcher.TideSource, 0)
+	for _, name := range cfg.GetTideSources() {
+		source, err := fetcher.NewTideSource(name, cfg.GetTideStations())
+		if err != nil {
+			zap.S().Warnf("Skipping tide source: %v", err)
+			continue
+		}
+		tideSources = append(tideSources, source)
+	}
+
 	// Initialize tidal flood fetcher
-	tidalFetcher := fetcher.NewTidalFloodFetcher(baseApp.DB)
+	tidalFetcher := fetcher.NewTidalFloodFetcher(baseApp.DB, cfg.GetTideStations(), tideSources...)
+
+	// Drop the cached tides once new tide data is committed, before the other hooks evaluate risk
+	tideCache := floodrisk.NewTideCache(baseApp.DB)
+	tidalFetcher.OnStored(tideCache.Invalidate)
+
+	// Store the flood risk assessments of the alerts after every fetch
+	assessor := floodrisk.NewAssessor(baseApp.DB, cfg, riskRules, tideCache)
+	bmkgFetcher.OnStored(assessor.Run)
+	tidalFetcher.OnStored(assessor.Run)
+
+	// Live events for the server-sent events stream
+	broker := events.NewBroker(cfg.GetStreamBufferSize())
+	bmkgFetcher.OnAlertDetailsChanged(func(created, updated []weathermodels.AlertDetail) {
+		for _, detail := range created {
+			broker.Publish(events.TypeAlertCreated, events.NewAlertData(detail))
+		}
+		for _, detail := range updated {
+			broker.Publish(events.TypeAlertUpdated, events.NewAlertData(detail))
+		}
+	})
+	tidalFetcher.OnStored(func() {
+		locations := make([]string, 0)
+		for _, station := range cfg.GetTideStations() {
+			locations = append(locations, station.Name)
+		}
+		broker.Publish(events.TypeTideUpdated, events.TideData{Locations: locations, UpdatedAt: time.Now().UTC()})
+	})
+
+	// Recompute flood risk after every fetch and notify level transitions, the stream is always a sink
+	sinks := append(notifier.NewSinks(cfg.GetNotifyConfig()), notifier.NewStreamSink(broker))
+	riskNotifier := notifier.New(baseApp.DB, cfg, riskRules, tideCache, sinks...)
+	bmkgFetcher.OnStored(riskNotifier.Evaluate)
+	tidalFetcher.OnStored(riskNotifier.Evaluate)
+
+	// Store the water level sensor readings and stream them
+	mqttClient := mqttclient.New(cfg.GetMQTTConfig())
+	sensors := sensor.New(baseApp.DB, cfg, riskRules, mqttClient)
+	if sensors != nil {
+		sensors.OnReading(func(reading models.SensorReading) {
+			broker.Publish(events.TypeSensorReading, events.NewSensorReadingData(reading))
+		})
+	}
+
+	// Publish the flood risk of every home to Home Assistant after every fetch and sensor reading
+	homeAssistant := homeassistant.New(baseApp.DB, cfg, riskRules, tideCache, assessor, mqttClient)
+	if homeAssistant != nil {
+		bmkgFetcher.OnStored(homeAssistant.Publish)
+		tidalFetcher.OnStored(homeAssistant.Publish)
+		// The water level entity follows every reading, the MQTT handler must not block on the publishes
+		if sensors != nil {
+			sensors.OnReading(func(reading models.SensorReading) {
+				go homeAssistant.PublishHome(reading.HomeID)
+			})
+		}
+	}
+
+	// Flooding observed by a sensor overrides the predicted risk, re-evaluate as soon as it starts or ends.
+	// The MQTT handler must not block on the publishes of the evaluation.
+	if sensors != nil {
+		sensors.OnFloodedChange(func(models.SensorReading) {
+			go func() {
+				assessor.Run()
+				riskNotifier.Evaluate()
+				if homeAssistant != nil {
+					homeAssistant.Publish()
+				}
+			}()
+		})
+	}
+
+	// Load the card themes
+	themes, err := traits.LoadThemes(cfg.GetCardThemesDir())
+	if err != nil {
+		zap.S().Warnf("Failed to load card themes from %s: %v", cfg.GetCardThemesDir(), err)
+	}
+	zap.S().Infof("Loaded %d card themes", len(themes))
 
 	app := &Application{
-		Application:  baseApp,
-		Cfg:          cfg,
-		TidalFetcher: tidalFetcher,
+		Application:   baseApp,
+		Cfg:           cfg,
+		TidalFetcher:  tidalFetcher,
+		RiskRules:     riskRules,
+		Tides:         tideCache,
+		Assessor:      assessor,
+		Notifier:      riskNotifier,
+		Events:        broker,
+		Themes:        themes,
+		MQTT:          mqttClient,
+		Sensors:       sensors,
+		HomeAssistant: homeAssistant,
 	}
 
 	return app, nil
@@ -68,6 +226,10 @@ func (app *Application) StartBackgroundJobs() {
 	app.Application.StartBackgroundJobs()
 	// Start tidal flood fetcher with its own interval
 	app.TidalFetcher.StartPeriodicFetch(app.Cfg.GetTidalFetchInterval())
+	// Connect to the MQTT broker, the water level sensors are subscribed on every connection
+	if app.MQTT != nil {
+		app.MQTT.Start()
+	}
 }
 
 // StopBackgroundJobs stops all background jobs
@@ -76,4 +238,38 @@ func (app *Application) StopBackgroundJobs() {
 	app.Application.StopBackgroundJobs()
 	// Stop tidal flood fetcher
 	app.TidalFetcher.Stop()
+	// Disconnect from the MQTT broker
+	if app.MQTT != nil {
+		app.MQTT.Stop()
+	}
+}
+
+// registerHomes creates the configured homes missing from the database, existing homes are left untouched
+func registerHomes(db *gorm.DB, homes []config.Home) {
+	for _, configured := range homes {
+		var count int64
+		if err := db.Model(&models.Home{}).Where("name = ?", configured.Name).Count(&count).Error; err != nil {
+			zap.S().Errorf("Failed to look up home %s: %v", configured.Name, err)
+			continue
+		}
+		if count > 0 {
+			continue
+		}
+
+		home := models.Home{
+			Name:      configured.Name,
+			Latitude:  configured.Location.Lat,
+			Longitude: configured.Location.Lon,
+			Keywords:  []string{},
+		}
+		if configured.Area != nil {
+			home.Area = configured.Area.String()
+		}
+
+		if err := db.Create(&home).Error; err != nil {
+			zap.S().Errorf("Failed to register home %s: %v", configured.Name, err)
+			continue
+		}
+		zap.S().Infof("Registered home %s", home.Name)
+	}
 }
@@ -1,29 +1,232 @@
 package config
 
 import (
+	"encoding/json"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/geo"
 	baseconfig "github.com/shadowbane/weather-alert/pkg/config"
+	"go.uber.org/zap"
 )
 
+// WorldTidesStationURL is the base URL used when a station is configured by slug
+const WorldTidesStationURL = "https://www.worldtides.info/tidestations/"
+
+// defaultProvinces keeps the original Kep. Riau province when nothing is configured
+const defaultProvinces = "Kep. Riau"
+
+// defaultTideStations keeps the original Sekupang station when nothing is configured
+const defaultTideStations = "Sekupang|Sekupang|Asia/Jakarta"
+
+// TideStation is a tide station to fetch and store tide data for
+type TideStation struct {
+	Name     string
+	URL      string
+	Timezone *time.Location
+	// Areas are keywords matched against alert locations to pick this station
+	Areas []string
+	// Harmonics are used by the offline harmonic tide source, nil when not configured
+	Harmonics *TideHarmonics
+}
+
+// HarmonicConstituent is a single tidal constituent of a station
+type HarmonicConstituent struct {
+	Name      string  `json:"name"`
+	Amplitude float64 `json:"amplitude_m"`
+	Phase     float64 `json:"phase_deg"`
+	// Speed in degrees per hour, only required for constituents unknown to the predictor
+	Speed float64 `json:"speed_deg_per_hour,omitempty"`
+}
+
+// TideHarmonics holds the harmonic constituents of a station
+type TideHarmonics struct {
+	// Datum is the mean water level above the chart datum used by the tide tables
+	Datum float64 `json:"datum_m"`
+	// Epoch is the reference time the constituent phases are relative to
+	Epoch        time.Time             `json:"epoch"`
+	Constituents []HarmonicConstituent `json:"constituents"`
+}
+
+// Home is a registered home location, matched against the BMKG alert polygons
+type Home struct {
+	Name string
+	// Location is the home point, the center of the area for polygon homes
+	Location geo.Point
+	// Area is the home polygon, nil when the home is a single point
+	Area geo.Polygon
+}
+
+// NotifyConfig holds the flood risk notification settings, a sink is enabled when its target is set
+type NotifyConfig struct {
+	// HorizonHours is how far ahead risk windows are watched
+	HorizonHours int
+	// MinLevel is the lowest risk level worth notifying, lower levels count as cleared
+	MinLevel string
+
+	WebhookURL    string
+	WebhookSecret string
+
+	TelegramBotToken string
+	TelegramChatID   string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	SMTPTo       []string
+}
+
+// Sensor is a water level sensor publishing its readings on an MQTT topic
+type Sensor struct {
+	Topic string
+	// Home is the name of the home the sensor is installed at
+	Home string
+	// OffsetM is added to the readings to convert them to the tide datum of the home flood threshold
+	OffsetM float64
+}
+
+// MQTTConfig holds the MQTT settings of the water level sensors and Home Assistant, disabled when BrokerURL is empty
+type MQTTConfig struct {
+	BrokerURL string
+	ClientID  string
+	Username  string
+	Password  string
+	Sensors   []Sensor
+	// LiveMinutes is how long the latest reading of a home is used by the risk evaluation
+	LiveMinutes int
+	// BaseTopic prefixes the topics published by the service, <base topic>/status is its availability
+	BaseTopic string
+	// DiscoveryPrefix is the Home Assistant discovery prefix, discovery is disabled when empty
+	DiscoveryPrefix string
+}
+
 type Config struct {
 	// Embed the base config
 	*baseconfig.Config
 
+	// Provinces whose BMKG alerts are stored and served
+	provinces []string
+
+	// Home locations matched against the alert polygons
+	homes []Home
+
 	// Tidal flood specific config
 	tidalFetchInterval int
+	tideStations       []TideStation
+	tideSources        []string
+	riskRulesFile      string
+	notify             NotifyConfig
+	streamBufferSize   int
+	cardThemesDir      string
+	mqtt               MQTTConfig
 }
 
 // Extend wraps an existing base config with additional tidal-specific settings
 func Extend(baseCfg *baseconfig.Config) *Config {
+	// Parse BMKG provinces (default: Kep. Riau)
+	provinces := make([]string, 0)
+	for _, province := range strings.Split(getenv("BMKG_PROVINCES", defaultProvinces), ",") {
+		if province = strings.TrimSpace(province); province != "" {
+			provinces = append(provinces, province)
+		}
+	}
+	if len(provinces) == 0 {
+		zap.S().Warnf("No BMKG province configured, falling back to %s", defaultProvinces)
+		provinces = []string{defaultProvinces}
+	}
+
+	// Parse home locations (optional)
+	homes := parseHomes(getenv("HOMES", ""))
+
 	// Parse tidal fetch interval (default: 300 seconds)
 	tidalFetchInterval, _ := strconv.Atoi(getenv("TIDE_DATA_FETCH_INTERVAL", "300"))
 
+	// Parse tide stations (default: Sekupang)
+	tideStations := parseTideStations(getenv("TIDE_STATIONS", defaultTideStations))
+	if len(tideStations) == 0 {
+		zap.S().Warnf("No valid tide station configured, falling back to %s", defaultTideStations)
+		tideStations = parseTideStations(defaultTideStations)
+	}
+
+	// Attach harmonic constituents to the stations (optional)
+	if harmonicsFile := getenv("TIDE_HARMONICS_FILE", ""); harmonicsFile != "" {
+		if err := loadTideHarmonics(harmonicsFile, tideStations); err != nil {
+			zap.S().Warnf("Failed to load tide harmonics from %s: %v", harmonicsFile, err)
+		}
+	}
+
+	// Parse tide sources, tried in order (default: worldtides)
+	tideSources := make([]string, 0)
+	for _, source := range strings.Split(getenv("TIDE_SOURCES", "worldtides"), ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			tideSources = append(tideSources, source)
+		}
+	}
+
+	// Parse notification settings
+	notifyHorizonHours, _ := strconv.Atoi(getenv("NOTIFY_HORIZON_HOURS", "48"))
+	smtpPort, _ := strconv.Atoi(getenv("NOTIFY_SMTP_PORT", "587"))
+	smtpTo := make([]string, 0)
+	for _, to := range strings.Split(getenv("NOTIFY_SMTP_TO", ""), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			smtpTo = append(smtpTo, to)
+		}
+	}
+
+	notify := NotifyConfig{
+		HorizonHours:     notifyHorizonHours,
+		MinLevel:         getenv("NOTIFY_MIN_LEVEL", "moderate"),
+		WebhookURL:       getenv("NOTIFY_WEBHOOK_URL", ""),
+		WebhookSecret:    getenv("NOTIFY_WEBHOOK_SECRET", ""),
+		TelegramBotToken: getenv("NOTIFY_TELEGRAM_BOT_TOKEN", ""),
+		TelegramChatID:   getenv("NOTIFY_TELEGRAM_CHAT_ID", ""),
+		SMTPHost:         getenv("NOTIFY_SMTP_HOST", ""),
+		SMTPPort:         smtpPort,
+		SMTPUsername:     getenv("NOTIFY_SMTP_USERNAME", ""),
+		SMTPPassword:     getenv("NOTIFY_SMTP_PASSWORD", ""),
+		SMTPFrom:         getenv("NOTIFY_SMTP_FROM", ""),
+		SMTPTo:           smtpTo,
+	}
+
+	// Parse the number of events kept for stream resumption (default: 256)
+	streamBufferSize, _ := strconv.Atoi(getenv("STREAM_BUFFER_SIZE", "256"))
+	if streamBufferSize < 1 {
+		streamBufferSize = 256
+	}
+
+	// Parse the water level sensors (optional)
+	mqttLiveMinutes, _ := strconv.Atoi(getenv("MQTT_LIVE_MINUTES", "15"))
+	if mqttLiveMinutes < 1 {
+		mqttLiveMinutes = 15
+	}
+
+	mqtt := MQTTConfig{
+		BrokerURL:       getenv("MQTT_BROKER_URL", ""),
+		ClientID:        getenv("MQTT_CLIENT_ID", "home-tidal-flood-warning"),
+		Username:        getenv("MQTT_USERNAME", ""),
+		Password:        getenv("MQTT_PASSWORD", ""),
+		Sensors:         parseSensors(getenv("MQTT_SENSORS", "")),
+		LiveMinutes:     mqttLiveMinutes,
+		BaseTopic:       strings.Trim(getenv("MQTT_BASE_TOPIC", "home-tidal-flood-warning"), "/"),
+		DiscoveryPrefix: strings.Trim(getenv("MQTT_HA_DISCOVERY_PREFIX", ""), "/"),
+	}
+
 	return &Config{
 		Config:             baseCfg,
+		provinces:          provinces,
+		homes:              homes,
 		tidalFetchInterval: tidalFetchInterval,
+		tideStations:       tideStations,
+		tideSources:        tideSources,
+		riskRulesFile:      getenv("RISK_RULES_FILE", ""),
+		notify:             notify,
+		streamBufferSize:   streamBufferSize,
+		cardThemesDir:      getenv("CARD_THEMES_DIR", ""),
+		mqtt:               mqtt,
 	}
 }
 
@@ -34,6 +237,256 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+// parseTideStations parses a comma separated list of stations.
+// Each station is "name|slug or url|timezone|area;area", areas are optional and default to the name.
+// Example: "Sekupang|Sekupang|Asia/Jakarta|Batam;Sekupang,Tanjung Pinang|Tanjung-Pinang|Asia/Jakarta"
+func parseTideStations(value string) []TideStation {
+	stations := make([]TideStation, 0)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "|")
+		if len(parts) < 3 {
+			zap.S().Warnf("Invalid tide station '%s', expected name|slug|timezone", entry)
+			continue
+		}
+
+		name := strings.TrimSpace(parts[0])
+		url := strings.TrimSpace(parts[1])
+		if name == "" || url == "" {
+			zap.S().Warnf("Invalid tide station '%s', name and slug are required", entry)
+			continue
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			url = WorldTidesStationURL + url
+		}
+
+		timezone, err := time.LoadLocation(strings.TrimSpace(parts[2]))
+		if err != nil {
+			zap.S().Warnf("Invalid timezone for tide station '%s': %v", name, err)
+			continue
+		}
+
+		areas := []string{name}
+		if len(parts) > 3 {
+			areas = make([]string, 0)
+			for _, area := range strings.Split(parts[3], ";") {
+				if area = strings.TrimSpace(area); area != "" {
+					areas = append(areas, area)
+				}
+			}
+		}
+
+		stations = append(stations, TideStation{
+			Name:     name,
+			URL:      url,
+			Timezone: timezone,
+			Areas:    areas,
+		})
+	}
+
+	return stations
+}
+
+// parseHomes parses a semicolon separated list of homes.
+// Each home is "name|lat,lon" for a point or "name|lat,lon lat,lon lat,lon ..." for a polygon.
+// Example: "Rumah|1.1301,104.0529;Kantor|1.12,104.05 1.12,104.06 1.13,104.06 1.13,104.05"
+func parseHomes(value string) []Home {
+	homes := make([]Home, 0)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "|", 2)
+		if len(parts) < 2 || strings.TrimSpace(parts[0]) == "" {
+			zap.S().Warnf("Invalid home '%s', expected name|lat,lon", entry)
+			continue
+		}
+
+		home := Home{Name: strings.TrimSpace(parts[0])}
+		coordinates := strings.TrimSpace(parts[1])
+
+		if len(strings.Fields(coordinates)) == 1 {
+			point, err := geo.ParsePoint(coordinates)
+			if err != nil {
+				zap.S().Warnf("Invalid location for home '%s': %v", home.Name, err)
+				continue
+			}
+			home.Location = point
+		} else {
+			polygons, err := geo.ParsePolygons(coordinates)
+			if err != nil || len(polygons) != 1 {
+				zap.S().Warnf("Invalid area for home '%s': expected a single polygon", home.Name)
+				continue
+			}
+			home.Area = polygons[0]
+			home.Location = home.Area.Center()
+		}
+
+		homes = append(homes, home)
+	}
+
+	return homes
+}
+
+// loadTideHarmonics reads a JSON file of harmonics keyed by station name and attaches them to the stations
+func loadTideHarmonics(path string, stations []TideStation) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	harmonics := make(map[string]*TideHarmonics)
+	if err := json.Unmarshal(content, &harmonics); err != nil {
+		return err
+	}
+
+	for i := range stations {
+		if h, ok := harmonics[stations[i].Name]; ok {
+			stations[i].Harmonics = h
+		}
+	}
+
+	return nil
+}
+
+// parseSensors parses a semicolon separated list of water level sensors.
+// Each sensor is "topic|home" or "topic|home|offset in meters".
+// Example: "home/drain/level|Rumah|-0.35"
+func parseSensors(value string) []Sensor {
+	sensors := make([]Sensor, 0)
+
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "|")
+		if len(parts) < 2 || len(parts) > 3 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			zap.S().Warnf("Invalid sensor '%s', expected topic|home or topic|home|offset", entry)
+			continue
+		}
+
+		sensor := Sensor{Topic: strings.TrimSpace(parts[0]), Home: strings.TrimSpace(parts[1])}
+		if len(parts) == 3 {
+			offset, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+			if err != nil {
+				zap.S().Warnf("Invalid offset for sensor '%s': %v", sensor.Topic, err)
+				continue
+			}
+			sensor.OffsetM = offset
+		}
+
+		sensors = append(sensors, sensor)
+	}
+
+	return sensors
+}
+
+// GetHomes returns the home locations configured in the environment, registered as homes at startup
+func (c *Config) GetHomes() []Home {
+	return c.homes
+}
+
+// GetProvinces returns the provinces whose BMKG alerts are stored and served
+func (c *Config) GetProvinces() []string {
+	return c.provinces
+}
+
+// FindProvince returns the configured province matching name (case-insensitive)
+func (c *Config) FindProvince(name string) (string, bool) {
+	for _, province := range c.provinces {
+		if strings.EqualFold(province, strings.TrimSpace(name)) {
+			return province, true
+		}
+	}
+	return "", false
+}
+
 func (c *Config) GetTidalFetchInterval() time.Duration {
 	return time.Duration(c.tidalFetchInterval) * time.Second
 }
+
+// GetRiskRulesFile returns the path of the JSON risk rules, empty for the built-in rules
+func (c *Config) GetRiskRulesFile() string {
+	return c.riskRulesFile
+}
+
+// GetNotifyConfig returns the flood risk notification settings
+func (c *Config) GetNotifyConfig() NotifyConfig {
+	return c.notify
+}
+
+// GetCardThemesDir returns the directory of the user-supplied card themes, empty for the built-in themes only
+func (c *Config) GetCardThemesDir() string {
+	return c.cardThemesDir
+}
+
+// GetStreamBufferSize returns how many events the stream keeps for clients resuming with Last-Event-ID
+func (c *Config) GetStreamBufferSize() int {
+	return c.streamBufferSize
+}
+
+// GetMQTTConfig returns the water level sensor subscriber settings
+func (c *Config) GetMQTTConfig() MQTTConfig {
+	return c.mqtt
+}
+
+// GetTideSources returns the names of the tide sources, in the order they are tried
+func (c *Config) GetTideSources() []string {
+	return c.tideSources
+}
+
+// GetTideStations returns all configured tide stations
+func (c *Config) GetTideStations() []TideStation {
+	return c.tideStations
+}
+
+// GetTideStationFor returns the first station whose area keywords appear in the given texts
+// (checked in order), falling back to the first configured station.
+// Meant to pick a station from alert descriptions, use FindTideStation for an explicit location.
+func (c *Config) GetTideStationFor(texts ...string) TideStation {
+	for _, text := range texts {
+		if station, ok := c.matchTideStation(text); ok {
+			return station
+		}
+	}
+
+	return c.tideStations[0]
+}
+
+// FindTideStation returns the station named by a location, or whose area keywords appear in it.
+// Reports false when no station matches.
+func (c *Config) FindTideStation(location string) (TideStation, bool) {
+	for _, station := range c.tideStations {
+		if strings.EqualFold(station.Name, strings.TrimSpace(location)) {
+			return station, true
+		}
+	}
+	return c.matchTideStation(location)
+}
+
+// matchTideStation returns the first station whose area keywords appear in the text
+func (c *Config) matchTideStation(text string) (TideStation, bool) {
+	textLower := strings.ToLower(text)
+	if textLower == "" {
+		return TideStation{}, false
+	}
+
+	for _, station := range c.tideStations {
+		for _, area := range station.Areas {
+			if strings.Contains(textLower, strings.ToLower(area)) {
+				return station, true
+			}
+		}
+	}
+	return TideStation{}, false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadTideHarmonics(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	valid := write("valid.json", `{"Sekupang": {"datum_m": 1.6, "epoch": "2025-01-01T00:00:00Z",
+		"constituents": [{"name": "M2", "amplitude_m": 1.2, "phase_deg": 0}]}}`)
+	invalid := write("invalid.json", `{"Sekupang": [`)
+
+	tests := []struct {
+		name          string
+		path          string
+		wantErr       bool
+		wantHarmonics bool
+	}{
+		{"valid", valid, false, true},
+		{"missing", filepath.Join(dir, "missing.json"), true, false},
+		{"invalid", invalid, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stations := []TideStation{{Name: "Sekupang"}, {Name: "Batu Ampar"}}
+
+			err := loadTideHarmonics(tt.path, stations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadTideHarmonics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := stations[0].Harmonics != nil; got != tt.wantHarmonics {
+				t.Errorf("Sekupang has harmonics = %v, want %v", got, tt.wantHarmonics)
+			}
+			if tt.wantHarmonics && len(stations[0].Harmonics.Constituents) != 1 {
+				t.Errorf("Sekupang has %d constituents, want 1", len(stations[0].Harmonics.Constituents))
+			}
+			if stations[1].Harmonics != nil {
+				t.Error("Batu Ampar has harmonics, want none")
+			}
+		})
+	}
+}
+
+func TestTideStationLookup(t *testing.T) {
+	cfg := &Config{tideStations: []TideStation{
+		{Name: "Sekupang", Areas: []string{"Sekupang", "Batam"}},
+		{Name: "Tanjung Pinang", Areas: []string{"Bintan"}},
+	}}
+
+	tests := []struct {
+		name      string
+		location  string
+		want      string
+		wantFound bool
+		fallback  string // station of GetTideStationFor
+	}{
+		{"station name", "Sekupang", "Sekupang", true, "Sekupang"},
+		{"station name case-insensitive", "tanjung pinang", "Tanjung Pinang", true, "Sekupang"},
+		{"area keyword", "Bintan Timur", "Tanjung Pinang", true, "Tanjung Pinang"},
+		{"typo", "Sekupnag", "", false, "Sekupang"},
+		{"empty", "", "", false, "Sekupang"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			station, ok := cfg.FindTideStation(tt.location)
+			if ok != tt.wantFound || station.Name != tt.want {
+				t.Errorf("FindTideStation(%q) = %q, %v, want %q, %v", tt.location, station.Name, ok, tt.want, tt.wantFound)
+			}
+			if got := cfg.GetTideStationFor(tt.location).Name; got != tt.fallback {
+				t.Errorf("GetTideStationFor(%q) = %q, want %q", tt.location, got, tt.fallback)
+			}
+		})
+	}
+}
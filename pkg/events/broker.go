@@ -0,0 +1,116 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event types published on the stream
+const (
+	TypeAlertCreated  = "alert.created"
+	TypeAlertUpdated  = "alert.updated"
+	TypeTideUpdated   = "tide.updated"
+	TypeRiskChanged   = "risk.changed"
+	TypeSensorReading = "sensor.reading"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is dropped
+const subscriberBuffer = 64
+
+// Event is a single published event
+type Event struct {
+	ID   uint64
+	Type string
+	Time time.Time
+	Data interface{}
+}
+
+// Broker fans events out to the subscribers and keeps the latest ones in a ring buffer,
+// so reconnecting clients can resume from their last event ID
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	ring        []Event
+	start       int
+	count       int
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroker creates a new Broker remembering the last size events.
+// IDs start at the current Unix time in milliseconds, so IDs from before a restart are lower
+// and a resuming client receives the whole buffer.
+func NewBroker(size int) *Broker {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Broker{
+		nextID:      uint64(time.Now().UnixMilli()),
+		ring:        make([]Event, size),
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish stores the event in the buffer and sends it to every subscriber.
+// Subscribers too slow to keep up are dropped, they resume from the buffer when reconnecting.
+func (b *Broker) Publish(eventType string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{ID: b.nextID, Type: eventType, Time: time.Now().UTC(), Data: data}
+
+	// Overwrite the oldest event once the buffer is full
+	if b.count < len(b.ring) {
+		b.ring[(b.start+b.count)%len(b.ring)] = event
+		b.count++
+	} else {
+		b.ring[b.start] = event
+		b.start = (b.start + 1) % len(b.ring)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			zap.S().Warn("Dropping slow event stream subscriber")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a subscriber and returns the buffered events published after lastID (0 for none),
+// the channel of new events and the function to unsubscribe. The channel is closed when the subscriber is dropped.
+func (b *Broker) Subscribe(lastID uint64) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	missed := make([]Event, 0)
+	if lastID > 0 {
+		for i := 0; i < b.count; i++ {
+			if event := b.ring[(b.start+i)%len(b.ring)]; event.ID > lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return missed, ch, unsubscribe
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"testing"
+)
+
+// ids returns the IDs of the events
+func ids(events []Event) []uint64 {
+	result := make([]uint64, len(events))
+	for i, event := range events {
+		result[i] = event.ID
+	}
+	return result
+}
+
+func TestBrokerSubscribeResume(t *testing.T) {
+	broker := NewBroker(3)
+
+	// Five events through a buffer of three, the first two are overwritten
+	published := make([]Event, 5)
+	for i := range published {
+		published[i] = broker.Publish(TypeTideUpdated, i)
+	}
+
+	tests := []struct {
+		name   string
+		lastID uint64
+		want   []Event
+	}{
+		{"no last ID", 0, nil},
+		{"before a restart", 1, published[2:]},
+		{"overwritten event", published[0].ID, published[2:]},
+		{"buffered event", published[2].ID, published[3:]},
+		{"latest event", published[4].ID, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missed, _, unsubscribe := broker.Subscribe(tt.lastID)
+			defer unsubscribe()
+
+			got, want := ids(missed), ids(tt.want)
+			if len(got) != len(want) {
+				t.Fatalf("Subscribe(%d) returned %v, want %v", tt.lastID, got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("Subscribe(%d) returned %v, want %v", tt.lastID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBrokerPublishOrder(t *testing.T) {
+	broker := NewBroker(2)
+	_, events, unsubscribe := broker.Subscribe(0)
+	defer unsubscribe()
+
+	first := broker.Publish(TypeAlertCreated, "first")
+	second := broker.Publish(TypeAlertUpdated, "second")
+
+	if second.ID != first.ID+1 {
+		t.Errorf("IDs = %d, %d, want consecutive", first.ID, second.ID)
+	}
+	for _, want := range []Event{first, second} {
+		if got := <-events; got.ID != want.ID || got.Type != want.Type || got.Data != want.Data {
+			t.Errorf("received %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	broker := NewBroker(8)
+	_, slow, unsubscribeSlow := broker.Subscribe(0)
+	_, fast, unsubscribeFast := broker.Subscribe(0)
+	defer unsubscribeFast()
+
+	// The fast subscriber keeps up, the slow one never reads and overflows on the last event
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(TypeSensorReading, i)
+		if event := <-fast; event.Data != i {
+			t.Fatalf("fast subscriber received %v, want %d", event.Data, i)
+		}
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("slow subscriber received %d events before being closed, want %d", received, subscriberBuffer)
+	}
+
+	// Unsubscribing a dropped subscriber is harmless
+	unsubscribeSlow()
+
+	broker.Publish(TypeSensorReading, "after")
+	if event := <-fast; event.Data != "after" {
+		t.Errorf("fast subscriber received %v after the drop, want after", event.Data)
+	}
+}
+
+func TestBrokerUnsubscribe(t *testing.T) {
+	broker := NewBroker(2)
+	_, events, unsubscribe := broker.Subscribe(0)
+
+	unsubscribe()
+	unsubscribe()
+
+	if _, ok := <-events; ok {
+		t.Error("channel open after unsubscribe, want closed")
+	}
+	broker.Publish(TypeTideUpdated, nil)
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// AlertData is the payload of the alert.created and alert.updated events
+type AlertData struct {
+	ID              string    `json:"id"`
+	Event           string    `json:"event"`
+	Headline        string    `json:"headline"`
+	Severity        string    `json:"severity"`
+	Urgency         string    `json:"urgency"`
+	Certainty       string    `json:"certainty"`
+	AreaDescription string    `json:"area_description"`
+	Effective       time.Time `json:"effective"`
+	Expires         time.Time `json:"expires"`
+}
+
+// NewAlertData converts an alert detail to its event payload
+func NewAlertData(detail weathermodels.AlertDetail) AlertData {
+	return AlertData{
+		ID:              detail.ID,
+		Event:           detail.Event,
+		Headline:        detail.Headline,
+		Severity:        detail.Severity,
+		Urgency:         detail.Urgency,
+		Certainty:       detail.Certainty,
+		AreaDescription: detail.AreaDescription,
+		Effective:       detail.Effective,
+		Expires:         detail.Expires,
+	}
+}
+
+// TideData is the payload of the tide.updated event
+type TideData struct {
+	Locations []string  `json:"locations"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SensorReadingData is the payload of the sensor.reading event
+type SensorReadingData struct {
+	Sensor     string    `json:"sensor"`
+	HomeID     string    `json:"home_id"`
+	LevelM     float64   `json:"level_m"`
+	ThresholdM float64   `json:"threshold_m"`
+	Flooded    bool      `json:"flooded"`
+	ReadAt     time.Time `json:"read_at"`
+}
+
+// NewSensorReadingData converts a sensor reading to its event payload
+func NewSensorReadingData(reading models.SensorReading) SensorReadingData {
+	return SensorReadingData{
+		Sensor:     reading.Sensor,
+		HomeID:     reading.HomeID,
+		LevelM:     reading.LevelM,
+		ThresholdM: reading.ThresholdM,
+		Flooded:    reading.Flooded,
+		ReadAt:     reading.ReadAt,
+	}
+}
@@ -11,21 +11,23 @@ import (
 	"gorm.io/gorm"
 )
 
-// ProvinceFilter is the province filter for alerts
-const ProvinceFilter = "Kep. Riau"
-
 // BMKGFetcher wraps the base BMKGFetcher with province filtering
 type BMKGFetcher struct {
 	*basefetcher.BMKGFetcher
-	db       *gorm.DB
-	stopChan chan struct{}
+	db        *gorm.DB
+	provinces []string
+	hooks     []func()
+	// changeHooks receive the alert details created or changed by a fetch
+	changeHooks []func(created, updated []models.AlertDetail)
+	stopChan    chan struct{}
 }
 
-// NewBMKGFetcher creates a new BMKGFetcher with province filtering
-func NewBMKGFetcher(db *gorm.DB) *BMKGFetcher {
+// NewBMKGFetcher creates a new BMKGFetcher keeping only the alerts of the given provinces
+func NewBMKGFetcher(db *gorm.DB, provinces []string) *BMKGFetcher {
 	return &BMKGFetcher{
 		BMKGFetcher: basefetcher.NewBMKGFetcher(db),
 		db:          db,
+		provinces:   provinces,
 		stopChan:    make(chan struct{}),
 	}
 }
@@ -38,16 +40,16 @@ func (f *BMKGFetcher) FetchAndStore() (int, error) {
 		return 0, err
 	}
 
-	// Filter alerts to only those containing the province filter
+	// Filter alerts to only those containing one of the provinces
 	filteredAlerts := make([]models.WeatherAlert, 0)
 	for _, alert := range alerts {
-		if strings.Contains(alert.Province, ProvinceFilter) {
+		if f.matchesProvince(alert.Province) {
 			filteredAlerts = append(filteredAlerts, alert)
 		}
 	}
 
-	zap.S().Infof("Filtered %d alerts to %d alerts for province containing '%s'",
-		len(alerts), len(filteredAlerts), ProvinceFilter)
+	zap.S().Infof("Filtered %d alerts to %d alerts for provinces containing '%s'",
+		len(alerts), len(filteredAlerts), strings.Join(f.provinces, "', '"))
 
 	count := 0
 	storedAlerts := make([]models.WeatherAlert, 0, len(filteredAlerts))
@@ -77,24 +79,117 @@ func (f *BMKGFetcher) FetchAndStore() (int, error) {
 		}
 	}
 
-	zap.S().Infof("Synced %d new alerts from BMKG (filtered for %s)", count, ProvinceFilter)
+	zap.S().Infof("Synced %d new alerts from BMKG (filtered for %s)", count, strings.Join(f.provinces, ", "))
 
 	// Fetch alert details concurrently (max 5 concurrent requests)
 	if len(storedAlerts) > 0 {
 		go func() {
 			zap.S().Infof("Fetching details for %d alerts concurrently", len(storedAlerts))
+			before := f.loadDetails(storedAlerts)
 			results := f.BMKGFetcher.FetchAlertDetailsConcurrently(storedAlerts, 5)
 			detailCount := f.BMKGFetcher.StoreAlertDetails(results)
 			zap.S().Infof("Stored %d alert details", detailCount)
+			f.runChangeHooks(before, storedAlerts)
+			f.runHooks()
 		}()
+	} else {
+		f.runHooks()
 	}
 
 	return count, nil
 }
 
+// matchesProvince reports whether the alert province contains one of the configured provinces
+func (f *BMKGFetcher) matchesProvince(province string) bool {
+	for _, p := range f.provinces {
+		if strings.Contains(province, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnStored registers a hook run after every fetch, once the alert details are stored
+// Hooks must be registered before the periodic fetch starts
+func (f *BMKGFetcher) OnStored(hook func()) {
+	f.hooks = append(f.hooks, hook)
+}
+
+// runHooks runs the registered hooks in order
+func (f *BMKGFetcher) runHooks() {
+	for _, hook := range f.hooks {
+		hook()
+	}
+}
+
+// OnAlertDetailsChanged registers a hook receiving the alert details created or changed by every fetch
+// Hooks must be registered before the periodic fetch starts
+func (f *BMKGFetcher) OnAlertDetailsChanged(hook func(created, updated []models.AlertDetail)) {
+	f.changeHooks = append(f.changeHooks, hook)
+}
+
+// loadDetails returns the stored details of the alerts keyed by ID, nil when no change hook is registered
+func (f *BMKGFetcher) loadDetails(alerts []models.WeatherAlert) map[string]models.AlertDetail {
+	if len(f.changeHooks) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(alerts))
+	for i, alert := range alerts {
+		ids[i] = alert.ID
+	}
+
+	var details []models.AlertDetail
+	if err := f.db.Where("weather_alert_id IN ?", ids).Find(&details).Error; err != nil {
+		zap.S().Errorf("Failed to load alert details: %v", err)
+		return nil
+	}
+
+	byID := make(map[string]models.AlertDetail, len(details))
+	for _, detail := range details {
+		byID[detail.ID] = detail
+	}
+	return byID
+}
+
+// runChangeHooks compares the details of the alerts with the ones loaded before storing and runs the change hooks
+func (f *BMKGFetcher) runChangeHooks(before map[string]models.AlertDetail, alerts []models.WeatherAlert) {
+	if before == nil {
+		return
+	}
+
+	after := f.loadDetails(alerts)
+	created := make([]models.AlertDetail, 0)
+	updated := make([]models.AlertDetail, 0)
+	for id, detail := range after {
+		previous, ok := before[id]
+		if !ok {
+			created = append(created, detail)
+		} else if detailChanged(previous, detail) {
+			updated = append(updated, detail)
+		}
+	}
+
+	if len(created) == 0 && len(updated) == 0 {
+		return
+	}
+
+	for _, hook := range f.changeHooks {
+		hook(created, updated)
+	}
+}
+
+// detailChanged reports whether the content of an alert detail changed, ignoring timestamps of the record
+func detailChanged(a, b models.AlertDetail) bool {
+	return a.Status != b.Status || a.MsgType != b.MsgType || a.Event != b.Event ||
+		a.Severity != b.Severity || a.Urgency != b.Urgency || a.Certainty != b.Certainty ||
+		!a.Effective.Equal(b.Effective) || !a.Expires.Equal(b.Expires) ||
+		a.Headline != b.Headline || a.Description != b.Description || a.Polygon != b.Polygon
+}
+
 // StartPeriodicFetch starts a background goroutine that fetches alerts periodically
 func (f *BMKGFetcher) StartPeriodicFetch(interval time.Duration) {
-	zap.S().Infof("Starting periodic BMKG fetch (filtered for %s) every %v", ProvinceFilter, interval)
+	zap.S().Infof("Starting periodic BMKG fetch (filtered for %s) every %v", strings.Join(f.provinces, ", "), interval)
 
 	// Fetch immediately on start
 	go func() {
@@ -0,0 +1,179 @@
+package fetcher
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// harmonicStep is the sampling interval used to locate the tide extremes
+const harmonicStep = 6 * time.Minute
+
+// metersToFeet converts tide heights from meters to feet
+const metersToFeet = 3.28084
+
+// harmonicSpeeds are the angular speeds (degrees per hour) of the well-known tidal constituents
+var harmonicSpeeds = map[string]float64{
+	"M2":   28.9841042,
+	"S2":   30.0000000,
+	"N2":   28.4397295,
+	"K2":   30.0821373,
+	"2N2":  27.8953548,
+	"MU2":  27.9682084,
+	"NU2":  28.5125831,
+	"L2":   29.5284789,
+	"T2":   29.9589333,
+	"K1":   15.0410686,
+	"O1":   13.9430356,
+	"P1":   14.9589314,
+	"Q1":   13.3986609,
+	"J1":   15.5854433,
+	"M1":   14.4966939,
+	"OO1":  16.1391017,
+	"M4":   57.9682084,
+	"MS4":  58.9841042,
+	"MN4":  57.4238337,
+	"M6":   86.9523127,
+	"SA":   0.0410686,
+	"SSA":  0.0821373,
+	"MM":   0.5443747,
+	"MF":   1.0980331,
+	"MSF":  1.0158958,
+	"2MK3": 42.9271398,
+	"MK3":  44.0251729,
+}
+
+// HarmonicSource predicts tides locally from the harmonic constituents of a station,
+// so tide data keeps flowing without any network access
+type HarmonicSource struct{}
+
+// NewHarmonicSource creates a new HarmonicSource instance.
+// At least one station needs harmonic constituents, and every constituent a known or configured speed.
+func NewHarmonicSource(stations []config.TideStation) (*HarmonicSource, error) {
+	configured := 0
+	for _, station := range stations {
+		if station.Harmonics == nil || len(station.Harmonics.Constituents) == 0 {
+			continue
+		}
+		if _, err := constituentSpeeds(station.Harmonics); err != nil {
+			return nil, fmt.Errorf("%s: %w", station.Name, err)
+		}
+		configured++
+	}
+
+	if configured == 0 {
+		return nil, fmt.Errorf("no station has harmonic constituents, check TIDE_HARMONICS_FILE")
+	}
+
+	return &HarmonicSource{}, nil
+}
+
+// Name returns the source name
+func (s *HarmonicSource) Name() string {
+	return "harmonic"
+}
+
+// FetchTides predicts the high and low tides of the station for the given number of days.
+// Nodal corrections are not applied, so constituents should be fitted for a recent epoch.
+func (s *HarmonicSource) FetchTides(station config.TideStation, days int) ([]models.TideData, error) {
+	harmonics := station.Harmonics
+	if harmonics == nil || len(harmonics.Constituents) == 0 {
+		return nil, fmt.Errorf("no harmonic constituents configured")
+	}
+
+	speeds, err := constituentSpeeds(harmonics)
+	if err != nil {
+		return nil, err
+	}
+
+	// Water level above chart datum at the given time
+	level := func(t time.Time) float64 {
+		hours := t.Sub(harmonics.Epoch).Hours()
+		height := harmonics.Datum
+		for i, constituent := range harmonics.Constituents {
+			angle := (speeds[i]*hours - constituent.Phase) * math.Pi / 180
+			height += constituent.Amplitude * math.Cos(angle)
+		}
+		return height
+	}
+
+	now := time.Now().In(station.Timezone)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, station.Timezone)
+	end := start.AddDate(0, 0, days)
+
+	tideData := make([]models.TideData, 0)
+
+	// Walk the curve and keep every local maximum (high tide) and minimum (low tide)
+	prev := level(start.Add(-harmonicStep))
+	cur := level(start)
+	for t := start; t.Before(end); t = t.Add(harmonicStep) {
+		next := level(t.Add(harmonicStep))
+
+		var tideType models.TideType
+		switch {
+		case cur > prev && cur >= next:
+			tideType = models.TideTypeHigh
+		case cur < prev && cur <= next:
+			tideType = models.TideTypeLow
+		}
+
+		if tideType != "" {
+			offset, height := refineExtreme(prev, cur, next)
+			tideTime := t.Add(time.Duration(offset * float64(harmonicStep))).Round(time.Minute)
+
+			// Skip extremes refined outside of the requested days, they belong to a date we don't replace
+			if !tideTime.Before(start) && tideTime.Before(end) {
+				local := tideTime.In(station.Timezone)
+				tideData = append(tideData, models.TideData{
+					Location: station.Name,
+					Date:     time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC),
+					TideType: tideType,
+					TideTime: tideTime.UTC(),
+					HeightM:  math.Round(height*100) / 100,
+					HeightFt: math.Round(height*metersToFeet*100) / 100,
+				})
+			}
+		}
+
+		prev, cur = cur, next
+	}
+
+	if len(tideData) == 0 {
+		return nil, fmt.Errorf("no tide extremes predicted")
+	}
+
+	return tideData, nil
+}
+
+// constituentSpeeds returns the angular speed of every constituent, the configured one or the well-known one
+func constituentSpeeds(harmonics *config.TideHarmonics) ([]float64, error) {
+	speeds := make([]float64, len(harmonics.Constituents))
+	for i, constituent := range harmonics.Constituents {
+		speed := constituent.Speed
+		if speed == 0 {
+			known, ok := harmonicSpeeds[strings.ToUpper(constituent.Name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown constituent %s, speed_deg_per_hour is required", constituent.Name)
+			}
+			speed = known
+		}
+		speeds[i] = speed
+	}
+	return speeds, nil
+}
+
+// refineExtreme fits a parabola through three equally spaced samples around an extreme.
+// Returns the offset of the vertex from the middle sample (in steps) and its height.
+func refineExtreme(prev, cur, next float64) (float64, float64) {
+	denom := prev - 2*cur + next
+	if denom == 0 {
+		return 0, cur
+	}
+
+	offset := 0.5 * (prev - next) / denom
+	return offset, cur - 0.25*(prev-next)*offset
+}
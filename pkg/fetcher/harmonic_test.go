@@ -0,0 +1,144 @@
+package fetcher
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// pureM2 is a station whose tide is the M2 constituent alone: 1.2m around a 1.6m datum, high at the epoch
+func pureM2() config.TideStation {
+	return config.TideStation{
+		Name:     "Sekupang",
+		Timezone: time.UTC,
+		Harmonics: &config.TideHarmonics{
+			Datum:        1.6,
+			Epoch:        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Constituents: []config.HarmonicConstituent{{Name: "M2", Amplitude: 1.2, Phase: 0}},
+		},
+	}
+}
+
+func TestHarmonicSourcePureM2(t *testing.T) {
+	station := pureM2()
+	epoch := station.Harmonics.Epoch
+
+	source, err := NewHarmonicSource([]config.TideStation{station})
+	if err != nil {
+		t.Fatalf("NewHarmonicSource() error = %v", err)
+	}
+
+	tides, err := source.FetchTides(station, 2)
+	if err != nil {
+		t.Fatalf("FetchTides() error = %v", err)
+	}
+
+	// cos(speed * hours) peaks every period and bottoms out half a period later
+	halfPeriod := time.Duration(180 / harmonicSpeeds["M2"] * float64(time.Hour))
+
+	// Two days hold 3.86 periods, so 7 or 8 alternating extremes
+	if len(tides) < 7 || len(tides) > 8 {
+		t.Fatalf("FetchTides() returned %d extremes, want 7 or 8", len(tides))
+	}
+
+	for i, tide := range tides {
+		// Index of the nearest analytic extreme: even ones are high tides, odd ones low tides
+		n := math.Round(float64(tide.TideTime.Sub(epoch)) / float64(halfPeriod))
+		want := epoch.Add(time.Duration(n * float64(halfPeriod)))
+
+		wantType, wantHeight := models.TideTypeHigh, 2.8
+		if int64(n)%2 != 0 {
+			wantType, wantHeight = models.TideTypeLow, 0.4
+		}
+
+		if tide.TideType != wantType {
+			t.Errorf("tide %d: type = %s, want %s", i, tide.TideType, wantType)
+		}
+		if diff := tide.TideTime.Sub(want); diff < -time.Minute || diff > time.Minute {
+			t.Errorf("tide %d: time = %s, want %s", i, tide.TideTime, want.Round(time.Minute))
+		}
+		if math.Abs(tide.HeightM-wantHeight) > 0.005 {
+			t.Errorf("tide %d: height = %.2fm, want %.2fm", i, tide.HeightM, wantHeight)
+		}
+		if tide.Location != station.Name || tide.TideTime.Location() != time.UTC {
+			t.Errorf("tide %d: location = %s, time zone = %s", i, tide.Location, tide.TideTime.Location())
+		}
+		if i > 0 && tide.TideType == tides[i-1].TideType {
+			t.Errorf("tide %d: two %s tides in a row", i, tide.TideType)
+		}
+	}
+}
+
+func TestRefineExtreme(t *testing.T) {
+	tests := []struct {
+		name       string
+		vertex     float64 // offset of the vertex from the middle sample, in steps
+		height     float64
+		curvature  float64
+		wantOffset float64
+	}{
+		{"high after the middle sample", 0.3, 2.9, -1, 0.3},
+		{"high before the middle sample", -0.45, 2.7, -0.2, -0.45},
+		{"low on the middle sample", 0, 0.4, 0.5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Samples of a parabola at -1, 0 and 1 step
+			sample := func(x float64) float64 {
+				return tt.height + tt.curvature*(x-tt.vertex)*(x-tt.vertex)
+			}
+
+			offset, height := refineExtreme(sample(-1), sample(0), sample(1))
+			if math.Abs(offset-tt.wantOffset) > 1e-9 || math.Abs(height-tt.height) > 1e-9 {
+				t.Errorf("refineExtreme() = %.4f, %.4f, want %.4f, %.4f", offset, height, tt.wantOffset, tt.height)
+			}
+		})
+	}
+
+	// A flat curve keeps the middle sample
+	if offset, height := refineExtreme(1, 1, 1); offset != 0 || height != 1 {
+		t.Errorf("refineExtreme(flat) = %.2f, %.2f, want 0, 1", offset, height)
+	}
+}
+
+func TestNewTideSource(t *testing.T) {
+	unknown := pureM2()
+	unknown.Harmonics.Constituents = []config.HarmonicConstituent{{Name: "X9", Amplitude: 0.1}}
+
+	custom := pureM2()
+	custom.Harmonics.Constituents = []config.HarmonicConstituent{{Name: "X9", Amplitude: 0.1, Speed: 12}}
+
+	tests := []struct {
+		name     string
+		source   string
+		stations []config.TideStation
+		wantErr  bool
+	}{
+		{"worldtides", "worldtides", nil, false},
+		{"harmonic", "harmonic", []config.TideStation{{Name: "Batu Ampar"}, pureM2()}, false},
+		{"harmonic with a custom speed", "harmonic", []config.TideStation{custom}, false},
+		{"harmonic without harmonics file", "harmonic", []config.TideStation{{Name: "Sekupang"}}, true},
+		{"harmonic with empty harmonics", "harmonic", []config.TideStation{{Name: "Sekupang", Harmonics: &config.TideHarmonics{}}}, true},
+		{"harmonic with unknown constituent", "harmonic", []config.TideStation{pureM2(), unknown}, true},
+		{"unknown source", "tidetables", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewTideSource(tt.source, tt.stations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTideSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && source != nil {
+				t.Errorf("NewTideSource() = %v, want nil on error", source)
+			}
+			if !tt.wantErr && source.Name() != tt.source {
+				t.Errorf("NewTideSource() name = %s, want %s", source.Name(), tt.source)
+			}
+		})
+	}
+}
@@ -1,26 +1,19 @@
 package fetcher
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
-	"regexp"
-	"strconv"
-	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
 	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-const (
-	// WorldTidesURL is the URL to scrape tide data from
-	WorldTidesURL = "https://www.worldtides.info/tidestations/Sekupang"
-	// TideLocation is the location name for the tide data
-	TideLocation = "Sekupang"
-)
+// TideForecastDays is the number of forecast days requested from the tide sources
+const TideForecastDays = 7
 
 // UTC+7 timezone
 var wibTimezone = time.FixedZone("WIB", 7*60*60)
@@ -29,48 +22,104 @@ var wibTimezone = time.FixedZone("WIB", 7*60*60)
 // Implements the fetcher.Fetcher interface from weather-alert
 type TidalFloodFetcher struct {
 	db       *gorm.DB
+	stations []config.TideStation
+	sources  []TideSource
+	hooks    []func()
 	stopChan chan struct{}
 }
 
-// NewTidalFloodFetcher creates a new TidalFloodFetcher instance
-func NewTidalFloodFetcher(db *gorm.DB) *TidalFloodFetcher {
+// NewTidalFloodFetcher creates a new TidalFloodFetcher instance for the given stations
+// Sources are tried in order, the next one is only used when the previous one fails
+func NewTidalFloodFetcher(db *gorm.DB, stations []config.TideStation, sources ...TideSource) *TidalFloodFetcher {
 	return &TidalFloodFetcher{
 		db:       db,
+		stations: stations,
+		sources:  sources,
 		stopChan: make(chan struct{}),
 	}
 }
 
-// FetchAndStore fetches tide data and stores it in the database using a transaction
+// FetchAndStore fetches and stores tide data for every configured station.
+// A failing station does not prevent the others from being stored.
+// The hooks run once at least one station committed tide data.
 func (f *TidalFloodFetcher) FetchAndStore() (int, error) {
-	tideData, date, err := f.Fetch()
+	total := 0
+	var errs []error
+
+	for _, station := range f.stations {
+		count, err := f.fetchAndStoreStation(station)
+		if err != nil {
+			zap.S().Errorf("Failed to sync tide data for %s: %v", station.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", station.Name, err))
+			continue
+		}
+		total += count
+	}
+
+	// Nothing changed when no station committed tide data
+	if total > 0 {
+		f.runHooks()
+	}
+
+	return total, errors.Join(errs...)
+}
+
+// OnStored registers a hook run after every fetch of all stations that stored tide data
+// Hooks must be registered before the periodic fetch starts
+func (f *TidalFloodFetcher) OnStored(hook func()) {
+	f.hooks = append(f.hooks, hook)
+}
+
+// runHooks runs the registered hooks in order
+func (f *TidalFloodFetcher) runHooks() {
+	for _, hook := range f.hooks {
+		hook()
+	}
+}
+
+// fetchAndStoreStation fetches tide data for a station and stores it in the database using a transaction
+func (f *TidalFloodFetcher) fetchAndStoreStation(station config.TideStation) (int, error) {
+	tideData, err := f.Fetch(station)
 	if err != nil {
 		return 0, err
 	}
 
 	if len(tideData) == 0 {
-		zap.S().Info("No tide data fetched")
+		zap.S().Infof("No tide data fetched for %s", station.Name)
 		return 0, nil
 	}
 
+	// Group entries per forecast date, keeping the page order of the dates
+	dates := make([]time.Time, 0)
+	tideByDate := make(map[time.Time][]models.TideData)
+	for _, data := range tideData {
+		if _, ok := tideByDate[data.Date]; !ok {
+			dates = append(dates, data.Date)
+		}
+		tideByDate[data.Date] = append(tideByDate[data.Date], data)
+	}
+
 	count := 0
 
-	// Use transaction to replace all data for the date
+	// Use a single transaction to replace the data of every forecast date
 	err = f.db.Transaction(func(tx *gorm.DB) error {
-		// Delete existing data for the same date and location
-		// Note: date is kept in WIB for correct logical date storage
-		if err := tx.Where("location = ? AND date = ?", TideLocation, date).
-			Delete(&models.TideData{}).Error; err != nil {
-			return fmt.Errorf("failed to delete existing tide data: %w", err)
-		}
+		for _, date := range dates {
+			// Delete existing data for the same date and location
+			// Note: date is kept in the station timezone for correct logical date storage
+			if err := tx.Where("location = ? AND date = ?", station.Name, date).
+				Delete(&models.TideData{}).Error; err != nil {
+				return fmt.Errorf("failed to delete existing tide data: %w", err)
+			}
 
-		zap.S().Infof("Deleted existing tide data for %s on %s", TideLocation, date.Format("2006-01-02"))
+			zap.S().Debugf("Deleted existing tide data for %s on %s", station.Name, date.Format("2006-01-02"))
 
-		// Insert new data
-		for _, data := range tideData {
-			if err := tx.Create(&data).Error; err != nil {
-				return fmt.Errorf("failed to insert tide data: %w", err)
+			// Insert new data
+			for _, data := range tideByDate[date] {
+				if err := tx.Create(&data).Error; err != nil {
+					return fmt.Errorf("failed to insert tide data: %w", err)
+				}
+				count++
 			}
-			count++
 		}
 
 		return nil
@@ -80,113 +129,32 @@ func (f *TidalFloodFetcher) FetchAndStore() (int, error) {
 		return 0, err
 	}
 
-	zap.S().Infof("Synced %d tide data entries for %s on %s", count, TideLocation, date.Format("2006-01-02"))
+	zap.S().Infof("Synced %d tide data entries for %s from %s to %s (%d days)", count, station.Name,
+		dates[0].Format("2006-01-02"), dates[len(dates)-1].Format("2006-01-02"), len(dates))
 	return count, nil
 }
 
-// Fetch retrieves and parses tide data from worldtides.info
-func (f *TidalFloodFetcher) Fetch() ([]models.TideData, time.Time, error) {
-	zap.S().Debugf("Fetching tide data from %s", WorldTidesURL)
-
-	resp, err := http.Get(WorldTidesURL)
-	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("failed to fetch tide data: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, time.Time{}, fmt.Errorf("worldtides.info returned status code: %d", resp.StatusCode)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("failed to parse HTML: %w", err)
-	}
-
-	// Parse the date from the header div
-	// Format: "Tide Times for Sekupang: Thursday December 4, 2025 (WIB)"
-	dateText := ""
-	doc.Find("div").Each(func(i int, s *goquery.Selection) {
-		text := s.Text()
-		if strings.Contains(text, "Tide Times for") && strings.Contains(text, "(WIB)") {
-			dateText = text
-		}
-	})
-
-	if dateText == "" {
-		return nil, time.Time{}, fmt.Errorf("could not find tide date header")
-	}
-
-	// dateForStorage: UTC midnight with correct Y/M/D (for DB storage)
-	// dateWIB: WIB midnight (for combining with tide times)
-	dateForStorage, dateWIB, err := parseTideDate(dateText)
-	if err != nil {
-		return nil, time.Time{}, fmt.Errorf("failed to parse tide date: %w", err)
-	}
-
-	zap.S().Debugf("Parsing tide data for date: %s", dateForStorage.Format("2006-01-02"))
-
-	tideData := make([]models.TideData, 0)
-
-	// Parse the tide table
-	doc.Find("table.table-bordered tr").Each(func(i int, s *goquery.Selection) {
-		// Skip header row
-		if i == 0 {
-			return
-		}
-
-		cols := s.Find("td")
-		if cols.Length() != 3 {
-			return
-		}
-
-		tideTypeStr := strings.TrimSpace(cols.Eq(0).Text())
-		timeStr := strings.TrimSpace(cols.Eq(1).Text())
-		heightStr := strings.TrimSpace(cols.Eq(2).Text())
-
-		// Parse tide type
-		var tideType models.TideType
-		if strings.Contains(strings.ToLower(tideTypeStr), "high") {
-			tideType = models.TideTypeHigh
-		} else if strings.Contains(strings.ToLower(tideTypeStr), "low") {
-			tideType = models.TideTypeLow
-		} else {
-			zap.S().Warnf("Unknown tide type: %s", tideTypeStr)
-			return
-		}
-
-		// Parse time using WIB date (for correct hour/minute combination)
-		tideTime, err := parseTimeWIB(dateWIB, timeStr)
-		if err != nil {
-			zap.S().Warnf("Failed to parse tide time '%s': %v", timeStr, err)
-			return
-		}
+// Fetch retrieves the tide forecast of a station from the first source that succeeds
+func (f *TidalFloodFetcher) Fetch(station config.TideStation) ([]models.TideData, error) {
+	var errs []error
 
-		// Parse height (format: "1.1 m (3.6 ft)")
-		heightM, heightFt, err := parseHeight(heightStr)
+	for _, source := range f.sources {
+		tideData, err := source.FetchTides(station, TideForecastDays)
 		if err != nil {
-			zap.S().Warnf("Failed to parse tide height '%s': %v", heightStr, err)
-			return
+			zap.S().Warnf("Tide source %s failed for %s: %v", source.Name(), station.Name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+			continue
 		}
 
-		data := models.TideData{
-			Location: TideLocation,
-			Date:     dateForStorage, // UTC midnight with correct Y/M/D for DB
-			TideType: tideType,
-			TideTime: tideTime, // Converted to UTC in parseTimeWIB for accurate comparisons
-			HeightM:  heightM,
-			HeightFt: heightFt,
-		}
-
-		tideData = append(tideData, data)
-	})
+		zap.S().Debugf("Fetched tide data for %s from %s", station.Name, source.Name())
+		return tideData, nil
+	}
 
-	if len(tideData) == 0 {
-		return nil, time.Time{}, fmt.Errorf("no tide data found in the table")
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no tide source configured")
 	}
 
-	zap.S().Infof("Fetched %d tide entries for %s", len(tideData), dateForStorage.Format("2006-01-02"))
-	return tideData, dateForStorage, nil
+	return nil, errors.Join(errs...)
 }
 
 // StartPeriodicFetch starts a background goroutine that fetches at 2-hour intervals aligned to UTC+7
@@ -251,78 +219,3 @@ func calculateNext2HourMark() time.Time {
 
 	return nextRun
 }
-
-// parseTideDate parses the date from text like "Tide Times for Sekupang: Thursday December 4, 2025 (WIB)"
-// Returns two values: dateForStorage (UTC midnight for DB) and dateWIB (for combining with times)
-func parseTideDate(text string) (dateForStorage time.Time, dateWIB time.Time, err error) {
-	// Extract date portion using regex
-	re := regexp.MustCompile(`(\w+)\s+(\w+)\s+(\d+),\s+(\d+)`)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) < 5 {
-		return time.Time{}, time.Time{}, fmt.Errorf("could not extract date from: %s", text)
-	}
-
-	// Parse: "Thursday December 4, 2025"
-	dateStr := fmt.Sprintf("%s %s, %s", matches[2], matches[3], matches[4])
-	date, err := time.ParseInLocation("January 2, 2006", dateStr, wibTimezone)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
-	}
-
-	// dateWIB: midnight in WIB timezone (for combining with tide times)
-	dateWIB = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, wibTimezone)
-
-	// dateForStorage: same Y/M/D but in UTC (so MySQL stores correct date)
-	dateForStorage = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
-
-	return dateForStorage, dateWIB, nil
-}
-
-// parseTimeWIB parses a time string like "03:12" and combines with the date in WIB,
-// then converts to UTC for consistent storage (required for SQLite compatibility)
-func parseTimeWIB(date time.Time, timeStr string) (time.Time, error) {
-	parts := strings.Split(timeStr, ":")
-	if len(parts) != 2 {
-		return time.Time{}, fmt.Errorf("invalid time format: %s", timeStr)
-	}
-
-	hour, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return time.Time{}, err
-	}
-
-	minute, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return time.Time{}, err
-	}
-
-	// Create time in WIB, then convert to UTC for storage
-	wibTime := time.Date(
-		date.Year(), date.Month(), date.Day(),
-		hour, minute, 0, 0,
-		wibTimezone,
-	)
-	return wibTime.UTC(), nil
-}
-
-// parseHeight parses height string like "1.1 m (3.6 ft)" and returns meters and feet
-func parseHeight(heightStr string) (float64, float64, error) {
-	// Regex to extract: "1.1 m (3.6 ft)" or "-0.1 m (-0.3 ft)"
-	re := regexp.MustCompile(`(-?[\d.]+)\s*m\s*\((-?[\d.]+)\s*ft\)`)
-	matches := re.FindStringSubmatch(heightStr)
-	if len(matches) < 3 {
-		return 0, 0, fmt.Errorf("could not parse height: %s", heightStr)
-	}
-
-	heightM, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	heightFt, err := strconv.ParseFloat(matches[2], 64)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	return heightM, heightFt, nil
-}
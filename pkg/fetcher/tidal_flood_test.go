@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// stubSource returns fixed tides, or fails
+type stubSource struct {
+	tides []models.TideData
+	err   error
+}
+
+func (s stubSource) Name() string {
+	return "stub"
+}
+
+func (s stubSource) FetchTides(station config.TideStation, days int) ([]models.TideData, error) {
+	return s.tides, s.err
+}
+
+func TestFetchAndStoreHooksWithoutCommit(t *testing.T) {
+	stations := []config.TideStation{{Name: "Sekupang"}, {Name: "Batu Ampar"}}
+
+	tests := []struct {
+		name    string
+		source  TideSource
+		wantErr bool
+	}{
+		{"every station failing", stubSource{err: errors.New("offline")}, true},
+		{"no tide data", stubSource{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewTidalFloodFetcher(nil, stations, tt.source)
+			runs := 0
+			f.OnStored(func() { runs++ })
+
+			total, err := f.FetchAndStore()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchAndStore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if total != 0 {
+				t.Errorf("FetchAndStore() stored %d entries, want 0", total)
+			}
+			if runs != 0 {
+				t.Errorf("hooks ran %d times, want none when nothing was committed", runs)
+			}
+		})
+	}
+}
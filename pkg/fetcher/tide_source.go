@@ -0,0 +1,34 @@
+package fetcher
+
+import (
+	"fmt"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// TideSource provides the predicted high and low tides of a station
+type TideSource interface {
+	// Name identifies the source in logs and configuration
+	Name() string
+	// FetchTides returns the tide extremes of the station for the given number of days, starting today
+	FetchTides(station config.TideStation, days int) ([]models.TideData, error)
+}
+
+// NewTideSource creates a tide source by its configuration name.
+// The harmonic source is rejected when no station has valid harmonic constituents,
+// e.g. when the harmonics file is missing or invalid.
+func NewTideSource(name string, stations []config.TideStation) (TideSource, error) {
+	switch name {
+	case "worldtides":
+		return NewWorldTidesSource(), nil
+	case "harmonic":
+		source, err := NewHarmonicSource(stations)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	default:
+		return nil, fmt.Errorf("unknown tide source: %s", name)
+	}
+}
@@ -0,0 +1,255 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+
+	"go.uber.org/zap"
+)
+
+// WorldTidesSource scrapes tide tables from the worldtides.info station pages
+type WorldTidesSource struct {
+	client *http.Client
+}
+
+// NewWorldTidesSource creates a new WorldTidesSource instance
+func NewWorldTidesSource() *WorldTidesSource {
+	return &WorldTidesSource{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the source name
+func (s *WorldTidesSource) Name() string {
+	return "worldtides"
+}
+
+// FetchTides retrieves and parses the multi-day tide forecast of a station from worldtides.info
+// The station page contains one "Tide Times for" header per day, each followed by its tide table
+func (s *WorldTidesSource) FetchTides(station config.TideStation, days int) ([]models.TideData, error) {
+	zap.S().Debugf("Fetching tide data for %s from %s", station.Name, station.URL)
+
+	resp, err := s.client.Get(station.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tide data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worldtides.info returned status code: %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	tideData := make([]models.TideData, 0)
+	parsedDays := 0
+
+	// dateForStorage: UTC midnight with correct Y/M/D (for DB storage)
+	// dateLocal: station midnight (for combining with tide times)
+	var dateForStorage, dateLocal time.Time
+
+	// Walk the date headers and tide tables in document order,
+	// so every table is parsed with the date of the header preceding it
+	doc.Find("div, table.table-bordered").Each(func(i int, sel *goquery.Selection) {
+		if goquery.NodeName(sel) == "table" {
+			if dateLocal.IsZero() {
+				return
+			}
+			tideData = append(tideData, parseTideTable(sel, station.Name, dateForStorage, dateLocal)...)
+			return
+		}
+
+		// Format: "Tide Times for Sekupang: Thursday December 4, 2025 (WIB)"
+		// Only the innermost div holding the header is used, its parents contain the same text
+		if !isTideDateHeader(sel.Text()) {
+			return
+		}
+		if sel.Find("div").FilterFunction(func(_ int, c *goquery.Selection) bool {
+			return isTideDateHeader(c.Text())
+		}).Length() > 0 {
+			return
+		}
+
+		storage, local, err := parseTideDate(sel.Text(), station.Timezone)
+		if err != nil {
+			zap.S().Warnf("Failed to parse tide date: %v", err)
+			return
+		}
+
+		if !storage.Equal(dateForStorage) {
+			parsedDays++
+		}
+		dateForStorage, dateLocal = storage, local
+		zap.S().Debugf("Parsing tide data for date: %s", dateForStorage.Format("2006-01-02"))
+	})
+
+	if parsedDays == 0 {
+		return nil, fmt.Errorf("could not find tide date header")
+	}
+
+	if len(tideData) == 0 {
+		return nil, fmt.Errorf("no tide data found in the table")
+	}
+
+	if parsedDays < days {
+		zap.S().Warnf("Expected at least %d days of tide forecast for %s, got %d", days, station.Name, parsedDays)
+	}
+
+	zap.S().Infof("Fetched %d tide entries for %s for %d days", len(tideData), station.Name, parsedDays)
+	return tideData, nil
+}
+
+// tideDateHeaderRegex matches a whole daily tide table header, e.g. "Tide Times for Sekupang: Thursday December 4, 2025 (WIB)"
+// The header ends with the station timezone abbreviation, e.g. "(WIB)" or "(+08)"
+var tideDateHeaderRegex = regexp.MustCompile(`^Tide Times for [^:]+: (Monday|Tuesday|Wednesday|Thursday|Friday|Saturday|Sunday) [A-Z][a-z]+ \d{1,2}, \d{4} \([^()]+\)$`)
+
+// isTideDateHeader checks whether the text is exactly a daily tide table header, whitespace aside.
+// Wrapper and summary blocks containing more than the header don't match.
+func isTideDateHeader(text string) bool {
+	return tideDateHeaderRegex.MatchString(strings.Join(strings.Fields(text), " "))
+}
+
+// parseTideTable parses the rows of a single day tide table
+func parseTideTable(table *goquery.Selection, location string, dateForStorage time.Time, dateLocal time.Time) []models.TideData {
+	tideData := make([]models.TideData, 0)
+
+	table.Find("tr").Each(func(i int, s *goquery.Selection) {
+		// Skip header row
+		if i == 0 {
+			return
+		}
+
+		cols := s.Find("td")
+		if cols.Length() != 3 {
+			return
+		}
+
+		tideTypeStr := strings.TrimSpace(cols.Eq(0).Text())
+		timeStr := strings.TrimSpace(cols.Eq(1).Text())
+		heightStr := strings.TrimSpace(cols.Eq(2).Text())
+
+		// Parse tide type
+		var tideType models.TideType
+		if strings.Contains(strings.ToLower(tideTypeStr), "high") {
+			tideType = models.TideTypeHigh
+		} else if strings.Contains(strings.ToLower(tideTypeStr), "low") {
+			tideType = models.TideTypeLow
+		} else {
+			zap.S().Warnf("Unknown tide type: %s", tideTypeStr)
+			return
+		}
+
+		// Parse time using the station date (for correct hour/minute combination)
+		tideTime, err := parseTideTime(dateLocal, timeStr)
+		if err != nil {
+			zap.S().Warnf("Failed to parse tide time '%s': %v", timeStr, err)
+			return
+		}
+
+		// Parse height (format: "1.1 m (3.6 ft)")
+		heightM, heightFt, err := parseHeight(heightStr)
+		if err != nil {
+			zap.S().Warnf("Failed to parse tide height '%s': %v", heightStr, err)
+			return
+		}
+
+		data := models.TideData{
+			Location: location,
+			Date:     dateForStorage, // UTC midnight with correct Y/M/D for DB
+			TideType: tideType,
+			TideTime: tideTime, // Converted to UTC in parseTideTime for accurate comparisons
+			HeightM:  heightM,
+			HeightFt: heightFt,
+		}
+
+		tideData = append(tideData, data)
+	})
+
+	return tideData
+}
+
+// parseTideDate parses the date from text like "Tide Times for Sekupang: Thursday December 4, 2025 (WIB)"
+// Returns two values: dateForStorage (UTC midnight for DB) and dateLocal (station midnight, for combining with times)
+func parseTideDate(text string, timezone *time.Location) (dateForStorage time.Time, dateLocal time.Time, err error) {
+	// Extract date portion using regex
+	re := regexp.MustCompile(`(\w+)\s+(\w+)\s+(\d+),\s+(\d+)`)
+	matches := re.FindStringSubmatch(text)
+	if len(matches) < 5 {
+		return time.Time{}, time.Time{}, fmt.Errorf("could not extract date from: %s", text)
+	}
+
+	// Parse: "Thursday December 4, 2025"
+	dateStr := fmt.Sprintf("%s %s, %s", matches[2], matches[3], matches[4])
+	date, err := time.ParseInLocation("January 2, 2006", dateStr, timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	// dateLocal: midnight in the station timezone (for combining with tide times)
+	dateLocal = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, timezone)
+
+	// dateForStorage: same Y/M/D but in UTC (so MySQL stores correct date)
+	dateForStorage = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	return dateForStorage, dateLocal, nil
+}
+
+// parseTideTime parses a time string like "03:12" and combines with the date in the station timezone,
+// then converts to UTC for consistent storage (required for SQLite compatibility)
+func parseTideTime(date time.Time, timeStr string) (time.Time, error) {
+	parts := strings.Split(timeStr, ":")
+	if len(parts) != 2 {
+		return time.Time{}, fmt.Errorf("invalid time format: %s", timeStr)
+	}
+
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Create time in the station timezone, then convert to UTC for storage
+	localTime := time.Date(
+		date.Year(), date.Month(), date.Day(),
+		hour, minute, 0, 0,
+		date.Location(),
+	)
+	return localTime.UTC(), nil
+}
+
+// parseHeight parses height string like "1.1 m (3.6 ft)" and returns meters and feet
+func parseHeight(heightStr string) (float64, float64, error) {
+	// Regex to extract: "1.1 m (3.6 ft)" or "-0.1 m (-0.3 ft)"
+	re := regexp.MustCompile(`(-?[\d.]+)\s*m\s*\((-?[\d.]+)\s*ft\)`)
+	matches := re.FindStringSubmatch(heightStr)
+	if len(matches) < 3 {
+		return 0, 0, fmt.Errorf("could not parse height: %s", heightStr)
+	}
+
+	heightM, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	heightFt, err := strconv.ParseFloat(matches[2], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return heightM, heightFt, nil
+}
@@ -0,0 +1,94 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+func TestIsTideDateHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"header", "Tide Times for Sekupang: Thursday December 4, 2025 (WIB)", true},
+		{"offset timezone", "Tide Times for Batam Centre: Monday January 12, 2026 (+07)", true},
+		{"surrounding whitespace", "\n  Tide Times for Sekupang:\n  Friday December 5, 2025 (WIB)\n", true},
+		{"summary", "Tide Times for Sekupang (WIB) for the next 7 days", false},
+		{"wrapper", "Tide Times for Sekupang: Thursday December 4, 2025 (WIB) High Tide 05:41 2.9 m (9.5 ft)", false},
+		{"no timezone", "Tide Times for Sekupang: Thursday December 4, 2025", false},
+		{"no weekday", "Tide Times for Sekupang: December 4, 2025 (WIB)", false},
+		{"other text", "Sunrise and sunset (WIB)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTideDateHeader(tt.text); got != tt.want {
+				t.Errorf("isTideDateHeader(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchTidesMultiDay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/worldtides_sekupang.html")
+	}))
+	defer server.Close()
+
+	wib := time.FixedZone("WIB", 7*60*60)
+	station := config.TideStation{Name: "Sekupang", URL: server.URL, Timezone: wib}
+
+	tides, err := NewWorldTidesSource().FetchTides(station, 3)
+	if err != nil {
+		t.Fatalf("FetchTides() error = %v", err)
+	}
+
+	// The summary block and the sunrise table are ignored, as is the slack water row
+	want := []struct {
+		date     string
+		tideType models.TideType
+		local    string
+		heightM  float64
+	}{
+		{"2025-12-04", models.TideTypeLow, "2025-12-04 03:12", 0.6},
+		{"2025-12-04", models.TideTypeHigh, "2025-12-04 09:27", 2.8},
+		{"2025-12-04", models.TideTypeLow, "2025-12-04 15:40", 0.9},
+		{"2025-12-04", models.TideTypeHigh, "2025-12-04 21:55", 2.6},
+		{"2025-12-05", models.TideTypeLow, "2025-12-05 04:01", -0.1},
+		{"2025-12-05", models.TideTypeHigh, "2025-12-05 10:15", 2.9},
+		{"2025-12-05", models.TideTypeLow, "2025-12-05 16:30", 0.8},
+		{"2025-12-06", models.TideTypeHigh, "2025-12-06 00:20", 2.5},
+		{"2025-12-06", models.TideTypeLow, "2025-12-06 04:48", 0.0},
+	}
+
+	if len(tides) != len(want) {
+		t.Fatalf("FetchTides() returned %d tides, want %d", len(tides), len(want))
+	}
+
+	for i, w := range want {
+		tide := tides[i]
+		local, _ := time.ParseInLocation("2006-01-02 15:04", w.local, wib)
+
+		if tide.Location != "Sekupang" {
+			t.Errorf("tide %d: location = %s, want Sekupang", i, tide.Location)
+		}
+		if got := tide.Date.Format("2006-01-02"); got != w.date || tide.Date.Location() != time.UTC {
+			t.Errorf("tide %d: date = %s (%s), want %s UTC", i, got, tide.Date.Location(), w.date)
+		}
+		if tide.TideType != w.tideType {
+			t.Errorf("tide %d: type = %s, want %s", i, tide.TideType, w.tideType)
+		}
+		if !tide.TideTime.Equal(local) || tide.TideTime.Location() != time.UTC {
+			t.Errorf("tide %d: time = %s, want %s in UTC", i, tide.TideTime, local.UTC())
+		}
+		if tide.HeightM != w.heightM {
+			t.Errorf("tide %d: height = %.1fm, want %.1fm", i, tide.HeightM, w.heightM)
+		}
+	}
+}
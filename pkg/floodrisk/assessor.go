@@ -0,0 +1,352 @@
+package floodrisk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// assessmentUpdateColumns are the columns replaced when an assessment is recomputed, the ID and creation time are kept
+var assessmentUpdateColumns = []string{
+	"tide_data_id", "tide_time", "tide_height_m", "water_level_m", "water_level_at", "surge_m", "anomaly_m",
+	"has_risk", "level", "score", "heavy_rain", "rain_intensity", "rule", "message", "rule_version", "computed_at", "updated_at",
+}
+
+// assessmentRecomputeWindow is how long after an alert (and its buffer) expired its assessment is still refreshed
+const assessmentRecomputeWindow = 24 * time.Hour
+
+// Assessor computes and persists the flood risk assessments of the alerts
+type Assessor struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	rules *RuleSet
+	tides *TideCache
+	mu    sync.Mutex
+}
+
+// NewAssessor creates a new Assessor for the alerts of the configured provinces
+func NewAssessor(db *gorm.DB, cfg *config.Config, rules *RuleSet, tides *TideCache) *Assessor {
+	return &Assessor{
+		db:    db,
+		cfg:   cfg,
+		rules: rules,
+		tides: tides,
+	}
+}
+
+// Run recomputes and stores the assessments of the active and recently expired alerts,
+// for their station and for every home they cover.
+// Meant to run after every fetch: each alert has a single assessment per station or home,
+// replaced while the alert is recomputed and kept as is once it expired.
+func (a *Assessor) Run() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	since := time.Now().UTC().Add(-a.rules.MaxBuffer() - assessmentRecomputeWindow)
+
+	var alerts []weathermodels.AlertDetail
+	if err := a.db.Where("area_description IN ? AND expires >= ?", a.cfg.GetProvinces(), since).
+		Find(&alerts).Error; err != nil {
+		zap.S().Errorf("Failed to query alerts for flood risk assessment: %v", err)
+		return
+	}
+
+	stations := make([]string, len(alerts))
+	for i, alert := range alerts {
+		stations[i] = a.cfg.GetTideStationFor(alert.Description).Name
+	}
+
+	records, err := a.Compute(alerts, stations)
+	if err != nil {
+		zap.S().Errorf("Failed to store flood risk assessments: %v", err)
+		return
+	}
+	count := len(records)
+
+	var homes []models.Home
+	if err := a.db.Find(&homes).Error; err != nil {
+		zap.S().Errorf("Failed to query homes for flood risk assessment: %v", err)
+	}
+
+	for _, home := range homes {
+		homeAlerts := make([]weathermodels.AlertDetail, 0)
+		for _, alert := range alerts {
+			if home.MatchesAlert(alert) {
+				homeAlerts = append(homeAlerts, alert)
+			}
+		}
+
+		records, err := a.ComputeForHome(home, homeAlerts)
+		if err != nil {
+			zap.S().Errorf("Failed to store flood risk assessments for home %s: %v", home.Name, err)
+			continue
+		}
+		count += len(records)
+	}
+
+	zap.S().Infof("Stored %d flood risk assessments", count)
+}
+
+// FindAll returns the stored assessments of the alerts, stations[i] being the station of alerts[i].
+// Missing assessments, or those computed with another rule version, are computed and stored in a single batch.
+func (a *Assessor) FindAll(alerts []weathermodels.AlertDetail, stations []string) ([]models.FloodRiskAssessment, error) {
+	return a.find(a.rules, nil, alerts, stations)
+}
+
+// FindAllForHome returns the stored assessments of the alerts for a home, computing the missing ones
+func (a *Assessor) FindAllForHome(home models.Home, alerts []weathermodels.AlertDetail) ([]models.FloodRiskAssessment, error) {
+	return a.find(a.RulesFor(home), &home, alerts, a.homeStations(home, len(alerts)))
+}
+
+// Compute evaluates the rules for the alerts, stations[i] being the station of alerts[i],
+// and stores the results replacing the previous assessments
+func (a *Assessor) Compute(alerts []weathermodels.AlertDetail, stations []string) ([]models.FloodRiskAssessment, error) {
+	return a.compute(a.rules, nil, alerts, stations)
+}
+
+// ComputeForHome evaluates the rules for the alerts at the home station and threshold, and stores the results.
+// A flooded live reading of the home overrides the alerts in effect, see AssessForHome.
+func (a *Assessor) ComputeForHome(home models.Home, alerts []weathermodels.AlertDetail) ([]models.FloodRiskAssessment, error) {
+	return a.compute(a.RulesFor(home), &home, alerts, a.homeStations(home, len(alerts)))
+}
+
+// AssessForHome evaluates the rules for the alerts at the home station and threshold without storing the results.
+// A flooded live reading of the home overrides the predicted risk of the alerts in effect with extreme risk.
+func (a *Assessor) AssessForHome(home models.Home, alerts []weathermodels.AlertDetail) ([]Assessment, error) {
+	return a.assess(a.RulesFor(home), &home, alerts, a.homeStations(home, len(alerts)))
+}
+
+// HomeTimeline evaluates the flood risk windows of a home between from and to at its station and threshold.
+// A flooded live reading of the home opens an extreme window ahead of the predicted ones.
+func (a *Assessor) HomeTimeline(home models.Home, alerts []weathermodels.AlertDetail, from, to time.Time) ([]Window, error) {
+	windows, err := a.RulesFor(home).Timeline(a.tides, HomeStation(a.cfg, home).Name, alerts, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	observed, err := ObservedWindow(a.db, a.cfg, home, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return WithObserved(windows, observed, from, to), nil
+}
+
+// RulesFor returns the rules adjusted to the flood threshold of the home
+func (a *Assessor) RulesFor(home models.Home) *RuleSet {
+	return a.rules.WithThreshold(home.FloodThresholdM)
+}
+
+// homeStations repeats the station of the home for n alerts
+func (a *Assessor) homeStations(home models.Home, n int) []string {
+	station := HomeStation(a.cfg, home).Name
+	stations := make([]string, n)
+	for i := range stations {
+		stations[i] = station
+	}
+	return stations
+}
+
+// find returns the stored assessments of the alerts for a home (nil for stations), computing the missing ones
+func (a *Assessor) find(rules *RuleSet, home *models.Home, alerts []weathermodels.AlertDetail, stations []string) ([]models.FloodRiskAssessment, error) {
+	stored, err := a.stored(homeIDOf(home), alerts)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.FloodRiskAssessment, len(alerts))
+	missing := make([]int, 0)
+	for i, alert := range alerts {
+		record, ok := stored[assessmentKey(alert.ID, stations[i])]
+		if !ok || record.RuleVersion != rules.Version {
+			missing = append(missing, i)
+			continue
+		}
+		records[i] = record
+	}
+
+	if len(missing) == 0 {
+		return records, nil
+	}
+
+	missingAlerts := make([]weathermodels.AlertDetail, len(missing))
+	missingStations := make([]string, len(missing))
+	for j, i := range missing {
+		missingAlerts[j] = alerts[i]
+		missingStations[j] = stations[i]
+	}
+
+	computed, err := a.compute(rules, home, missingAlerts, missingStations)
+	if err != nil {
+		return nil, err
+	}
+
+	for j, i := range missing {
+		records[i] = computed[j]
+	}
+
+	return records, nil
+}
+
+// compute evaluates the rules for the alerts of a home (nil for stations) and stores the results
+func (a *Assessor) compute(rules *RuleSet, home *models.Home, alerts []weathermodels.AlertDetail, stations []string) ([]models.FloodRiskAssessment, error) {
+	if len(alerts) == 0 {
+		return []models.FloodRiskAssessment{}, nil
+	}
+
+	assessments, err := a.assess(rules, home, alerts, stations)
+	if err != nil {
+		return nil, err
+	}
+
+	homeID := homeIDOf(home)
+
+	records := make([]models.FloodRiskAssessment, len(alerts))
+	for i, alert := range alerts {
+		records[i] = NewRecord(alert.ID, stations[i], rules.Version, assessments[i])
+		records[i].HomeID = homeID
+	}
+
+	// Upsert on the alert/station/home index, so concurrent computations of the same alert replace each other
+	if err := a.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "alert_detail_id"}, {Name: "location"}, {Name: "home_id"}},
+		DoUpdates: clause.AssignmentColumns(assessmentUpdateColumns),
+	}).Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to store flood risk assessments: %w", err)
+	}
+
+	// Replaced assessments keep the ID of the stored row
+	stored, err := a.stored(homeID, alerts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if existing, ok := stored[assessmentKey(records[i].AlertDetailID, records[i].Location)]; ok {
+			records[i].ID = existing.ID
+			records[i].CreatedAt = existing.CreatedAt
+		}
+	}
+
+	return records, nil
+}
+
+// assess evaluates the rules for the alerts of a home (nil for stations).
+// A flooded live reading of the home overrides the alerts in effect now, including the buffer after they expire.
+func (a *Assessor) assess(rules *RuleSet, home *models.Home, alerts []weathermodels.AlertDetail, stations []string) ([]Assessment, error) {
+	assessments, err := rules.AssessBatch(a.tides, alerts, stations)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine tidal flood risk: %w", err)
+	}
+	if home == nil {
+		return assessments, nil
+	}
+
+	now := time.Now().UTC()
+	observed, err := ObservedWindow(a.db, a.cfg, *home, now)
+	if err != nil {
+		zap.S().Errorf("Failed to get the observed flooding of %s: %v", home.Name, err)
+		return assessments, nil
+	}
+	if observed == nil {
+		return assessments, nil
+	}
+
+	for i, alert := range alerts {
+		if !now.Before(alert.Effective) && !now.After(alert.Expires.Add(rules.MaxBuffer())) {
+			assessments[i].applyObserved(*observed)
+		}
+	}
+
+	return assessments, nil
+}
+
+// homeIDOf returns the ID of a home, empty for stations
+func homeIDOf(home *models.Home) string {
+	if home == nil {
+		return ""
+	}
+	return home.ID
+}
+
+// stored returns the stored assessments of the alerts for a home (empty for stations) keyed by assessmentKey
+func (a *Assessor) stored(homeID string, alerts []weathermodels.AlertDetail) (map[string]models.FloodRiskAssessment, error) {
+	ids := make([]string, len(alerts))
+	for i, alert := range alerts {
+		ids[i] = alert.ID
+	}
+
+	var records []models.FloodRiskAssessment
+	if err := a.db.Where("alert_detail_id IN ? AND home_id = ?", ids, homeID).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to query flood risk assessments: %w", err)
+	}
+
+	stored := make(map[string]models.FloodRiskAssessment, len(records))
+	for _, record := range records {
+		stored[assessmentKey(record.AlertDetailID, record.Location)] = record
+	}
+
+	return stored, nil
+}
+
+// assessmentKey identifies the assessment of an alert at a station
+func assessmentKey(alertDetailID string, station string) string {
+	return alertDetailID + "|" + station
+}
+
+// HomeStation returns the tide station of a home: the configured one when set and known,
+// otherwise the station matching the home keywords or name
+func HomeStation(cfg *config.Config, home models.Home) config.TideStation {
+	if home.Station != "" {
+		for _, station := range cfg.GetTideStations() {
+			if station.Name == home.Station {
+				return station
+			}
+		}
+	}
+
+	texts := append(append(make([]string, 0, len(home.Keywords)+1), home.Keywords...), home.Name)
+	return cfg.GetTideStationFor(texts...)
+}
+
+// NewRecord converts an assessment to its database model
+func NewRecord(alertDetailID string, station string, ruleVersion string, assessment Assessment) models.FloodRiskAssessment {
+	record := models.FloodRiskAssessment{
+		AlertDetailID: alertDetailID,
+		Location:      station,
+		HasRisk:       assessment.HasRisk,
+		Level:         assessment.Level,
+		Score:         assessment.Score,
+		HeavyRain:     assessment.HeavyRain,
+		SurgeM:        assessment.SurgeM,
+		AnomalyM:      assessment.AnomalyM,
+		RainIntensity: string(assessment.RainIntensity),
+		Rule:          assessment.Rule,
+		Message:       assessment.Message,
+		RuleVersion:   ruleVersion,
+		ComputedAt:    time.Now().UTC(),
+	}
+
+	if assessment.Tide != nil {
+		tideID := assessment.Tide.ID
+		tideTime := assessment.Tide.TideTime
+		record.TideDataID = &tideID
+		record.TideTime = &tideTime
+		record.TideHeightM = assessment.Tide.HeightM
+	}
+
+	if assessment.WaterLevel != nil {
+		waterLevelAt := assessment.WaterLevel.Time
+		record.WaterLevelM = assessment.WaterLevel.HeightM
+		record.WaterLevelAt = &waterLevelAt
+	}
+
+	return record
+}
@@ -0,0 +1,181 @@
+package floodrisk
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Range of high tides loaded around now on a cache miss, covering the tide forecast
+const (
+	cacheBehind = 48 * time.Hour
+	cacheAhead  = 8 * 24 * time.Hour
+)
+
+// TideCache keeps the high and low tides and the sea level anomalies of every station in memory
+// so risk evaluations don't query the database. It must be invalidated whenever either is stored.
+type TideCache struct {
+	db     *gorm.DB
+	mu     sync.RWMutex
+	loaded bool
+	from   time.Time
+	to     time.Time
+	// tides holds the high and low tides of the loaded range per location, ordered by tide time
+	tides map[string][]models.TideData
+	// anomalies holds the sea level anomalies valid during the loaded range per location
+	anomalies map[string][]models.SeaLevelAnomaly
+}
+
+// NewTideCache creates a new empty TideCache
+func NewTideCache(db *gorm.DB) *TideCache {
+	return &TideCache{db: db}
+}
+
+// HighTides returns the high tides of a station above minHeight between from and to (inclusive), ordered by tide time.
+// The range is served from memory when loaded, otherwise it is loaded for all stations with a single query.
+func (c *TideCache) HighTides(station string, from, to time.Time, minHeight float64) ([]models.TideData, error) {
+	var tideData []models.TideData
+	err := c.read(from, to, func() {
+		tideData = c.filter(station, from, to, minHeight)
+	})
+	return tideData, err
+}
+
+// Curve returns the tide curve of a station covering from and to, see LoadTideCurve
+func (c *TideCache) Curve(station string, from, to time.Time) (*TideCurve, error) {
+	from, to = from.Add(-curveMargin), to.Add(curveMargin)
+
+	var curve *TideCurve
+	err := c.read(from, to, func() {
+		extremes := make([]models.TideData, 0)
+		for _, tide := range c.tides[station] {
+			if !tide.TideTime.Before(from) && !tide.TideTime.After(to) {
+				extremes = append(extremes, tide)
+			}
+		}
+		curve = &TideCurve{extremes: extremes}
+	})
+	return curve, err
+}
+
+// Anomalies returns the sea level anomalies of a station valid at any moment between from and to
+func (c *TideCache) Anomalies(station string, from, to time.Time) ([]models.SeaLevelAnomaly, error) {
+	anomalies := make([]models.SeaLevelAnomaly, 0)
+	err := c.read(from, to, func() {
+		for _, anomaly := range c.anomalies[station] {
+			if !anomaly.ValidUntil.Before(from) && !anomaly.ValidFrom.After(to) {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+	})
+	return anomalies, err
+}
+
+// read calls fn holding the lock once the range from to is loaded, loading it for all stations
+// with a single query on a cache miss
+func (c *TideCache) read(from, to time.Time, fn func()) error {
+	c.mu.RLock()
+	if c.covers(from, to) {
+		defer c.mu.RUnlock()
+		fn()
+		return nil
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller may have loaded the range meanwhile
+	if !c.covers(from, to) {
+		if err := c.load(from, to); err != nil {
+			return err
+		}
+	}
+
+	fn()
+	return nil
+}
+
+// Invalidate drops the cached tides, the next lookup reloads them
+func (c *TideCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.loaded = false
+	c.tides = nil
+	c.anomalies = nil
+	zap.S().Debug("Tide cache invalidated")
+}
+
+// covers reports whether the loaded range includes from and to, the lock must be held
+func (c *TideCache) covers(from, to time.Time) bool {
+	return c.loaded && !from.Before(c.from) && !to.After(c.to)
+}
+
+// load replaces the cached tides and anomalies with those of a range including from, to and the forecast around now.
+// The write lock must be held.
+func (c *TideCache) load(from, to time.Time) error {
+	now := time.Now().UTC()
+	if start := now.Add(-cacheBehind); start.Before(from) {
+		from = start
+	}
+	if end := now.Add(cacheAhead); end.After(to) {
+		to = end
+	}
+
+	var tideData []models.TideData
+	result := c.db.Where("tide_time >= ? AND tide_time <= ?", from, to).
+		Order("tide_time ASC").
+		Find(&tideData)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to query tide data: %w", result.Error)
+	}
+
+	var anomalyData []models.SeaLevelAnomaly
+	result = c.db.Where("valid_until >= ? AND valid_from <= ?", from, to).
+		Order("valid_from ASC").
+		Find(&anomalyData)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to query sea level anomalies: %w", result.Error)
+	}
+
+	tides := make(map[string][]models.TideData)
+	for _, tide := range tideData {
+		tides[tide.Location] = append(tides[tide.Location], tide)
+	}
+
+	anomalies := make(map[string][]models.SeaLevelAnomaly)
+	for _, anomaly := range anomalyData {
+		anomalies[anomaly.Location] = append(anomalies[anomaly.Location], anomaly)
+	}
+
+	c.tides = tides
+	c.anomalies = anomalies
+	c.from = from
+	c.to = to
+	c.loaded = true
+
+	zap.S().Debugf("Loaded %d tides and %d anomalies in tide cache from %s to %s", len(tideData), len(anomalyData),
+		from.Format(time.RFC3339), to.Format(time.RFC3339))
+	return nil
+}
+
+// filter copies the cached high tides of a station in the range above minHeight, the lock must be held
+func (c *TideCache) filter(station string, from, to time.Time, minHeight float64) []models.TideData {
+	result := make([]models.TideData, 0)
+	for _, tide := range c.tides[station] {
+		if tide.TideType != models.TideTypeHigh || tide.TideTime.Before(from) || tide.TideTime.After(to) || tide.HeightM <= minHeight {
+			continue
+		}
+		result = append(result, tide)
+	}
+
+	return result
+}
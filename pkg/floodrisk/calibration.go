@@ -0,0 +1,102 @@
+package floodrisk
+
+import (
+	"math"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// calibrationMargin is kept below the lowest flooding tide when no dry observation bounds the threshold
+const calibrationMargin = 0.05
+
+// CalibrationPoint compares an observation with the predicted tide at the same moment
+type CalibrationPoint struct {
+	Observation models.WaterObservation
+	PredictedM  float64
+	// ResidualM is the observed minus the predicted level
+	ResidualM float64
+}
+
+// Calibration compares the observations of a home with the tide predictions of its station
+type Calibration struct {
+	Points []CalibrationPoint
+	// Skipped counts the observations outside the stored tide predictions
+	Skipped int
+	Flooded int
+	// MeanResidualM and RMSResidualM measure how far the observed levels are from the predictions
+	MeanResidualM float64
+	RMSResidualM  float64
+	// LowestFloodedM is the lowest predicted tide of a flooded observation,
+	// HighestDryM the highest predicted tide of a dry observation below it
+	LowestFloodedM *float64
+	HighestDryM    *float64
+	// Conflicts counts the dry observations at or above LowestFloodedM (flooded by rain, or a wrong report)
+	Conflicts int
+	// SuggestedThresholdM is the tide height separating flooded from dry observations, nil without flooded observations
+	SuggestedThresholdM *float64
+}
+
+// Calibrate compares the observations with the curve. The suggested threshold is halfway between the lowest
+// predicted tide that flooded and the highest one below it that did not, or just below the former
+// when every observation below it flooded.
+func Calibrate(curve *TideCurve, observations []models.WaterObservation) Calibration {
+	calibration := Calibration{Points: make([]CalibrationPoint, 0, len(observations))}
+
+	var sum, squares float64
+	for _, observation := range observations {
+		predicted, ok := curve.Level(observation.ObservedAt)
+		if !ok {
+			calibration.Skipped++
+			continue
+		}
+
+		residual := observation.LevelM - predicted
+		calibration.Points = append(calibration.Points, CalibrationPoint{
+			Observation: observation,
+			PredictedM:  predicted,
+			ResidualM:   residual,
+		})
+		sum += residual
+		squares += residual * residual
+
+		if observation.Flooded {
+			calibration.Flooded++
+			if calibration.LowestFloodedM == nil || predicted < *calibration.LowestFloodedM {
+				calibration.LowestFloodedM = &predicted
+			}
+		}
+	}
+
+	if n := float64(len(calibration.Points)); n > 0 {
+		calibration.MeanResidualM = sum / n
+		calibration.RMSResidualM = math.Sqrt(squares / n)
+	}
+
+	if calibration.LowestFloodedM == nil {
+		return calibration
+	}
+	lowest := *calibration.LowestFloodedM
+
+	for _, point := range calibration.Points {
+		if point.Observation.Flooded {
+			continue
+		}
+		if point.PredictedM >= lowest {
+			calibration.Conflicts++
+			continue
+		}
+		if calibration.HighestDryM == nil || point.PredictedM > *calibration.HighestDryM {
+			predicted := point.PredictedM
+			calibration.HighestDryM = &predicted
+		}
+	}
+
+	suggested := lowest - calibrationMargin
+	if calibration.HighestDryM != nil {
+		suggested = (lowest + *calibration.HighestDryM) / 2
+	}
+	suggested = math.Round(suggested*100) / 100
+	calibration.SuggestedThresholdM = &suggested
+
+	return calibration
+}
@@ -0,0 +1,151 @@
+package floodrisk
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// observation reports the water level hours after the start of the test day, offset from the predicted level
+func observation(curve *TideCurve, hours float64, residual float64, flooded bool) models.WaterObservation {
+	predicted, _ := curve.Level(at(hours))
+	return models.WaterObservation{ObservedAt: at(hours), LevelM: predicted + residual, Flooded: flooded}
+}
+
+func TestCalibrate(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	// Predicted levels: 06:00 2.9m, 18:00 2.7m, 05:00 2.74m, 16:00 2.2m, 04:00 2.3m, 00:00 0.5m
+	tests := []struct {
+		name          string
+		observations  []models.WaterObservation
+		wantPoints    int
+		wantSkipped   int
+		wantFlooded   int
+		wantLowest    *float64
+		wantHighest   *float64
+		wantConflicts int
+		wantSuggested *float64
+	}{
+		{
+			name: "no observations",
+		},
+		{
+			name: "dry only",
+			observations: []models.WaterObservation{
+				observation(curve, 6, 0, false),
+				observation(curve, 0, 0, false),
+			},
+			wantPoints: 2,
+		},
+		{
+			name: "flooded above dry",
+			observations: []models.WaterObservation{
+				observation(curve, 6, 0.1, true),
+				observation(curve, 18, 0.1, true),
+				observation(curve, 4, 0, false),
+				observation(curve, 0, 0, false),
+			},
+			wantPoints:    4,
+			wantFlooded:   2,
+			wantLowest:    ptr(2.7),
+			wantHighest:   ptr(2.3),
+			wantSuggested: ptr(2.5),
+		},
+		{
+			name: "all flooded",
+			observations: []models.WaterObservation{
+				observation(curve, 6, 0.2, true),
+				observation(curve, 18, 0.2, true),
+			},
+			wantPoints:    2,
+			wantFlooded:   2,
+			wantLowest:    ptr(2.7),
+			wantSuggested: ptr(2.65),
+		},
+		{
+			name: "dry above the lowest flooding is a conflict",
+			observations: []models.WaterObservation{
+				observation(curve, 18, 0, true),
+				observation(curve, 6, 0, false),
+				observation(curve, 16, 0, false),
+			},
+			wantPoints:    3,
+			wantFlooded:   1,
+			wantLowest:    ptr(2.7),
+			wantHighest:   ptr(2.2),
+			wantConflicts: 1,
+			wantSuggested: ptr(2.45),
+		},
+		{
+			name: "outside the predictions",
+			observations: []models.WaterObservation{
+				{ObservedAt: at(30), LevelM: 3.0, Flooded: true},
+				observation(curve, 5, 0, true),
+			},
+			wantPoints:    1,
+			wantSkipped:   1,
+			wantFlooded:   1,
+			wantLowest:    ptr(2.74),
+			wantSuggested: ptr(2.69),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calibration := Calibrate(curve, tt.observations)
+
+			if len(calibration.Points) != tt.wantPoints || calibration.Skipped != tt.wantSkipped || calibration.Flooded != tt.wantFlooded {
+				t.Errorf("Calibrate() points = %d, skipped = %d, flooded = %d, want %d, %d, %d",
+					len(calibration.Points), calibration.Skipped, calibration.Flooded, tt.wantPoints, tt.wantSkipped, tt.wantFlooded)
+			}
+			if calibration.Conflicts != tt.wantConflicts {
+				t.Errorf("Calibrate() conflicts = %d, want %d", calibration.Conflicts, tt.wantConflicts)
+			}
+			checkLevel(t, "lowest flooded", calibration.LowestFloodedM, tt.wantLowest, 0.01)
+			checkLevel(t, "highest dry", calibration.HighestDryM, tt.wantHighest, 1e-9)
+			checkLevel(t, "suggested threshold", calibration.SuggestedThresholdM, tt.wantSuggested, 1e-9)
+		})
+	}
+}
+
+func TestCalibrateResiduals(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	calibration := Calibrate(curve, []models.WaterObservation{
+		observation(curve, 6, 0.3, true),
+		observation(curve, 0, -0.1, false),
+	})
+
+	if math.Abs(calibration.MeanResidualM-0.1) > 1e-9 {
+		t.Errorf("MeanResidualM = %.4f, want 0.1", calibration.MeanResidualM)
+	}
+	if want := math.Sqrt((0.09 + 0.01) / 2); math.Abs(calibration.RMSResidualM-want) > 1e-9 {
+		t.Errorf("RMSResidualM = %.4f, want %.4f", calibration.RMSResidualM, want)
+	}
+	if point := calibration.Points[0]; math.Abs(point.PredictedM-2.9) > 1e-9 || math.Abs(point.ResidualM-0.3) > 1e-9 {
+		t.Errorf("point = %.2f predicted, %.2f residual, want 2.9, 0.3", point.PredictedM, point.ResidualM)
+	}
+
+	if empty := Calibrate(curve, nil); empty.MeanResidualM != 0 || empty.RMSResidualM != 0 || empty.SuggestedThresholdM != nil {
+		t.Errorf("Calibrate(nil) = %+v, want zero residuals and no threshold", empty)
+	}
+}
+
+// ptr returns a pointer to the level
+func ptr(level float64) *float64 {
+	return &level
+}
+
+// checkLevel compares an optional level with its expected value
+func checkLevel(t *testing.T, name string, got, want *float64, tolerance float64) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("%s = %v, want %v", name, got, want)
+	case math.Abs(*got-*want) > tolerance:
+		t.Errorf("%s = %.4f, want %.4f", name, *got, *want)
+	}
+}
@@ -0,0 +1,174 @@
+package floodrisk
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// curveMargin is loaded around a curve range so its ends fall between two extremes (about 6h apart)
+const curveMargin = 12 * time.Hour
+
+// CurvePoint is the predicted water level at a moment
+type CurvePoint struct {
+	Time    time.Time
+	HeightM float64
+}
+
+// TideCurve predicts the water level between the stored high and low tides of a station,
+// following half a cosine from one extreme to the next
+type TideCurve struct {
+	extremes []models.TideData
+}
+
+// NewTideCurve creates a TideCurve from the extremes of a single station, in any order
+func NewTideCurve(extremes []models.TideData) *TideCurve {
+	sorted := make([]models.TideData, len(extremes))
+	copy(sorted, extremes)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].TideTime.Before(sorted[j].TideTime)
+	})
+
+	return &TideCurve{extremes: sorted}
+}
+
+// LoadTideCurve loads the curve of a station covering from and to
+func LoadTideCurve(db *gorm.DB, station string, from, to time.Time) (*TideCurve, error) {
+	var extremes []models.TideData
+	result := db.Where("location = ? AND tide_time >= ? AND tide_time <= ?", station, from.Add(-curveMargin), to.Add(curveMargin)).
+		Order("tide_time ASC").
+		Find(&extremes)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to query tide data: %w", result.Error)
+	}
+
+	return NewTideCurve(extremes), nil
+}
+
+// Level returns the predicted water level at a moment, false outside the stored extremes
+func (c *TideCurve) Level(at time.Time) (float64, bool) {
+	previous, next, ok := c.Around(at)
+	if !ok {
+		return 0, false
+	}
+
+	span := next.TideTime.Sub(previous.TideTime)
+	if span <= 0 {
+		return previous.HeightM, true
+	}
+	fraction := float64(at.Sub(previous.TideTime)) / float64(span)
+
+	return previous.HeightM + (next.HeightM-previous.HeightM)*(1-math.Cos(math.Pi*fraction))/2, true
+}
+
+// Around returns the stored extremes before and after a moment, false outside the stored extremes.
+// Both are the last extreme when the moment is that extreme.
+func (c *TideCurve) Around(at time.Time) (models.TideData, models.TideData, bool) {
+	n := len(c.extremes)
+	if n == 0 || at.Before(c.extremes[0].TideTime) || at.After(c.extremes[n-1].TideTime) {
+		return models.TideData{}, models.TideData{}, false
+	}
+
+	// Index of the first extreme after the moment
+	next := sort.Search(n, func(i int) bool {
+		return c.extremes[i].TideTime.After(at)
+	})
+	if next == n {
+		return c.extremes[n-1], c.extremes[n-1], true
+	}
+
+	return c.extremes[next-1], c.extremes[next], true
+}
+
+// Cycle returns the extremes before and after a moment, bounding the tidal cycle of a high tide at that moment.
+// The moment itself is returned at the ends of the curve.
+func (c *TideCurve) Cycle(at time.Time) (time.Time, time.Time) {
+	n := len(c.extremes)
+	start, end := at, at
+
+	// Index of the first extreme at or after the moment
+	i := sort.Search(n, func(i int) bool {
+		return !c.extremes[i].TideTime.Before(at)
+	})
+	if i > 0 {
+		start = c.extremes[i-1].TideTime
+	}
+
+	// Index of the first extreme after the moment
+	j := sort.Search(n, func(i int) bool {
+		return c.extremes[i].TideTime.After(at)
+	})
+	if j < n {
+		end = c.extremes[j].TideTime
+	}
+
+	return start, end
+}
+
+// Sample returns the predicted levels from from to to every step, skipping moments outside the stored extremes
+func (c *TideCurve) Sample(from, to time.Time, step time.Duration) []CurvePoint {
+	points := make([]CurvePoint, 0)
+	for at := from; !at.After(to); at = at.Add(step) {
+		if height, ok := c.Level(at); ok {
+			points = append(points, CurvePoint{Time: at, HeightM: height})
+		}
+	}
+	return points
+}
+
+// WaterLevel is the highest predicted water level of a period and the high tide of its tidal cycle
+type WaterLevel struct {
+	CurvePoint
+	Tide models.TideData
+}
+
+// MaxLevel returns the highest predicted water level between from and to, false when the curve doesn't cover them.
+// The curve is monotonic between extremes, so the highest level is at either end or at a high tide inside.
+func (c *TideCurve) MaxLevel(from, to time.Time) (WaterLevel, bool) {
+	n := len(c.extremes)
+	if n < 2 || to.Before(from) || to.Before(c.extremes[0].TideTime) || from.After(c.extremes[n-1].TideTime) {
+		return WaterLevel{}, false
+	}
+	if from.Before(c.extremes[0].TideTime) {
+		from = c.extremes[0].TideTime
+	}
+	if to.After(c.extremes[n-1].TideTime) {
+		to = c.extremes[n-1].TideTime
+	}
+
+	var best WaterLevel
+	found := false
+	consider := func(at time.Time, tide models.TideData) {
+		height, ok := c.Level(at)
+		if ok && tide.TideType == models.TideTypeHigh && (!found || height > best.HeightM) {
+			best = WaterLevel{CurvePoint: CurvePoint{Time: at, HeightM: height}, Tide: tide}
+			found = true
+		}
+	}
+
+	// At the start the water falls from the previous high tide, at the end it rises to the next one
+	first := sort.Search(n, func(i int) bool {
+		return !c.extremes[i].TideTime.Before(from)
+	})
+	last := sort.Search(n, func(i int) bool {
+		return c.extremes[i].TideTime.After(to)
+	}) - 1
+
+	if first > 0 {
+		consider(from, c.extremes[first-1])
+	}
+	for i := first; i <= last; i++ {
+		consider(c.extremes[i].TideTime, c.extremes[i])
+	}
+	if last < n-1 {
+		consider(to, c.extremes[last+1])
+	}
+
+	return best, found
+}
@@ -0,0 +1,159 @@
+package floodrisk
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// testDay is the first day of the test tides
+var testDay = time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC)
+
+// at returns the moment hours after the start of the test day
+func at(hours float64) time.Time {
+	return testDay.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+// testExtremes are two semi-diurnal tidal cycles at Sekupang, unordered
+func testExtremes() []models.TideData {
+	return []models.TideData{
+		{ID: "h1", Location: "Sekupang", TideType: models.TideTypeHigh, TideTime: at(6), HeightM: 2.9},
+		{ID: "l0", Location: "Sekupang", TideType: models.TideTypeLow, TideTime: at(0), HeightM: 0.5},
+		{ID: "l1", Location: "Sekupang", TideType: models.TideTypeLow, TideTime: at(12), HeightM: 0.7},
+		{ID: "h2", Location: "Sekupang", TideType: models.TideTypeHigh, TideTime: at(18), HeightM: 2.7},
+		{ID: "l2", Location: "Sekupang", TideType: models.TideTypeLow, TideTime: at(24), HeightM: 0.4},
+	}
+}
+
+func TestTideCurveLevel(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		want   float64
+		wantOk bool
+	}{
+		{"low tide", at(0), 0.5, true},
+		{"high tide", at(6), 2.9, true},
+		{"last extreme", at(24), 0.4, true},
+		// Half a cosine: a quarter of the range at a third of the rise, half of it midway, three quarters at two thirds
+		{"third of rise", at(2), 0.5 + 2.4*0.25, true},
+		{"midway rise", at(3), 0.5 + 2.4*0.5, true},
+		{"two thirds of rise", at(4), 0.5 + 2.4*0.75, true},
+		{"quarter of rise", at(1.5), 0.5 + 2.4*(1-math.Cos(math.Pi/4))/2, true},
+		{"midway fall", at(9), 2.9 - 2.2*0.5, true},
+		{"before curve", at(-1), 0, false},
+		{"after curve", at(25), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := curve.Level(tt.at)
+			if ok != tt.wantOk || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Level(%s) = %.4f, %v, want %.4f, %v", tt.at.Format("15:04"), got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTideCurveAround(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		previous string
+		next     string
+		wantOk   bool
+	}{
+		{"between", at(3), "l0", "h1", true},
+		{"on extreme", at(6), "h1", "l1", true},
+		{"first extreme", at(0), "l0", "h1", true},
+		{"last extreme", at(24), "l2", "l2", true},
+		{"before curve", at(-1), "", "", false},
+		{"after curve", at(24.5), "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, next, ok := curve.Around(tt.at)
+			if ok != tt.wantOk || previous.ID != tt.previous || next.ID != tt.next {
+				t.Errorf("Around() = %s, %s, %v, want %s, %s, %v", previous.ID, next.ID, ok, tt.previous, tt.next, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTideCurveCycle(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		start time.Time
+		end   time.Time
+	}{
+		{"high tide", at(6), at(0), at(12)},
+		{"second high tide", at(18), at(12), at(24)},
+		{"first extreme", at(0), at(0), at(6)},
+		{"last extreme", at(24), at(18), at(24)},
+		{"between extremes", at(8), at(6), at(12)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := curve.Cycle(tt.at)
+			if !start.Equal(tt.start) || !end.Equal(tt.end) {
+				t.Errorf("Cycle() = %s - %s, want %s - %s", start.Format("15:04"), end.Format("15:04"), tt.start.Format("15:04"), tt.end.Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestTideCurveMaxLevel(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		wantOk   bool
+		wantAt   time.Time
+		wantM    float64
+		wantTide string
+	}{
+		{"around high tide", at(5), at(7), true, at(6), 2.9, "h1"},
+		{"both high tides", at(5), at(19), true, at(6), 2.9, "h1"},
+		{"ending between extremes", at(1), at(4), true, at(4), 0.5 + 2.4*0.75, "h1"},
+		{"starting between extremes", at(8), at(11), true, at(8), 2.9 - 2.2*0.25, "h1"},
+		{"falling then next high tide", at(10), at(20), true, at(18), 2.7, "h2"},
+		{"rising to second high tide", at(13), at(16), true, at(16), 0.7 + 2.0*0.75, "h2"},
+		{"starting before curve", at(-2), at(2), true, at(2), 0.5 + 2.4*0.25, "h1"},
+		{"ending after curve", at(20), at(30), true, at(20), 2.7 - 2.3*0.25, "h2"},
+		{"before curve", at(-5), at(-1), false, time.Time{}, 0, ""},
+		{"after curve", at(25), at(30), false, time.Time{}, 0, ""},
+		{"reversed", at(7), at(5), false, time.Time{}, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := curve.MaxLevel(tt.from, tt.to)
+			if ok != tt.wantOk {
+				t.Fatalf("MaxLevel() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if !got.Time.Equal(tt.wantAt) || math.Abs(got.HeightM-tt.wantM) > 1e-9 || got.Tide.ID != tt.wantTide {
+				t.Errorf("MaxLevel() = %.4fm at %s (tide %s), want %.4fm at %s (tide %s)",
+					got.HeightM, got.Time.Format("15:04"), got.Tide.ID, tt.wantM, tt.wantAt.Format("15:04"), tt.wantTide)
+			}
+		})
+	}
+
+	if _, ok := NewTideCurve(testExtremes()[:1]).MaxLevel(at(0), at(24)); ok {
+		t.Error("MaxLevel() of a single extreme ok = true, want false")
+	}
+}
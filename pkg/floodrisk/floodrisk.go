@@ -0,0 +1,198 @@
+package floodrisk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// Risk levels
+const (
+	LevelNone     = "none"
+	LevelLow      = "low"
+	LevelModerate = "moderate"
+	LevelHigh     = "high"
+	LevelExtreme  = "extreme"
+	LevelUnknown  = "unknown"
+)
+
+// LevelRank orders the risk levels from none (0) to extreme (4), unknown levels rank as none
+func LevelRank(level string) int {
+	switch level {
+	case LevelLow:
+		return 1
+	case LevelModerate:
+		return 2
+	case LevelHigh:
+		return 3
+	case LevelExtreme:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// SeverityRank orders the CAP severities from unknown (0) to extreme (4)
+func SeverityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "minor":
+		return 1
+	case "moderate":
+		return 2
+	case "severe":
+		return 3
+	case "extreme":
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Assessment is the tidal flood risk of a single alert
+type Assessment struct {
+	HasRisk bool
+	// Level is the band of the score
+	Level string
+	// Score is the 0-100 risk score
+	Score     int
+	Message   string
+	HeavyRain bool
+	// RainIntensity is the rain intensity announced by the alert
+	RainIntensity RainIntensity
+	// Rule is the name of the rule that fired, empty when none did
+	Rule string
+	// Tide is the high tide of the tidal cycle matched by the rule, nil when no rule fired
+	Tide *models.TideData
+	// WaterLevel is the highest predicted (astronomical) water level inside the window of the rule that fired,
+	// nil when none did
+	WaterLevel *CurvePoint
+	// SurgeM is the surge offset of the alert event and AnomalyM the reported sea level anomaly at the water level,
+	// both added to the astronomical heights before comparing them with the rule thresholds
+	SurgeM   float64
+	AnomalyM float64
+	// Trace reports the evaluation of every rule, in order
+	Trace []RuleTrace
+}
+
+// AssessBatch evaluates the rules against many alerts, stations[i] being the station of alerts[i].
+// The tide curves and sea level anomalies of the union of the alert periods are loaded at once and evaluated in memory.
+func (rs *RuleSet) AssessBatch(tides *TideCache, alerts []weathermodels.AlertDetail, stations []string) ([]Assessment, error) {
+	if len(alerts) != len(stations) {
+		return nil, fmt.Errorf("got %d stations for %d alerts", len(stations), len(alerts))
+	}
+	if len(alerts) == 0 {
+		return []Assessment{}, nil
+	}
+
+	// Sea level rises gradually before the high tide peak, so water levels shortly after
+	// the alert expires are still considered (up to the largest rule buffer)
+	buffer := rs.MaxBuffer()
+	from, to := alerts[0].Effective, alerts[0].Expires.Add(buffer)
+	for _, alert := range alerts[1:] {
+		if alert.Effective.Before(from) {
+			from = alert.Effective
+		}
+		if end := alert.Expires.Add(buffer); end.After(to) {
+			to = end
+		}
+	}
+
+	curves := make(map[string]*TideCurve)
+	anomalies := make(map[string][]models.SeaLevelAnomaly)
+	for _, station := range stations {
+		if _, ok := curves[station]; ok {
+			continue
+		}
+
+		curve, err := tides.Curve(station, from, to)
+		if err != nil {
+			return nil, err
+		}
+		curves[station] = curve
+
+		if anomalies[station], err = tides.Anomalies(station, from, to); err != nil {
+			return nil, err
+		}
+	}
+
+	assessments := make([]Assessment, len(alerts))
+	for i, alert := range alerts {
+		assessments[i] = rs.assessCurve(alert, curves[stations[i]], anomalies[stations[i]])
+	}
+
+	return assessments, nil
+}
+
+// assessCurve evaluates the rules in order against an alert and the tide curve and anomalies of its station.
+// The first rule matching the alert whose highest water level inside its window, raised by the surge and the anomaly
+// valid at each moment, exceeds its threshold fires.
+func (rs *RuleSet) assessCurve(alert weathermodels.AlertDetail, curve *TideCurve, anomalies []models.SeaLevelAnomaly) Assessment {
+	surge, surgeName := rs.SurgeFor(alert)
+
+	assessment := Assessment{
+		SurgeM:        surge,
+		Level:         LevelNone,
+		HeavyRain:     rs.HasHeavyRain(alert),
+		RainIntensity: ClassifyRain(alert),
+		Trace:         make([]RuleTrace, 0, len(rs.Rules)),
+	}
+
+	for _, rule := range rs.Rules {
+		if assessment.Rule != "" {
+			assessment.Trace = append(assessment.Trace, RuleTrace{Rule: rule.Name, Reason: "skipped, a previous rule fired"})
+			continue
+		}
+
+		// Tide only rules describe windows without any alert
+		if rule.Overlap == OverlapNone {
+			assessment.Trace = append(assessment.Trace, RuleTrace{Rule: rule.Name, Reason: "skipped, rule applies without alert only"})
+			continue
+		}
+
+		if ok, reason := rule.matchesAlert(alert); !ok {
+			assessment.Trace = append(assessment.Trace, RuleTrace{Rule: rule.Name, Reason: reason})
+			continue
+		}
+
+		start, end := rule.window(alert)
+		level, anomaly, ok := maxAdjustedLevel(curve, anomalies, start, end)
+		adjusted := CurvePoint{Time: level.Time, HeightM: level.HeightM + surge + anomaly}
+		if !ok || adjusted.HeightM <= rule.TideHeightAbove {
+			assessment.Trace = append(assessment.Trace, RuleTrace{
+				Rule:   rule.Name,
+				Reason: fmt.Sprintf("no water level above %.2fm %s", rule.TideHeightAbove, rule.overlapDescription()),
+			})
+			continue
+		}
+
+		assessment.HasRisk = true
+		assessment.Score = rule.score(adjusted, &alert)
+		assessment.Level = LevelForScore(assessment.Score)
+		assessment.Message = rule.Message
+		assessment.Rule = rule.Name
+		assessment.Tide = &level.Tide
+		assessment.WaterLevel = &level.CurvePoint
+		assessment.AnomalyM = anomaly
+		assessment.Trace = append(assessment.Trace, RuleTrace{
+			Rule:    rule.Name,
+			Matched: true,
+			Reason: fmt.Sprintf("water level of %.2fm at %s (high tide of %.2fm at %s)%s",
+				adjusted.HeightM, level.Time.Format("2006-01-02 15:04 MST"),
+				level.Tide.HeightM, level.Tide.TideTime.Format("2006-01-02 15:04 MST"),
+				adjustmentDescription(level.HeightM, surge, surgeName, anomaly)),
+		})
+	}
+
+	if assessment.Rule == "" {
+		if assessment.HeavyRain {
+			assessment.Message = i18n.T(i18n.Default, "risk.no_tide")
+		} else {
+			assessment.Message = i18n.T(i18n.Default, "risk.no_rain")
+		}
+	}
+
+	return assessment
+}
@@ -0,0 +1,123 @@
+package floodrisk
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// RuleObservedFlooding is the rule name of assessments and windows overridden by a sensor observing flooding
+const RuleObservedFlooding = "sensor-observed-flooding"
+
+// LatestReading returns the latest sensor reading of a home taken since a moment, nil when there is none
+func LatestReading(db *gorm.DB, homeID string, since time.Time) (*models.SensorReading, error) {
+	var reading models.SensorReading
+	err := db.Where("home_id = ? AND read_at >= ?", homeID, since).
+		Order("read_at DESC").
+		First(&reading).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+
+	return &reading, nil
+}
+
+// LiveReading returns the latest sensor reading of a home within the live period, nil when there is none or without MQTT
+func LiveReading(db *gorm.DB, cfg *config.Config, homeID string, now time.Time) (*models.SensorReading, error) {
+	mqttCfg := cfg.GetMQTTConfig()
+	if mqttCfg.BrokerURL == "" {
+		return nil, nil
+	}
+
+	return LatestReading(db, homeID, now.Add(-liveDuration(mqttCfg)))
+}
+
+// ObservedWindow returns the extreme risk window of a home whose live reading is flooded, nil otherwise.
+// It starts with the first flooded reading of the ongoing flooding and lasts the live period after the latest one.
+func ObservedWindow(db *gorm.DB, cfg *config.Config, home models.Home, now time.Time) (*Window, error) {
+	reading, err := LiveReading(db, cfg, home.ID, now)
+	if err != nil || reading == nil || !reading.Flooded {
+		return nil, err
+	}
+
+	since, err := floodedSince(db, *reading)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Window{
+		Start:    since,
+		End:      reading.ReadAt.Add(liveDuration(cfg.GetMQTTConfig())),
+		Level:    LevelExtreme,
+		Score:    100,
+		Message:  ObservedMessage(*reading, i18n.Default),
+		Rule:     RuleObservedFlooding,
+		Observed: reading,
+	}, nil
+}
+
+// WithObserved puts the observed window of a home, when any and overlapping from and to, ahead of its predicted windows
+func WithObserved(windows []Window, observed *Window, from, to time.Time) []Window {
+	if observed == nil || observed.End.Before(from) || observed.Start.After(to) {
+		return windows
+	}
+	return append([]Window{*observed}, windows...)
+}
+
+// ObservedMessage describes a flooded reading in the language
+func ObservedMessage(reading models.SensorReading, lang string) string {
+	return i18n.T(lang, "risk.observed", reading.LevelM, reading.ThresholdM)
+}
+
+// applyObserved overrides the predicted risk of an assessment with the observed flooding
+func (a *Assessment) applyObserved(observed Window) {
+	a.HasRisk = true
+	a.Level = observed.Level
+	a.Score = observed.Score
+	a.Rule = observed.Rule
+	a.Message = observed.Message
+	a.Trace = append(a.Trace, RuleTrace{
+		Rule:    observed.Rule,
+		Matched: true,
+		Reason: fmt.Sprintf("sensor %s reads %.2fm above the %.2fm threshold at %s, overriding the predicted risk",
+			observed.Observed.Sensor, observed.Observed.LevelM, observed.Observed.ThresholdM,
+			observed.Observed.ReadAt.Format("2006-01-02 15:04 MST")),
+	})
+}
+
+// floodedSince returns the time of the first flooded reading after the last reading below the threshold
+func floodedSince(db *gorm.DB, reading models.SensorReading) (time.Time, error) {
+	query := db.Where("home_id = ? AND flooded = ? AND read_at <= ?", reading.HomeID, true, reading.ReadAt)
+
+	var dry models.SensorReading
+	err := db.Where("home_id = ? AND flooded = ? AND read_at < ?", reading.HomeID, false, reading.ReadAt).
+		Order("read_at DESC").
+		First(&dry).Error
+	if err == nil {
+		query = query.Where("read_at > ?", dry.ReadAt)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+
+	var first models.SensorReading
+	if err := query.Order("read_at ASC").First(&first).Error; err != nil {
+		return time.Time{}, fmt.Errorf("failed to query sensor readings: %w", err)
+	}
+
+	return first.ReadAt, nil
+}
+
+// liveDuration is how long the latest reading of a home is used by the risk evaluation
+func liveDuration(cfg config.MQTTConfig) time.Duration {
+	return time.Duration(cfg.LiveMinutes) * time.Minute
+}
@@ -0,0 +1,80 @@
+package floodrisk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// testObserved is the window of a sensor flooded from 05:00 to 06:00, live for 15 minutes after the latest reading
+func testObserved() Window {
+	reading := &models.SensorReading{Sensor: "sensors/home", ReadAt: at(6), LevelM: 3.1, ThresholdM: 2.8, Flooded: true}
+	return Window{
+		Start:    at(5),
+		End:      at(6).Add(15 * time.Minute),
+		Level:    LevelExtreme,
+		Score:    100,
+		Message:  ObservedMessage(*reading, "en"),
+		Rule:     RuleObservedFlooding,
+		Observed: reading,
+	}
+}
+
+func TestWithObserved(t *testing.T) {
+	observed := testObserved()
+	predicted := []Window{{Start: at(17), End: at(19), Level: LevelLow}}
+
+	tests := []struct {
+		name     string
+		observed *Window
+		from, to time.Time
+		want     int
+	}{
+		{"no observed window", nil, at(0), at(24), 1},
+		{"ongoing", &observed, at(6), at(24), 2},
+		{"ended before from", &observed, at(7), at(24), 1},
+		{"starting after to", &observed, at(0), at(4), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := WithObserved(predicted, tt.observed, tt.from, tt.to)
+			if len(windows) != tt.want {
+				t.Fatalf("WithObserved() returned %d windows, want %d", len(windows), tt.want)
+			}
+			if tt.want == 2 && windows[0].Observed == nil {
+				t.Errorf("WithObserved() first window is %s, want the observed window", windows[0].Rule)
+			}
+		})
+	}
+}
+
+func TestAssessmentApplyObserved(t *testing.T) {
+	observed := testObserved()
+
+	tests := []struct {
+		name       string
+		assessment Assessment
+	}{
+		{"no predicted risk", Assessment{Level: LevelNone}},
+		{"predicted lower risk", Assessment{HasRisk: true, Level: LevelModerate, Score: 45, Rule: "spring-tide-rain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assessment := tt.assessment
+			assessment.applyObserved(observed)
+
+			if !assessment.HasRisk || assessment.Level != LevelExtreme || assessment.Score != 100 {
+				t.Errorf("applyObserved() = %v %s %d, want true %s 100", assessment.HasRisk, assessment.Level, assessment.Score, LevelExtreme)
+			}
+			if assessment.Rule != RuleObservedFlooding || assessment.Message != observed.Message {
+				t.Errorf("applyObserved() rule = %s, message = %q", assessment.Rule, assessment.Message)
+			}
+			if n := len(assessment.Trace); n == 0 || assessment.Trace[n-1].Rule != RuleObservedFlooding || !assessment.Trace[n-1].Matched {
+				t.Errorf("applyObserved() trace = %v, want a matched %s entry", assessment.Trace, RuleObservedFlooding)
+			}
+		})
+	}
+}
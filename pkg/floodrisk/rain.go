@@ -0,0 +1,91 @@
+package floodrisk
+
+import (
+	"regexp"
+	"strings"
+
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// RainIntensity is the rain intensity announced by an alert
+type RainIntensity string
+
+// Rain intensities, from none to extreme
+const (
+	RainNone      RainIntensity = "none"
+	RainLight     RainIntensity = "light"
+	RainModerate  RainIntensity = "moderate"
+	RainHeavy     RainIntensity = "heavy"
+	RainVeryHeavy RainIntensity = "very_heavy"
+	RainExtreme   RainIntensity = "extreme"
+)
+
+// rainIntensityRanks orders the intensities, unknown intensities rank as none
+var rainIntensityRanks = map[RainIntensity]int{
+	RainLight:     1,
+	RainModerate:  2,
+	RainHeavy:     3,
+	RainVeryHeavy: 4,
+	RainExtreme:   5,
+}
+
+// rainIntensityWords maps the intensity wording of BMKG alerts, in English and Indonesian, to the intensity
+var rainIntensityWords = map[string]RainIntensity{
+	"light":           RainLight,
+	"ringan":          RainLight,
+	"moderate":        RainModerate,
+	"sedang":          RainModerate,
+	"heavy":           RainHeavy,
+	"lebat":           RainHeavy,
+	"deras":           RainHeavy,
+	"tinggi":          RainHeavy,
+	"very heavy":      RainVeryHeavy,
+	"sangat lebat":    RainVeryHeavy,
+	"sangat deras":    RainVeryHeavy,
+	"sangat tinggi":   RainVeryHeavy,
+	"extreme":         RainExtreme,
+	"extremely heavy": RainExtreme,
+	"ekstrem":         RainExtreme,
+	"ekstrim":         RainExtreme,
+}
+
+// Intensity phrases: "moderate to heavy rain(fall)", "rain of heavy intensity",
+// "hujan (dengan intensitas) sedang hingga lebat" and "curah hujan tinggi". Ranges take their upper bound.
+var (
+	englishRain    = `(extremely heavy|extreme|very heavy|heavy|moderate|light)`
+	indonesianRain = `(sangat lebat|sangat deras|lebat|deras|sedang|ringan|ekstrem|ekstrim)`
+	rainRegexes    = []*regexp.Regexp{
+		regexp.MustCompile(`\b` + englishRain + `(?:\s*(?:to|-)\s*` + englishRain + `)?\s+rain`),
+		regexp.MustCompile(`\brain(?:fall)?\s+(?:of|with)\s+` + englishRain + `\s+intensity`),
+		regexp.MustCompile(`\bhujan\s+(?:dengan\s+intensitas\s+)?` + indonesianRain + `(?:\s*(?:hingga|sampai|-)\s*` + indonesianRain + `)?`),
+		regexp.MustCompile(`\bcurah\s+hujan\s+(?:yang\s+)?(sangat\s+tinggi|tinggi|sangat\s+lebat|lebat|sedang|ringan|ekstrem|ekstrim)`),
+	}
+)
+
+// Rank orders the intensity from none (0) to extreme (5)
+func (i RainIntensity) Rank() int {
+	return rainIntensityRanks[i]
+}
+
+// Valid reports whether the intensity is one of the known intensities
+func (i RainIntensity) Valid() bool {
+	return i == RainNone || i.Rank() > 0
+}
+
+// ClassifyRain returns the highest rain intensity announced in the alert event, headline or description
+func ClassifyRain(alert weathermodels.AlertDetail) RainIntensity {
+	text := strings.ToLower(strings.Join(strings.Fields(alert.Event+" . "+alert.Headline+" . "+alert.Description), " "))
+
+	intensity := RainNone
+	for _, re := range rainRegexes {
+		for _, match := range re.FindAllStringSubmatch(text, -1) {
+			for _, word := range match[1:] {
+				if found := rainIntensityWords[strings.Join(strings.Fields(word), " ")]; found.Rank() > intensity.Rank() {
+					intensity = found
+				}
+			}
+		}
+	}
+
+	return intensity
+}
@@ -0,0 +1,40 @@
+package floodrisk
+
+import (
+	"testing"
+
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+func TestClassifyRain(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert weathermodels.AlertDetail
+		want  RainIntensity
+	}{
+		{"no rain", weathermodels.AlertDetail{Event: "Strong wind", Description: "Angin kencang di wilayah Batam"}, RainNone},
+		{"english heavy", weathermodels.AlertDetail{Headline: "Heavy rain in Riau Islands"}, RainHeavy},
+		{"english very heavy", weathermodels.AlertDetail{Headline: "Very heavy rain in Riau Islands"}, RainVeryHeavy},
+		{"english range", weathermodels.AlertDetail{Description: "Moderate to heavy rainfall expected"}, RainHeavy},
+		{"english intensity", weathermodels.AlertDetail{Description: "Rain of moderate intensity accompanied by lightning"}, RainModerate},
+		{"english extreme", weathermodels.AlertDetail{Description: "Extremely heavy rain over Batam"}, RainExtreme},
+		{"indonesian lebat", weathermodels.AlertDetail{Description: "Hujan lebat disertai kilat/petir"}, RainHeavy},
+		{"indonesian sangat lebat", weathermodels.AlertDetail{Description: "Hujan sangat lebat disertai kilat/petir"}, RainVeryHeavy},
+		{"indonesian range", weathermodels.AlertDetail{Description: "hujan sedang hingga lebat"}, RainHeavy},
+		{"indonesian range to very heavy", weathermodels.AlertDetail{Description: "hujan lebat hingga sangat lebat"}, RainVeryHeavy},
+		{"indonesian intensity", weathermodels.AlertDetail{Description: "hujan dengan intensitas ringan"}, RainLight},
+		{"indonesian rainfall", weathermodels.AlertDetail{Description: "curah hujan sangat tinggi"}, RainVeryHeavy},
+		{"indonesian extreme", weathermodels.AlertDetail{Description: "Hujan ekstrem di Batam"}, RainExtreme},
+		{"case and whitespace", weathermodels.AlertDetail{Event: "HUJAN\n  SANGAT   LEBAT"}, RainVeryHeavy},
+		{"highest across fields", weathermodels.AlertDetail{Event: "Hujan lebat", Headline: "Light rain", Description: "hujan sangat lebat"}, RainVeryHeavy},
+		{"intensity without rain", weathermodels.AlertDetail{Description: "Gelombang tinggi dan angin sangat kencang"}, RainNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyRain(tt.alert); got != tt.want {
+				t.Errorf("ClassifyRain() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package floodrisk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// Overlap modes describing when the predicted water level must exceed the rule threshold, relative to the alert period.
+// The timeline checks the same window, limited to the tidal cycle of each high tide.
+const (
+	// OverlapDuring requires the water level above the threshold inside the alert period
+	OverlapDuring = "during"
+	// OverlapBuffer requires it after the alert expires, within the buffer (sea level rising during the alert)
+	OverlapBuffer = "buffer"
+	// OverlapAny accepts both during and buffer
+	OverlapAny = "any"
+	// OverlapNone does not require an alert, used for the flood risk timeline only
+	// (place it after the alert rules so it acts as fallback)
+	OverlapNone = "none"
+)
+
+// Rule is a single flood risk rule, all of its conditions must hold for it to fire
+type Rule struct {
+	Name string `json:"name"`
+	// Level is the minimum level when the rule fires, the score can raise it further
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	// Messages translates the message by language ("id": "..."), Message is used for the others
+	Messages map[string]string `json:"messages,omitempty"`
+	// TideHeightAbove is the height (meters) a high tide must exceed
+	TideHeightAbove float64 `json:"tide_height_above_m"`
+	// RainKeywords must appear in the alert description (any of them, case-insensitive), empty matches any alert
+	RainKeywords []string `json:"rain_keywords,omitempty"`
+	// MinRainIntensity is the lowest rain intensity the alert must announce (see ClassifyRain), empty matches any alert
+	MinRainIntensity RainIntensity `json:"min_rain_intensity,omitempty"`
+	// Severities and Urgencies restrict the alert CAP severity/urgency (any of them), empty matches any alert
+	Severities []string `json:"severities,omitempty"`
+	Urgencies  []string `json:"urgencies,omitempty"`
+	Overlap    string   `json:"overlap"`
+	// BufferMinutes is the time after the alert expires still considered for buffer overlaps
+	BufferMinutes int `json:"buffer_minutes,omitempty"`
+}
+
+// RuleSet is an ordered list of rules, the first rule that matches fires
+type RuleSet struct {
+	Version string `json:"version"`
+	Rules   []Rule `json:"rules"`
+	// Surge raises the predicted tide heights during strong wind or high wave alerts, see SurgeOffset
+	Surge []SurgeOffset `json:"surge,omitempty"`
+}
+
+// RuleTrace reports the evaluation result of a single rule
+type RuleTrace struct {
+	Rule    string `json:"rule"`
+	Matched bool   `json:"matched"`
+	Reason  string `json:"reason"`
+}
+
+// DefaultRuleSet returns the built-in rules: heavy rain (or more) with a high tide above 2.6m
+// during the alert is high risk, shortly (2 hours) after the alert is moderate risk.
+// Strong wind and high wave alerts raise the tide heights by 0.15-0.4m of surge.
+func DefaultRuleSet() *RuleSet {
+	return &RuleSet{
+		Version: "default-3",
+		Rules: []Rule{
+			{
+				Name:             "heavy-rain-during-high-tide",
+				Level:            LevelHigh,
+				Message:          "HIGH RISK: Heavy rain expected during high tide (>2.6m) - Flash flood possible!",
+				TideHeightAbove:  2.6,
+				MinRainIntensity: RainHeavy,
+				Overlap:          OverlapDuring,
+				Messages: map[string]string{
+					i18n.ID: "RISIKO TINGGI: Hujan lebat diperkirakan saat air pasang tinggi (>2.6m) - Banjir rob mungkin terjadi!",
+				},
+			},
+			{
+				Name:             "heavy-rain-before-high-tide",
+				Level:            LevelModerate,
+				Message:          "MODERATE RISK: Heavy rain with high tide (>2.6m) shortly after - Sea level rising during alert period",
+				TideHeightAbove:  2.6,
+				MinRainIntensity: RainHeavy,
+				Overlap:          OverlapBuffer,
+				BufferMinutes:    120,
+				Messages: map[string]string{
+					i18n.ID: "RISIKO SEDANG: Hujan lebat dengan air pasang tinggi (>2.6m) tak lama setelahnya - Permukaan laut naik selama periode peringatan",
+				},
+			},
+			{
+				Name:            "high-tide-without-rain",
+				Level:           LevelLow,
+				Message:         "LOW RISK: High tide (>2.6m) without heavy rain forecast",
+				TideHeightAbove: 2.6,
+				Overlap:         OverlapNone,
+				BufferMinutes:   120,
+				Messages: map[string]string{
+					i18n.ID: "RISIKO RENDAH: Air pasang tinggi (>2.6m) tanpa prakiraan hujan lebat",
+				},
+			},
+		},
+		Surge: defaultSurge(),
+	}
+}
+
+// LoadRuleSet reads and validates a JSON rule set, the default rules are used when path is empty
+func LoadRuleSet(path string) (*RuleSet, error) {
+	if path == "" {
+		return DefaultRuleSet(), nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read risk rules: %w", err)
+	}
+
+	var rs RuleSet
+	if err := json.Unmarshal(content, &rs); err != nil {
+		return nil, fmt.Errorf("failed to parse risk rules: %w", err)
+	}
+
+	if err := rs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid risk rules in %s: %w", path, err)
+	}
+
+	return &rs, nil
+}
+
+// Validate checks the rule set for missing or invalid fields
+func (rs *RuleSet) Validate() error {
+	if len(rs.Rules) == 0 {
+		return fmt.Errorf("no rules defined")
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range rs.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule #%d: name is required", i+1)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+
+		switch rule.Level {
+		case LevelLow, LevelModerate, LevelHigh, LevelExtreme:
+		default:
+			return fmt.Errorf("rule %s: level must be one of low, moderate, high, extreme", rule.Name)
+		}
+
+		switch rule.Overlap {
+		case OverlapDuring, OverlapBuffer, OverlapAny, OverlapNone:
+		default:
+			return fmt.Errorf("rule %s: overlap must be one of during, buffer, any, none", rule.Name)
+		}
+
+		if rule.Message == "" {
+			return fmt.Errorf("rule %s: message is required", rule.Name)
+		}
+		if rule.MinRainIntensity != "" && !rule.MinRainIntensity.Valid() {
+			return fmt.Errorf("rule %s: min_rain_intensity must be one of light, moderate, heavy, very_heavy, extreme", rule.Name)
+		}
+		for lang := range rule.Messages {
+			if !i18n.Supported(lang) {
+				return fmt.Errorf("rule %s: messages language must be one of: %s", rule.Name, strings.Join(i18n.Languages(), ", "))
+			}
+		}
+		if rule.TideHeightAbove <= 0 {
+			return fmt.Errorf("rule %s: tide_height_above_m must be positive", rule.Name)
+		}
+		if rule.BufferMinutes < 0 {
+			return fmt.Errorf("rule %s: buffer_minutes must not be negative", rule.Name)
+		}
+		if rule.BufferMinutes == 0 && (rule.Overlap == OverlapBuffer || rule.Overlap == OverlapNone) {
+			return fmt.Errorf("rule %s: buffer_minutes is required for %s overlap", rule.Name, rule.Overlap)
+		}
+	}
+
+	return rs.validateSurge()
+}
+
+// WithThreshold returns a copy of the rules shifted so the lowest tide height threshold equals threshold,
+// keeping the differences between rules. The version records the threshold so stored assessments
+// are recomputed when it changes. A threshold of 0 returns the rules unchanged.
+func (rs *RuleSet) WithThreshold(threshold float64) *RuleSet {
+	if threshold <= 0 {
+		return rs
+	}
+
+	shift := threshold - rs.MinTideHeight()
+	shifted := &RuleSet{
+		Version: fmt.Sprintf("%s@%.2fm", rs.Version, threshold),
+		Rules:   make([]Rule, len(rs.Rules)),
+		Surge:   rs.Surge,
+	}
+	for i, rule := range rs.Rules {
+		rule.TideHeightAbove += shift
+		shifted.Rules[i] = rule
+	}
+
+	return shifted
+}
+
+// LocalizedMessage returns the message of an assessment in the language: the message of the rule that fired,
+// or the no risk message when none did. The stored message is kept when the rule is no longer defined.
+func (rs *RuleSet) LocalizedMessage(rule string, heavyRain bool, message, lang string) string {
+	if rule == "" {
+		if heavyRain {
+			return i18n.T(lang, "risk.no_tide")
+		}
+		return i18n.T(lang, "risk.no_rain")
+	}
+
+	for _, r := range rs.Rules {
+		if r.Name != rule {
+			continue
+		}
+		if translated, ok := r.Messages[lang]; ok {
+			return translated
+		}
+		return r.Message
+	}
+
+	return message
+}
+
+// MinTideHeight returns the lowest tide height threshold of all rules, used to pre-filter tide queries
+func (rs *RuleSet) MinTideHeight() float64 {
+	height := rs.Rules[0].TideHeightAbove
+	for _, rule := range rs.Rules {
+		if rule.TideHeightAbove < height {
+			height = rule.TideHeightAbove
+		}
+	}
+	return height
+}
+
+// MaxBuffer returns the largest buffer of all rules
+func (rs *RuleSet) MaxBuffer() time.Duration {
+	var buffer time.Duration
+	for _, rule := range rs.Rules {
+		if rule.buffer() > buffer {
+			buffer = rule.buffer()
+		}
+	}
+	return buffer
+}
+
+// HasHeavyRain checks whether the alert announces heavy rain or more, or its description contains a rain keyword of any rule
+func (rs *RuleSet) HasHeavyRain(alert weathermodels.AlertDetail) bool {
+	if ClassifyRain(alert).Rank() >= RainHeavy.Rank() {
+		return true
+	}
+
+	descLower := strings.ToLower(alert.Description)
+	for _, rule := range rs.Rules {
+		for _, keyword := range rule.RainKeywords {
+			if strings.Contains(descLower, strings.ToLower(keyword)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// buffer returns the rule buffer as duration
+func (r Rule) buffer() time.Duration {
+	return time.Duration(r.BufferMinutes) * time.Minute
+}
+
+// matchesAlert checks the alert conditions of the rule, returning the reason when it doesn't match
+func (r Rule) matchesAlert(alert weathermodels.AlertDetail) (bool, string) {
+	if len(r.RainKeywords) > 0 {
+		descLower := strings.ToLower(alert.Description)
+		found := false
+		for _, keyword := range r.RainKeywords {
+			if strings.Contains(descLower, strings.ToLower(keyword)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, fmt.Sprintf("description has none of the rain keywords %v", r.RainKeywords)
+		}
+	}
+
+	if r.MinRainIntensity != "" {
+		if intensity := ClassifyRain(alert); intensity.Rank() < r.MinRainIntensity.Rank() {
+			return false, fmt.Sprintf("rain intensity '%s' below %s", intensity, r.MinRainIntensity)
+		}
+	}
+
+	if len(r.Severities) > 0 && !containsFold(r.Severities, alert.Severity) {
+		return false, fmt.Sprintf("severity '%s' not in %v", alert.Severity, r.Severities)
+	}
+
+	if len(r.Urgencies) > 0 && !containsFold(r.Urgencies, alert.Urgency) {
+		return false, fmt.Sprintf("urgency '%s' not in %v", alert.Urgency, r.Urgencies)
+	}
+
+	return true, ""
+}
+
+// window returns the period in which the predicted water level must exceed the threshold for the rule to fire
+func (r Rule) window(alert weathermodels.AlertDetail) (time.Time, time.Time) {
+	switch r.Overlap {
+	case OverlapBuffer:
+		return alert.Expires, alert.Expires.Add(r.buffer())
+	case OverlapAny:
+		return alert.Effective, alert.Expires.Add(r.buffer())
+	default:
+		return alert.Effective, alert.Expires
+	}
+}
+
+// overlapDescription describes the overlap condition for rule traces
+func (r Rule) overlapDescription() string {
+	switch r.Overlap {
+	case OverlapDuring:
+		return "during the alert period"
+	case OverlapBuffer:
+		return fmt.Sprintf("within %d minutes after the alert expires", r.BufferMinutes)
+	case OverlapAny:
+		return fmt.Sprintf("during or within %d minutes after the alert period", r.BufferMinutes)
+	default:
+		return "without alert"
+	}
+}
+
+// containsFold checks whether the value is in the list, case-insensitive
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package floodrisk
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// Maximum points of each score component, adding up to 100
+const (
+	maxTideScore      = 30
+	maxProximityScore = 25
+	maxAlertScore     = 20
+	maxRainScore      = 25
+)
+
+// tideExcessForMaxScore is the height (meters) above the rule threshold earning the full tide score
+const tideExcessForMaxScore = 0.5
+
+// Points for the CAP severity, certainty and urgency of an alert
+var (
+	severityScores  = map[string]int{"extreme": 10, "severe": 7, "moderate": 4, "minor": 1}
+	certaintyScores = map[string]int{"observed": 5, "likely": 4, "possible": 2}
+	urgencyScores   = map[string]int{"immediate": 5, "expected": 4, "future": 2}
+)
+
+// rainIntensityScores are the points of the rain intensity announced by the alert
+var rainIntensityScores = map[RainIntensity]int{
+	RainLight:     4,
+	RainModerate:  10,
+	RainHeavy:     18,
+	RainVeryHeavy: 25,
+	RainExtreme:   25,
+}
+
+// LevelForScore returns the risk level band of a score
+func LevelForScore(score int) string {
+	switch {
+	case score >= 75:
+		return LevelExtreme
+	case score >= 50:
+		return LevelHigh
+	case score >= 25:
+		return LevelModerate
+	case score > 0:
+		return LevelLow
+	default:
+		return LevelNone
+	}
+}
+
+// minScoreForLevel returns the lowest score of a level band, so a fired rule is never scored below its level
+func minScoreForLevel(level string) int {
+	switch level {
+	case LevelExtreme:
+		return 75
+	case LevelHigh:
+		return 50
+	case LevelModerate:
+		return 25
+	case LevelLow:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// score computes the 0-100 risk score of a water level firing the rule, alert is nil for rules without alert.
+// Components: water level above threshold, its proximity to the alert period,
+// alert severity/certainty/urgency and rain intensity.
+func (r Rule) score(level CurvePoint, alert *weathermodels.AlertDetail) int {
+	excess := math.Max(0, level.HeightM-r.TideHeightAbove)
+	score := int(math.Round(math.Min(1, excess/tideExcessForMaxScore) * maxTideScore))
+
+	if alert != nil {
+		score += proximityScore(level, *alert, r.buffer())
+		score += severityScores[strings.ToLower(alert.Severity)] +
+			certaintyScores[strings.ToLower(alert.Certainty)] +
+			urgencyScores[strings.ToLower(alert.Urgency)]
+		score += rainScore(*alert)
+	}
+
+	score = max(score, minScoreForLevel(r.Level))
+	return min(score, 100)
+}
+
+// proximityScore scores how close the water level is to the alert period: full points during the alert,
+// decreasing linearly over the buffer after the alert expires
+func proximityScore(level CurvePoint, alert weathermodels.AlertDetail, buffer time.Duration) int {
+	if level.Time.Before(alert.Effective) {
+		return 0
+	}
+	if !level.Time.After(alert.Expires) {
+		return maxProximityScore
+	}
+
+	delay := level.Time.Sub(alert.Expires)
+	if buffer <= 0 || delay > buffer {
+		return 0
+	}
+
+	return int(math.Round(float64(maxProximityScore) * (1 - float64(delay)/float64(buffer))))
+}
+
+// rainScore scores the rain intensity announced by the alert
+func rainScore(alert weathermodels.AlertDetail) int {
+	return rainIntensityScores[ClassifyRain(alert)]
+}
@@ -0,0 +1,39 @@
+package floodrisk
+
+import "testing"
+
+func TestLevelForScore(t *testing.T) {
+	tests := []struct {
+		score int
+		want  string
+	}{
+		{-5, LevelNone},
+		{0, LevelNone},
+		{1, LevelLow},
+		{24, LevelLow},
+		{25, LevelModerate},
+		{49, LevelModerate},
+		{50, LevelHigh},
+		{74, LevelHigh},
+		{75, LevelExtreme},
+		{100, LevelExtreme},
+	}
+
+	for _, tt := range tests {
+		if got := LevelForScore(tt.score); got != tt.want {
+			t.Errorf("LevelForScore(%d) = %s, want %s", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestMinScoreForLevel(t *testing.T) {
+	// The lowest score of every band must map back to the same band
+	for _, level := range []string{LevelLow, LevelModerate, LevelHigh, LevelExtreme} {
+		if got := LevelForScore(minScoreForLevel(level)); got != level {
+			t.Errorf("LevelForScore(minScoreForLevel(%s)) = %s", level, got)
+		}
+		if got := LevelForScore(minScoreForLevel(level) - 1); got == level {
+			t.Errorf("LevelForScore(minScoreForLevel(%s) - 1) = %s, want the band below", level, got)
+		}
+	}
+}
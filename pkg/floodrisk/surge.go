@@ -0,0 +1,175 @@
+package floodrisk
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// SurgeOffset raises the predicted tide heights during the alerts of an event type, for the wind-driven
+// storm surge the astronomical tides ignore. The largest offset of all matching surge entries applies.
+type SurgeOffset struct {
+	Name string `json:"name"`
+	// EventKeywords must appear in the alert event or headline (any of them, case-insensitive)
+	EventKeywords []string `json:"event_keywords"`
+	// Severities restrict the alert CAP severity (any of them), empty matches any alert
+	Severities []string `json:"severities,omitempty"`
+	// OffsetM is added to the predicted tide heights, in meters
+	OffsetM float64 `json:"offset_m"`
+}
+
+// defaultSurge are the built-in surge offsets for strong wind and high wave alerts
+func defaultSurge() []SurgeOffset {
+	return []SurgeOffset{
+		{Name: "strong-wind", EventKeywords: []string{"strong wind", "angin kencang"}, OffsetM: 0.15},
+		{Name: "strong-wind-severe", EventKeywords: []string{"strong wind", "angin kencang"}, Severities: []string{"Severe", "Extreme"}, OffsetM: 0.3},
+		{Name: "high-waves", EventKeywords: []string{"high wave", "gelombang tinggi"}, OffsetM: 0.2},
+		{Name: "high-waves-severe", EventKeywords: []string{"high wave", "gelombang tinggi"}, Severities: []string{"Severe", "Extreme"}, OffsetM: 0.4},
+	}
+}
+
+// SurgeFor returns the surge offset (meters) of an alert and the name of the surge entry, 0 when none matches
+func (rs *RuleSet) SurgeFor(alert weathermodels.AlertDetail) (float64, string) {
+	text := strings.ToLower(alert.Event + " " + alert.Headline)
+
+	offset, name := 0.0, ""
+	for _, surge := range rs.Surge {
+		if surge.OffsetM <= offset || !surge.matches(text, alert.Severity) {
+			continue
+		}
+		offset, name = surge.OffsetM, surge.Name
+	}
+
+	return offset, name
+}
+
+// MaxSurge returns the largest surge offset, used to pre-filter tide queries
+func (rs *RuleSet) MaxSurge() float64 {
+	offset := 0.0
+	for _, surge := range rs.Surge {
+		offset = max(offset, surge.OffsetM)
+	}
+	return offset
+}
+
+// validateSurge checks the surge entries of the rule set
+func (rs *RuleSet) validateSurge() error {
+	names := make(map[string]bool)
+	for i, surge := range rs.Surge {
+		if surge.Name == "" {
+			return fmt.Errorf("surge #%d: name is required", i+1)
+		}
+		if names[surge.Name] {
+			return fmt.Errorf("surge %s: duplicate name", surge.Name)
+		}
+		names[surge.Name] = true
+
+		if len(surge.EventKeywords) == 0 {
+			return fmt.Errorf("surge %s: event_keywords is required", surge.Name)
+		}
+		if surge.OffsetM <= 0 {
+			return fmt.Errorf("surge %s: offset_m must be positive", surge.Name)
+		}
+	}
+
+	return nil
+}
+
+// matches checks the surge conditions against the lowercase alert event and headline
+func (s SurgeOffset) matches(text, severity string) bool {
+	if len(s.Severities) > 0 && !containsFold(s.Severities, severity) {
+		return false
+	}
+
+	for _, keyword := range s.EventKeywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// anomalyAt returns the latest reported sea level anomaly valid at a moment, 0 when there is none
+func anomalyAt(anomalies []models.SeaLevelAnomaly, at time.Time) float64 {
+	var latest *models.SeaLevelAnomaly
+	for i := range anomalies {
+		anomaly := &anomalies[i]
+		if at.Before(anomaly.ValidFrom) || at.After(anomaly.ValidUntil) {
+			continue
+		}
+		if latest == nil || anomaly.ValidFrom.After(latest.ValidFrom) {
+			latest = anomaly
+		}
+	}
+
+	if latest == nil {
+		return 0
+	}
+	return latest.AnomalyM
+}
+
+// maxAdjustedLevel returns the highest water level between from and to once raised by the anomaly valid at each moment,
+// as the astronomical level and the anomaly added to it, false when the curve doesn't cover the period.
+// Anomalies only change at the bounds of their periods, so the period is split there and each piece is raised by its own.
+func maxAdjustedLevel(curve *TideCurve, anomalies []models.SeaLevelAnomaly, from, to time.Time) (WaterLevel, float64, bool) {
+	bounds := []time.Time{from, to}
+	for _, anomaly := range anomalies {
+		for _, bound := range []time.Time{anomaly.ValidFrom, anomaly.ValidUntil} {
+			if bound.After(from) && bound.Before(to) {
+				bounds = append(bounds, bound)
+			}
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i].Before(bounds[j])
+	})
+
+	var best WaterLevel
+	var bestAnomaly float64
+	found := false
+	consider := func(start, end time.Time, anomaly float64) {
+		level, ok := curve.MaxLevel(start, end)
+		if ok && (!found || level.HeightM+anomaly > best.HeightM+bestAnomaly) {
+			best, bestAnomaly, found = level, anomaly, true
+		}
+	}
+
+	// The bounds themselves, then the pieces between them
+	for i, bound := range bounds {
+		consider(bound, bound, anomalyAt(anomalies, bound))
+		if i+1 < len(bounds) && bounds[i+1].After(bound) {
+			consider(bound, bounds[i+1], anomalyAt(anomalies, bound.Add(bounds[i+1].Sub(bound)/2)))
+		}
+	}
+
+	return best, bestAnomaly, found
+}
+
+// maxAnomaly returns the largest positive anomaly, used to pre-filter tide queries
+func maxAnomaly(anomalies []models.SeaLevelAnomaly) float64 {
+	anomalyM := 0.0
+	for _, anomaly := range anomalies {
+		anomalyM = max(anomalyM, anomaly.AnomalyM)
+	}
+	return anomalyM
+}
+
+// adjustmentDescription describes the surge and anomaly added to an astronomical height for rule traces
+func adjustmentDescription(astronomical, surge float64, surgeName string, anomaly float64) string {
+	if surge == 0 && anomaly == 0 {
+		return ""
+	}
+
+	description := fmt.Sprintf(", astronomical %.2fm", astronomical)
+	if surge != 0 {
+		description += fmt.Sprintf(" + %.2fm %s surge", surge, surgeName)
+	}
+	if anomaly != 0 {
+		description += fmt.Sprintf(" %+.2fm anomaly", anomaly)
+	}
+	return description
+}
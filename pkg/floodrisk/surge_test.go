@@ -0,0 +1,88 @@
+package floodrisk
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// anomaly returns a sea level anomaly at Sekupang valid between the hours of the test day
+func anomaly(from, until float64, anomalyM float64) models.SeaLevelAnomaly {
+	return models.SeaLevelAnomaly{Location: "Sekupang", ValidFrom: at(from), ValidUntil: at(until), AnomalyM: anomalyM}
+}
+
+func TestMaxAdjustedLevel(t *testing.T) {
+	curve := NewTideCurve(testExtremes())
+
+	tests := []struct {
+		name        string
+		anomalies   []models.SeaLevelAnomaly
+		from, to    time.Time
+		wantAt      time.Time
+		wantM       float64
+		wantAnomaly float64
+	}{
+		{"no anomaly", nil, at(4), at(10), at(6), 2.9, 0},
+		{"anomaly at peak", []models.SeaLevelAnomaly{anomaly(5, 7, 0.2)}, at(4), at(10), at(6), 2.9, 0.2},
+		// The astronomical peak at 06:00 has no anomaly, 08:00 is lower but raised by 1m
+		{"anomaly after peak", []models.SeaLevelAnomaly{anomaly(8, 12, 1.0)}, at(4), at(10), at(8), 2.9 - 2.2*0.25, 1.0},
+		// Until 07:00 the peak is lowered, right after it the water is still high
+		{"negative anomaly at peak", []models.SeaLevelAnomaly{anomaly(5, 7, -0.5)}, at(4), at(8), at(7), 2.9 - 2.2*(1-math.Cos(math.Pi/6))/2, 0},
+		{"latest anomaly wins", []models.SeaLevelAnomaly{anomaly(0, 12, 0.1), anomaly(7, 12, 0.6)}, at(4), at(8), at(7), 2.9 - 2.2*(1-math.Cos(math.Pi/6))/2, 0.6},
+		{"anomaly outside window", []models.SeaLevelAnomaly{anomaly(14, 16, 2.0)}, at(4), at(10), at(6), 2.9, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, anomalyM, ok := maxAdjustedLevel(curve, tt.anomalies, tt.from, tt.to)
+			if !ok {
+				t.Fatal("maxAdjustedLevel() ok = false, want true")
+			}
+			if !level.Time.Equal(tt.wantAt) || math.Abs(level.HeightM-tt.wantM) > 1e-9 || anomalyM != tt.wantAnomaly {
+				t.Errorf("maxAdjustedLevel() = %.4fm %+.2fm at %s, want %.4fm %+.2fm at %s",
+					level.HeightM, anomalyM, level.Time.Format("15:04"), tt.wantM, tt.wantAnomaly, tt.wantAt.Format("15:04"))
+			}
+		})
+	}
+
+	if _, _, ok := maxAdjustedLevel(curve, nil, at(25), at(30)); ok {
+		t.Error("maxAdjustedLevel() outside the curve ok = true, want false")
+	}
+}
+
+func TestAssessBatchAnomalyAfterPeak(t *testing.T) {
+	rules := &RuleSet{
+		Version: "test",
+		Rules: []Rule{
+			{Name: "rain-during", Level: LevelHigh, Message: "High tide during rain", TideHeightAbove: 3.0, Overlap: OverlapDuring},
+		},
+	}
+	alert := weathermodels.AlertDetail{ID: "alert", Event: "Hujan Lebat", Effective: at(4), Expires: at(10)}
+
+	// The astronomical peak of 2.9m stays below 3.0m, the anomaly reported from 08:00 raises the water above it
+	assessments, err := rules.AssessBatch(testTideCache(anomaly(8, 12, 1.0)), []weathermodels.AlertDetail{alert}, []string{"Sekupang"})
+	if err != nil {
+		t.Fatalf("AssessBatch() error = %v", err)
+	}
+
+	assessment := assessments[0]
+	if assessment.Rule != "rain-during" {
+		t.Fatalf("AssessBatch() rule = %q, want rain-during", assessment.Rule)
+	}
+	if assessment.AnomalyM != 1.0 || !assessment.WaterLevel.Time.Equal(at(8)) || assessment.Tide.ID != "h1" {
+		t.Errorf("AssessBatch() = %+.2fm anomaly at %s (tide %s), want +1.00m at 08:00 (tide h1)",
+			assessment.AnomalyM, assessment.WaterLevel.Time.Format("15:04"), assessment.Tide.ID)
+	}
+
+	// Without the anomaly the rule doesn't fire
+	assessments, err = rules.AssessBatch(testTideCache(), []weathermodels.AlertDetail{alert}, []string{"Sekupang"})
+	if err != nil {
+		t.Fatalf("AssessBatch() error = %v", err)
+	}
+	if assessments[0].Rule != "" {
+		t.Errorf("AssessBatch() without anomaly rule = %q, want none", assessments[0].Rule)
+	}
+}
@@ -0,0 +1,144 @@
+package floodrisk
+
+import (
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+	"gorm.io/gorm"
+)
+
+// Window is a period of flood risk around a high tide
+type Window struct {
+	Start   time.Time
+	End     time.Time
+	Level   string
+	Score   int
+	Message string
+	// Rule is the name of the rule that fired for the tide
+	Rule string
+	// Tide is the high tide causing the window, with its astronomical height
+	Tide models.TideData
+	// SurgeM and AnomalyM were added to the highest water level of the rule window before evaluating the rules
+	SurgeM   float64
+	AnomalyM float64
+	// Alert is the alert matched by the rule, nil for rules without alert
+	Alert *weathermodels.AlertDetail
+	// Observed is the flooded sensor reading of an observed window, which has no tide
+	Observed *models.SensorReading
+}
+
+// WindowMessage returns the message of a window in the language, observed windows describe their reading
+func (rs *RuleSet) WindowMessage(window Window, lang string) string {
+	if window.Observed != nil {
+		return ObservedMessage(*window.Observed, lang)
+	}
+	return rs.LocalizedMessage(window.Rule, true, window.Message, lang)
+}
+
+// Timeline evaluates the flood risk windows of a station between from and to.
+// Every high tide firing a rule opens a window spanning the largest rule buffer around its peak.
+// Like the assessments, a rule fires on the highest water level of the tide curve inside its alert window,
+// limited to the tidal cycle of the high tide and raised by the sea level anomaly valid at each moment
+// and, for rules with an alert, the alert surge.
+func (rs *RuleSet) Timeline(tides *TideCache, station string, alerts []weathermodels.AlertDetail, from, to time.Time) ([]Window, error) {
+	buffer := rs.MaxBuffer()
+
+	anomalies, err := tides.Anomalies(station, from.Add(-buffer), to.Add(buffer))
+	if err != nil {
+		return nil, err
+	}
+
+	// Lower tides can only fire a rule once raised by the surge and anomaly
+	minHeight := rs.MinTideHeight() - rs.MaxSurge() - maxAnomaly(anomalies)
+	tideData, err := tides.HighTides(station, from.Add(-buffer), to.Add(buffer), minHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	curve, err := tides.Curve(station, from.Add(-buffer), to.Add(buffer))
+	if err != nil {
+		return nil, err
+	}
+
+	windows := make([]Window, 0, len(tideData))
+	for _, tide := range tideData {
+		rule, alert, level, surge, anomaly := rs.evaluateTide(curve, tide, alerts, anomalies)
+		if rule == nil {
+			continue
+		}
+
+		score := rule.score(CurvePoint{Time: level.Time, HeightM: level.HeightM + surge + anomaly}, alert)
+		windows = append(windows, Window{
+			Start:    tide.TideTime.Add(-buffer),
+			End:      tide.TideTime.Add(buffer),
+			Level:    LevelForScore(score),
+			Score:    score,
+			Message:  rule.Message,
+			Rule:     rule.Name,
+			Tide:     tide,
+			SurgeM:   surge,
+			AnomalyM: anomaly,
+			Alert:    alert,
+		})
+	}
+
+	return windows, nil
+}
+
+// evaluateTide returns the first rule firing for the tidal cycle of a high tide, the alert it matched,
+// the astronomical water level inside the rule window that is highest once raised by the anomaly, the alert surge and that anomaly
+func (rs *RuleSet) evaluateTide(curve *TideCurve, tide models.TideData, alerts []weathermodels.AlertDetail, anomalies []models.SeaLevelAnomaly) (*Rule, *weathermodels.AlertDetail, CurvePoint, float64, float64) {
+	cycleStart, cycleEnd := curve.Cycle(tide.TideTime)
+
+	// Rules without alert take the highest level of the whole cycle, the peak when the curve can't tell
+	cycleLevel, cycleAnomaly := CurvePoint{Time: tide.TideTime, HeightM: tide.HeightM}, anomalyAt(anomalies, tide.TideTime)
+	if level, anomaly, ok := maxAdjustedLevel(curve, anomalies, cycleStart, cycleEnd); ok && level.Tide.TideTime.Equal(tide.TideTime) {
+		cycleLevel, cycleAnomaly = level.CurvePoint, anomaly
+	}
+
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+
+		if rule.Overlap == OverlapNone {
+			if cycleLevel.HeightM+cycleAnomaly > rule.TideHeightAbove {
+				return rule, nil, cycleLevel, 0, cycleAnomaly
+			}
+			continue
+		}
+
+		for j := range alerts {
+			if ok, _ := rule.matchesAlert(alerts[j]); !ok {
+				continue
+			}
+
+			start, end := rule.window(alerts[j])
+			if start.Before(cycleStart) {
+				start = cycleStart
+			}
+			if end.After(cycleEnd) {
+				end = cycleEnd
+			}
+
+			level, anomaly, ok := maxAdjustedLevel(curve, anomalies, start, end)
+			if !ok || !level.Tide.TideTime.Equal(tide.TideTime) {
+				continue
+			}
+
+			surge, _ := rs.SurgeFor(alerts[j])
+			if level.HeightM+surge+anomaly > rule.TideHeightAbove {
+				return rule, &alerts[j], level.CurvePoint, surge, anomaly
+			}
+		}
+	}
+
+	return nil, nil, CurvePoint{}, 0, 0
+}
+
+// TimelineAlertsQuery builds the query of the alerts of the provinces that can overlap a tide window between from and to
+func (rs *RuleSet) TimelineAlertsQuery(db *gorm.DB, provinces []string, from, to time.Time) *gorm.DB {
+	buffer := rs.MaxBuffer()
+	return db.Model(&weathermodels.AlertDetail{}).
+		Where("area_description IN ?", provinces).
+		Where("effective <= ? AND expires >= ?", to.Add(buffer), from.Add(-2*buffer))
+}
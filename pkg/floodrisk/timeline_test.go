@@ -0,0 +1,69 @@
+package floodrisk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+)
+
+// testTideCache returns a cache loaded with the test tides and anomalies, without database
+func testTideCache(anomalies ...models.SeaLevelAnomaly) *TideCache {
+	return &TideCache{
+		loaded:    true,
+		from:      at(-48),
+		to:        at(72),
+		tides:     map[string][]models.TideData{"Sekupang": NewTideCurve(testExtremes()).extremes},
+		anomalies: map[string][]models.SeaLevelAnomaly{"Sekupang": anomalies},
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	rules := &RuleSet{
+		Version: "test",
+		Rules: []Rule{
+			{Name: "rain-during", Level: LevelHigh, Message: "High tide during rain", TideHeightAbove: 2.6, Overlap: OverlapDuring},
+			{Name: "tide-only", Level: LevelLow, Message: "Very high tide", TideHeightAbove: 2.8, Overlap: OverlapNone},
+		},
+	}
+
+	tests := []struct {
+		name string
+		from time.Time
+		to   time.Time
+		want []string // tide ID and rule of every window
+	}{
+		{"peak during alert", at(4), at(8), []string{"h1 rain-during"}},
+		// The water is above 2.6m from about 04:50, before the peak at 06:00
+		{"alert ending before peak", at(0), at(5.5), []string{"h1 rain-during"}},
+		{"alert starting after peak", at(7), at(11), []string{"h1 rain-during"}},
+		{"alert too early in rising water", at(0), at(4), []string{"h1 tide-only"}},
+		{"alert across both cycles", at(5), at(19), []string{"h1 rain-during", "h2 rain-during"}},
+		{"alert around low tide", at(10), at(14), []string{"h1 tide-only"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := []weathermodels.AlertDetail{{ID: "alert", Event: "Hujan Lebat", Effective: tt.from, Expires: tt.to}}
+
+			windows, err := rules.Timeline(testTideCache(), "Sekupang", alerts, at(0), at(24))
+			if err != nil {
+				t.Fatalf("Timeline() error = %v", err)
+			}
+
+			got := make([]string, len(windows))
+			for i, window := range windows {
+				got[i] = window.Tide.ID + " " + window.Rule
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Timeline() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Timeline() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
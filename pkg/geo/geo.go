@@ -0,0 +1,175 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// boundaryEpsilon absorbs the rounding of the cross product when checking points on an edge
+const boundaryEpsilon = 1e-12
+
+// Point is a WGS84 coordinate in decimal degrees
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Polygon is a ring of points, the closing point may be omitted
+type Polygon []Point
+
+// ParsePoint parses a "lat,lon" coordinate
+func ParsePoint(text string) (Point, error) {
+	parts := strings.Split(strings.TrimSpace(text), ",")
+	if len(parts) != 2 {
+		return Point{}, fmt.Errorf("invalid coordinate '%s', expected lat,lon", text)
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid latitude '%s'", parts[0])
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid longitude '%s'", parts[1])
+	}
+
+	point := Point{Lat: lat, Lon: lon}
+	if !point.Valid() {
+		return Point{}, fmt.Errorf("coordinate '%s' out of range", text)
+	}
+
+	return point, nil
+}
+
+// Valid reports whether the latitude and longitude are within range
+func (p Point) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// ParsePolygons parses the CAP polygon format used by BMKG: space separated "lat,lon" pairs.
+// Polygons are separated by semicolons (the alert polygons are joined with "; ") or newlines,
+// and may also be concatenated, a ring ends when its first point is repeated.
+func ParsePolygons(text string) ([]Polygon, error) {
+	groups := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ';' || r == '\n' || r == '\r'
+	})
+
+	polygons := make([]Polygon, 0)
+	for _, group := range groups {
+		ring := make(Polygon, 0)
+		for _, field := range strings.Fields(group) {
+			point, err := ParsePoint(field)
+			if err != nil {
+				return nil, err
+			}
+
+			ring = append(ring, point)
+			if len(ring) > 3 && point == ring[0] {
+				polygons = append(polygons, ring)
+				ring = make(Polygon, 0)
+			}
+		}
+
+		// Unclosed trailing ring
+		if len(ring) >= 3 {
+			polygons = append(polygons, ring)
+		} else if len(ring) > 0 {
+			return nil, fmt.Errorf("polygon needs at least 3 points, got %d", len(ring))
+		}
+	}
+
+	return polygons, nil
+}
+
+// String formats the polygon in the CAP "lat,lon lat,lon ..." format
+func (p Polygon) String() string {
+	points := make([]string, len(p))
+	for i, point := range p {
+		points[i] = strconv.FormatFloat(point.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(point.Lon, 'f', -1, 64)
+	}
+	return strings.Join(points, " ")
+}
+
+// Center returns the average of the polygon vertices, ignoring the closing point
+func (p Polygon) Center() Point {
+	points := p
+	if len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+	if len(points) == 0 {
+		return Point{}
+	}
+
+	var center Point
+	for _, point := range points {
+		center.Lat += point.Lat
+		center.Lon += point.Lon
+	}
+	center.Lat /= float64(len(points))
+	center.Lon /= float64(len(points))
+
+	return center
+}
+
+// Contains reports whether the point is inside the polygon or on its boundary (ray casting)
+func (p Polygon) Contains(point Point) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if onSegment(a, b, point) {
+			return true
+		}
+		if (a.Lat > point.Lat) != (b.Lat > point.Lat) &&
+			point.Lon < (b.Lon-a.Lon)*(point.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Intersects reports whether the polygons overlap or touch: a vertex of one lies inside or on the other, or two edges cross
+func (p Polygon) Intersects(other Polygon) bool {
+	for _, point := range p {
+		if other.Contains(point) {
+			return true
+		}
+	}
+	for _, point := range other {
+		if p.Contains(point) {
+			return true
+		}
+	}
+
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		for k, l := 0, len(other)-1; k < len(other); l, k = k, k+1 {
+			if segmentsCross(p[j], p[i], other[l], other[k]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// segmentsCross reports whether the segments ab and cd properly cross each other
+func segmentsCross(a, b, c, d Point) bool {
+	d1 := orientation(c, d, a)
+	d2 := orientation(c, d, b)
+	d3 := orientation(a, b, c)
+	d4 := orientation(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// onSegment reports whether the point lies on the segment ab, its ends included
+func onSegment(a, b, point Point) bool {
+	return math.Abs(orientation(a, b, point)) <= boundaryEpsilon &&
+		point.Lat >= math.Min(a.Lat, b.Lat) && point.Lat <= math.Max(a.Lat, b.Lat) &&
+		point.Lon >= math.Min(a.Lon, b.Lon) && point.Lon <= math.Max(a.Lon, b.Lon)
+}
+
+// orientation is the sign of the cross product (b - a) x (c - a)
+func orientation(a, b, c Point) float64 {
+	return (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+}
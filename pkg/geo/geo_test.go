@@ -0,0 +1,122 @@
+package geo
+
+import "testing"
+
+// square is the Sekupang area used by the tests, closed
+var square = Polygon{
+	{Lat: 1.10, Lon: 104.00},
+	{Lat: 1.10, Lon: 104.10},
+	{Lat: 1.20, Lon: 104.10},
+	{Lat: 1.20, Lon: 104.00},
+	{Lat: 1.10, Lon: 104.00},
+}
+
+func TestPolygonContains(t *testing.T) {
+	// Concave "L" shape, the notch at the top right is outside
+	concave := Polygon{
+		{Lat: 0, Lon: 0},
+		{Lat: 0, Lon: 2},
+		{Lat: 1, Lon: 2},
+		{Lat: 1, Lon: 1},
+		{Lat: 2, Lon: 1},
+		{Lat: 2, Lon: 0},
+	}
+
+	tests := []struct {
+		name    string
+		polygon Polygon
+		point   Point
+		want    bool
+	}{
+		{"inside", square, Point{Lat: 1.15, Lon: 104.05}, true},
+		{"outside", square, Point{Lat: 1.25, Lon: 104.05}, false},
+		{"vertex", square, Point{Lat: 1.10, Lon: 104.00}, true},
+		{"opposite vertex", square, Point{Lat: 1.20, Lon: 104.10}, true},
+		{"horizontal edge", square, Point{Lat: 1.10, Lon: 104.05}, true},
+		{"vertical edge", square, Point{Lat: 1.15, Lon: 104.10}, true},
+		{"edge extended", square, Point{Lat: 1.10, Lon: 104.20}, false},
+		{"ray through vertex", square, Point{Lat: 1.20, Lon: 103.90}, false},
+		{"concave inside", concave, Point{Lat: 0.5, Lon: 1.5}, true},
+		{"concave notch", concave, Point{Lat: 1.5, Lon: 1.5}, false},
+		{"concave inner vertex", concave, Point{Lat: 1, Lon: 1}, true},
+		{"diagonal edge", Polygon{{Lat: 0, Lon: 0}, {Lat: 0.3, Lon: 0.3}, {Lat: 0, Lon: 0.3}}, Point{Lat: 0.1, Lon: 0.1}, true},
+		{"empty", Polygon{}, Point{Lat: 1.15, Lon: 104.05}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.polygon.Contains(tt.point); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.point, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolygonIntersects(t *testing.T) {
+	tests := []struct {
+		name  string
+		other Polygon
+		want  bool
+	}{
+		{"overlapping", Polygon{{Lat: 1.15, Lon: 104.05}, {Lat: 1.15, Lon: 104.15}, {Lat: 1.25, Lon: 104.15}, {Lat: 1.25, Lon: 104.05}}, true},
+		{"inside", Polygon{{Lat: 1.12, Lon: 104.02}, {Lat: 1.12, Lon: 104.04}, {Lat: 1.14, Lon: 104.04}}, true},
+		{"containing", Polygon{{Lat: 1.0, Lon: 103.9}, {Lat: 1.0, Lon: 104.2}, {Lat: 1.3, Lon: 104.2}, {Lat: 1.3, Lon: 103.9}}, true},
+		{"crossing without vertex inside", Polygon{{Lat: 1.05, Lon: 104.04}, {Lat: 1.05, Lon: 104.06}, {Lat: 1.25, Lon: 104.06}, {Lat: 1.25, Lon: 104.04}}, true},
+		{"shared edge", Polygon{{Lat: 1.10, Lon: 104.10}, {Lat: 1.10, Lon: 104.20}, {Lat: 1.20, Lon: 104.20}, {Lat: 1.20, Lon: 104.10}}, true},
+		{"shared vertex", Polygon{{Lat: 1.20, Lon: 104.10}, {Lat: 1.20, Lon: 104.20}, {Lat: 1.30, Lon: 104.20}}, true},
+		{"disjoint", Polygon{{Lat: 1.30, Lon: 104.30}, {Lat: 1.30, Lon: 104.40}, {Lat: 1.40, Lon: 104.40}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := square.Intersects(tt.other); got != tt.want {
+				t.Errorf("Intersects() = %v, want %v", got, tt.want)
+			}
+			if got := tt.other.Intersects(square); got != tt.want {
+				t.Errorf("reversed Intersects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePolygons(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []int // number of points of every polygon
+		wantErr bool
+	}{
+		{"single closed", "1.1,104.0 1.1,104.1 1.2,104.1 1.1,104.0", []int{4}, false},
+		{"single unclosed", "1.1,104.0 1.1,104.1 1.2,104.1", []int{3}, false},
+		{"semicolon separated closed", "1.1,104.0 1.1,104.1 1.2,104.1 1.1,104.0; 0.9,104.4 0.9,104.5 1.0,104.5 0.9,104.4", []int{4, 4}, false},
+		{"semicolon separated unclosed", "1.1,104.0 1.1,104.1 1.2,104.1; 0.9,104.4 0.9,104.5 1.0,104.5 1.0,104.4", []int{3, 4}, false},
+		{"newline separated", "1.1,104.0 1.1,104.1 1.2,104.1\n0.9,104.4 0.9,104.5 1.0,104.5", []int{3, 3}, false},
+		{"concatenated closed", "1.1,104.0 1.1,104.1 1.2,104.1 1.1,104.0 0.9,104.4 0.9,104.5 1.0,104.5 0.9,104.4", []int{4, 4}, false},
+		{"trailing separator", "1.1,104.0 1.1,104.1 1.2,104.1; ", []int{3}, false},
+		{"empty", "", []int{}, false},
+		{"too few points", "1.1,104.0 1.1,104.1 1.2,104.1; 0.9,104.4 0.9,104.5", nil, true},
+		{"invalid coordinate", "1.1,104.0 1.1 1.2,104.1", nil, true},
+		{"out of range", "91,104.0 1.1,104.1 1.2,104.1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polygons, err := ParsePolygons(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolygons() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(polygons) != len(tt.want) {
+				t.Fatalf("ParsePolygons() returned %d polygons, want %d", len(polygons), len(tt.want))
+			}
+			for i, polygon := range polygons {
+				if len(polygon) != tt.want[i] {
+					t.Errorf("polygon %d has %d points, want %d", i, len(polygon), tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,542 @@
+package homeassistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/mqttclient"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// tideHorizon is how far ahead the next high tide is looked up
+const tideHorizon = 48 * time.Hour
+
+// birthPayload is published by Home Assistant on <prefix>/status when it (re)starts
+const birthPayload = "online"
+
+// nonSlugRegex matches the characters replaced in topic and entity identifiers
+var nonSlugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// State is the JSON state of a home or a tide station, every entity of the device reads its value from it
+type State struct {
+	Station             string     `json:"station"`
+	RiskLevel           string     `json:"risk_level"`
+	RiskScore           int        `json:"risk_score"`
+	RiskMessage         string     `json:"risk_message"`
+	NextHighTide        *time.Time `json:"next_high_tide"`
+	NextHighTideHeightM *float64   `json:"next_high_tide_height_m"`
+	AlertEvent          string     `json:"alert_event"`
+	AlertHeadline       string     `json:"alert_headline"`
+	HeavyRain           bool       `json:"heavy_rain"`
+	RainIntensity       string     `json:"rain_intensity"`
+	// ObservedFlooding is set while the sensor of the home reads above its flood threshold, overriding the risk
+	ObservedFlooding bool `json:"observed_flooding"`
+	// ObservedLevelM is the latest level read by the sensor of the home, nil once the sensor is no longer live
+	ObservedLevelM *float64  `json:"observed_level_m"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// entity describes a Home Assistant entity of a device
+type entity struct {
+	component     string
+	key           string
+	name          string
+	icon          string
+	deviceClass   string
+	unit          string
+	valueTemplate string
+	attributes    bool
+	// sensor entities are only published for the homes with a water level sensor
+	sensor bool
+}
+
+// entities are published for every home, and for every tide station without homes
+var entities = []entity{
+	{component: "sensor", key: "risk_level", name: "Flood risk", icon: "mdi:home-flood", valueTemplate: "{{ value_json.risk_level }}", attributes: true},
+	{component: "sensor", key: "next_high_tide", name: "Next high tide", deviceClass: "timestamp", valueTemplate: "{{ value_json.next_high_tide }}"},
+	{component: "sensor", key: "next_high_tide_height", name: "Next high tide height", icon: "mdi:waves-arrow-up", deviceClass: "distance", unit: "m", valueTemplate: "{{ value_json.next_high_tide_height_m }}"},
+	{component: "sensor", key: "alert_event", name: "Active alert", icon: "mdi:alert", valueTemplate: "{{ value_json.alert_event if value_json.alert_event else 'none' }}", attributes: true},
+	{component: "binary_sensor", key: "heavy_rain", name: "Heavy rain", icon: "mdi:weather-pouring", valueTemplate: "{{ 'ON' if value_json.heavy_rain else 'OFF' }}"},
+	{component: "binary_sensor", key: "observed_flooding", name: "Flooding observed", deviceClass: "moisture", valueTemplate: "{{ 'ON' if value_json.observed_flooding else 'OFF' }}", sensor: true},
+	{component: "sensor", key: "water_level", name: "Water level", icon: "mdi:waves", deviceClass: "distance", unit: "m", valueTemplate: "{{ value_json.observed_level_m }}", sensor: true},
+}
+
+// Publisher publishes the flood risk of every home to Home Assistant over MQTT:
+// the discovery configs on every connection and the retained states after every fetch and sensor reading.
+// Without homes, every tide station appears as a device instead.
+type Publisher struct {
+	db       *gorm.DB
+	cfg      *config.Config
+	rules    *floodrisk.RuleSet
+	tides    *floodrisk.TideCache
+	assessor *floodrisk.Assessor
+	client   *mqttclient.Client
+	mu       sync.Mutex
+	// discovered holds the discovery configs published since the last connection, by topic
+	discovered map[string]string
+	// retained holds the discovery config topics of the service retained by the broker,
+	// it has its own lock as the subscription updates it while Publish waits for the broker
+	retained   map[string]bool
+	retainedMu sync.Mutex
+}
+
+// device is a Home Assistant device, a home or a tide station
+type device struct {
+	slug     string
+	name     string
+	model    string
+	entities []entity
+}
+
+// New creates a new Publisher, nil when no broker or no discovery prefix is configured.
+// The risk of every home is evaluated by the assessor, at the home station and threshold.
+func New(db *gorm.DB, cfg *config.Config, rules *floodrisk.RuleSet, tides *floodrisk.TideCache, assessor *floodrisk.Assessor, client *mqttclient.Client) *Publisher {
+	if client == nil || cfg.GetMQTTConfig().DiscoveryPrefix == "" {
+		return nil
+	}
+
+	p := &Publisher{
+		db:         db,
+		cfg:        cfg,
+		rules:      rules,
+		tides:      tides,
+		assessor:   assessor,
+		client:     client,
+		discovered: make(map[string]string),
+		retained:   make(map[string]bool),
+	}
+	client.OnConnect(p.connected)
+
+	return p
+}
+
+// Publish computes the state of every home and publishes it, retained so Home Assistant reads it on restart.
+// Without homes the tide stations are published instead. Devices added or changed since the last connection
+// get their discovery configs first, and the configs of removed devices are cleared.
+// Runs are serialized, as both fetchers and the water level sensors trigger it.
+func (p *Publisher) Publish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	var homes []models.Home
+	if err := p.db.Find(&homes).Error; err != nil {
+		zap.S().Errorf("Failed to query homes for Home Assistant: %v", err)
+		return
+	}
+
+	alerts, err := p.alerts(now)
+	if err != nil {
+		zap.S().Errorf("Failed to query alerts for Home Assistant: %v", err)
+		return
+	}
+
+	expected := make(map[string]bool)
+	if len(homes) == 0 {
+		p.publishStations(alerts, now, expected)
+	}
+	for _, home := range homes {
+		p.publishHome(home, alerts, now, expected)
+	}
+
+	p.clearStale(expected)
+}
+
+// PublishHome publishes the state of a single home, after a reading of its water level sensor
+func (p *Publisher) PublishHome(homeID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now().UTC()
+
+	var home models.Home
+	if err := p.db.First(&home, "id = ?", homeID).Error; err != nil {
+		zap.S().Errorf("Failed to query home %s for Home Assistant: %v", homeID, err)
+		return
+	}
+
+	alerts, err := p.alerts(now)
+	if err != nil {
+		zap.S().Errorf("Failed to query alerts for Home Assistant: %v", err)
+		return
+	}
+
+	p.publishHome(home, alerts, now, make(map[string]bool))
+}
+
+// alerts returns the alerts the timelines consider now, the latest sent first
+func (p *Publisher) alerts(now time.Time) ([]weathermodels.AlertDetail, error) {
+	var alerts []weathermodels.AlertDetail
+	err := p.rules.TimelineAlertsQuery(p.db, p.cfg.GetProvinces(), now, now).Order("sent DESC").Find(&alerts).Error
+	return alerts, err
+}
+
+// publishHome publishes the discovery configs and the state of a home, adding its config topics to the expected ones
+func (p *Publisher) publishHome(home models.Home, alerts []weathermodels.AlertDetail, now time.Time, expected map[string]bool) {
+	d := device{
+		slug:     slug(home.Name),
+		name:     home.Name,
+		model:    "Home",
+		entities: homeEntities(p.hasSensor(home)),
+	}
+	p.publishDiscovery(d, expected)
+
+	homeAlerts := make([]weathermodels.AlertDetail, 0)
+	for _, alert := range alerts {
+		if home.MatchesAlert(alert) {
+			homeAlerts = append(homeAlerts, alert)
+		}
+	}
+
+	state, err := p.homeState(home, homeAlerts, now)
+	if err != nil {
+		zap.S().Errorf("Failed to compute the Home Assistant state of %s: %v", home.Name, err)
+		return
+	}
+	p.publishState(d, state)
+}
+
+// publishStations publishes the discovery configs and the state of every tide station,
+// adding their config topics to the expected ones
+func (p *Publisher) publishStations(alerts []weathermodels.AlertDetail, now time.Time, expected map[string]bool) {
+	// Every alert belongs to the station configured for its area
+	stationAlerts := make(map[string][]weathermodels.AlertDetail)
+	for _, alert := range alerts {
+		station := p.cfg.GetTideStationFor(alert.Description)
+		stationAlerts[station.Name] = append(stationAlerts[station.Name], alert)
+	}
+
+	for _, station := range p.cfg.GetTideStations() {
+		d := device{
+			slug:     slug(station.Name),
+			name:     station.Name,
+			model:    "Tide station",
+			entities: homeEntities(false),
+		}
+		p.publishDiscovery(d, expected)
+
+		state, err := p.stationState(station, stationAlerts[station.Name], now)
+		if err != nil {
+			zap.S().Errorf("Failed to compute the Home Assistant state of %s: %v", station.Name, err)
+			continue
+		}
+		p.publishState(d, state)
+	}
+}
+
+// publishState publishes the retained state of a device
+func (p *Publisher) publishState(d device, state State) {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		zap.S().Errorf("Failed to encode the Home Assistant state of %s: %v", d.name, err)
+		return
+	}
+	if err := p.client.Publish(p.stateTopic(d), true, payload); err != nil {
+		zap.S().Warnf("Failed to publish the Home Assistant state of %s: %v", d.name, err)
+	}
+}
+
+// homeState computes the state of a home: its risk at its station and threshold, and the level of its sensor
+func (p *Publisher) homeState(home models.Home, alerts []weathermodels.AlertDetail, now time.Time) (State, error) {
+	station := floodrisk.HomeStation(p.cfg, home)
+
+	// Flooding observed by the sensor of the home comes as an extreme window ahead of the predicted ones
+	windows, err := p.assessor.HomeTimeline(home, alerts, now, now)
+	if err != nil {
+		return State{}, err
+	}
+
+	state, err := p.state(station, windows, alerts, now)
+	if err != nil {
+		return state, err
+	}
+
+	// The water level is reported while the sensor is live, flooded or not
+	if p.hasSensor(home) {
+		reading, err := floodrisk.LiveReading(p.db, p.cfg, home.ID, now)
+		if err != nil {
+			return state, err
+		}
+		if reading != nil {
+			level := reading.LevelM
+			state.ObservedLevelM = &level
+		}
+	}
+
+	return state, nil
+}
+
+// stationState computes the state of a tide station from the rules, without any home threshold or sensor
+func (p *Publisher) stationState(station config.TideStation, alerts []weathermodels.AlertDetail, now time.Time) (State, error) {
+	windows, err := p.rules.Timeline(p.tides, station.Name, alerts, now, now)
+	if err != nil {
+		return State{}, err
+	}
+
+	return p.state(station, windows, alerts, now)
+}
+
+// state computes the current flood risk, the next high tide and the active alert at a station
+func (p *Publisher) state(station config.TideStation, windows []floodrisk.Window, alerts []weathermodels.AlertDetail, now time.Time) (State, error) {
+	state := State{
+		Station:       station.Name,
+		RiskLevel:     floodrisk.LevelNone,
+		RainIntensity: string(floodrisk.RainNone),
+		UpdatedAt:     p.localTime(station, now),
+	}
+	applyRisk(&state, windows, now)
+
+	tides, err := p.tides.HighTides(station.Name, now, now.Add(tideHorizon), math.Inf(-1))
+	if err != nil {
+		return state, err
+	}
+	if len(tides) > 0 {
+		tideTime := p.localTime(station, tides[0].TideTime)
+		height := tides[0].HeightM
+		state.NextHighTide = &tideTime
+		state.NextHighTideHeightM = &height
+	}
+
+	// Alerts are sorted by sent time, the latest active one is reported
+	for _, alert := range alerts {
+		if now.Before(alert.Effective) || now.After(alert.Expires) {
+			continue
+		}
+		if state.AlertEvent == "" {
+			state.AlertEvent = alert.Event
+			state.AlertHeadline = alert.Headline
+		}
+		if p.rules.HasHeavyRain(alert) {
+			state.HeavyRain = true
+		}
+		if intensity := floodrisk.ClassifyRain(alert); intensity.Rank() > floodrisk.RainIntensity(state.RainIntensity).Rank() {
+			state.RainIntensity = string(intensity)
+		}
+	}
+
+	return state, nil
+}
+
+// applyRisk sets the current risk of the state: the highest level of the windows spanning now,
+// flagging the flooding observed by a sensor
+func applyRisk(state *State, windows []floodrisk.Window, now time.Time) {
+	for _, window := range windows {
+		if now.Before(window.Start) || now.After(window.End) {
+			continue
+		}
+		if window.Observed != nil {
+			state.ObservedFlooding = true
+		}
+		if floodrisk.LevelRank(window.Level) <= floodrisk.LevelRank(state.RiskLevel) {
+			continue
+		}
+		state.RiskLevel = window.Level
+		state.RiskScore = window.Score
+		state.RiskMessage = window.Message
+	}
+}
+
+// connected publishes the discovery configs and the states, and republishes them whenever Home Assistant restarts.
+// The retained discovery configs of the service are watched to clear those of removed devices.
+func (p *Publisher) connected() {
+	mqttCfg := p.cfg.GetMQTTConfig()
+
+	p.retainedMu.Lock()
+	p.retained = make(map[string]bool)
+	p.retainedMu.Unlock()
+
+	topic := fmt.Sprintf("%s/+/%s/+/config", mqttCfg.DiscoveryPrefix, slug(mqttCfg.BaseTopic))
+	err := p.client.Subscribe(topic, func(topic string, payload []byte) {
+		p.retainedMu.Lock()
+		defer p.retainedMu.Unlock()
+
+		// An empty payload clears the retained config
+		if len(payload) == 0 {
+			delete(p.retained, topic)
+			return
+		}
+		p.retained[topic] = true
+	})
+	if err != nil {
+		zap.S().Warnf("Failed to watch the Home Assistant discovery configs: %v", err)
+	}
+
+	p.rediscover()
+
+	err = p.client.Subscribe(mqttCfg.DiscoveryPrefix+"/status", func(_ string, payload []byte) {
+		if strings.TrimSpace(string(payload)) != birthPayload {
+			return
+		}
+		// Publishing from the message handler would block the client
+		go p.rediscover()
+	})
+	if err != nil {
+		zap.S().Warnf("Failed to watch Home Assistant restarts: %v", err)
+	}
+}
+
+// rediscover publishes the discovery configs of every device again, with the states
+func (p *Publisher) rediscover() {
+	p.mu.Lock()
+	p.discovered = make(map[string]string)
+	p.mu.Unlock()
+
+	p.Publish()
+}
+
+// publishDiscovery publishes the retained discovery config of every entity of a device not published yet
+// since the last connection, or changed since, and adds the config topics to the expected ones
+func (p *Publisher) publishDiscovery(d device, expected map[string]bool) {
+	mqttCfg := p.cfg.GetMQTTConfig()
+	node := slug(mqttCfg.BaseTopic)
+
+	info := map[string]interface{}{
+		"identifiers":  []string{node + "_" + d.slug},
+		"name":         "Tidal flood " + d.name,
+		"manufacturer": "Home Tidal Flood Warning",
+		"model":        d.model,
+	}
+
+	published := false
+	for _, e := range d.entities {
+		objectID := d.slug + "_" + e.key
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", mqttCfg.DiscoveryPrefix, e.component, node, objectID)
+		expected[topic] = true
+
+		payload, err := json.Marshal(discoveryConfig(e, node, objectID, p.stateTopic(d), p.client.AvailabilityTopic(), info))
+		if err != nil {
+			zap.S().Errorf("Failed to encode Home Assistant discovery of %s: %v", objectID, err)
+			continue
+		}
+		if p.discovered[topic] == string(payload) {
+			continue
+		}
+
+		if err := p.client.Publish(topic, true, payload); err != nil {
+			zap.S().Warnf("Failed to publish Home Assistant discovery: %v", err)
+			continue
+		}
+		p.discovered[topic] = string(payload)
+		published = true
+	}
+
+	if published {
+		zap.S().Infof("Published Home Assistant discovery for %s %s", strings.ToLower(d.model), d.name)
+	}
+}
+
+// clearStale clears the retained discovery configs of the devices and entities no longer published,
+// such as those of removed or renamed homes
+func (p *Publisher) clearStale(expected map[string]bool) {
+	p.retainedMu.Lock()
+	for topic := range p.discovered {
+		p.retained[topic] = true
+	}
+	stale := staleTopics(p.retained, expected)
+	p.retainedMu.Unlock()
+
+	for _, topic := range stale {
+		if err := p.client.Publish(topic, true, []byte{}); err != nil {
+			zap.S().Warnf("Failed to clear Home Assistant discovery: %v", err)
+			continue
+		}
+		delete(p.discovered, topic)
+
+		p.retainedMu.Lock()
+		delete(p.retained, topic)
+		p.retainedMu.Unlock()
+
+		zap.S().Infof("Cleared Home Assistant discovery %s", topic)
+	}
+}
+
+// staleTopics returns the retained topics that are not expected, sorted
+func staleTopics(retained, expected map[string]bool) []string {
+	stale := make([]string, 0)
+	for topic := range retained {
+		if !expected[topic] {
+			stale = append(stale, topic)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// homeEntities returns the entities of a home, the sensor ones only when it has a water level sensor
+func homeEntities(hasSensor bool) []entity {
+	result := make([]entity, 0, len(entities))
+	for _, e := range entities {
+		if !e.sensor || hasSensor {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// discoveryConfig builds the discovery config of an entity reading its value from the state topic
+func discoveryConfig(e entity, node, objectID, stateTopic, availabilityTopic string, device map[string]interface{}) map[string]interface{} {
+	discovery := map[string]interface{}{
+		"name":               e.name,
+		"unique_id":          node + "_" + objectID,
+		"object_id":          node + "_" + objectID,
+		"state_topic":        stateTopic,
+		"value_template":     e.valueTemplate,
+		"availability_topic": availabilityTopic,
+		"device":             device,
+	}
+	if e.icon != "" {
+		discovery["icon"] = e.icon
+	}
+	if e.deviceClass != "" {
+		discovery["device_class"] = e.deviceClass
+	}
+	if e.unit != "" {
+		discovery["unit_of_measurement"] = e.unit
+		discovery["state_class"] = "measurement"
+	}
+	if e.attributes {
+		discovery["json_attributes_topic"] = stateTopic
+	}
+	return discovery
+}
+
+// hasSensor reports whether a water level sensor is installed at the home
+func (p *Publisher) hasSensor(home models.Home) bool {
+	for _, sensor := range p.cfg.GetMQTTConfig().Sensors {
+		if strings.EqualFold(sensor.Home, home.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// stateTopic returns the topic of the JSON state of a device
+func (p *Publisher) stateTopic(d device) string {
+	return p.cfg.GetMQTTConfig().BaseTopic + "/" + d.slug + "/state"
+}
+
+// localTime converts a time to the timezone of the station, Home Assistant shows the offset as is
+func (p *Publisher) localTime(station config.TideStation, t time.Time) time.Time {
+	if station.Timezone == nil {
+		return t
+	}
+	return t.In(station.Timezone)
+}
+
+// slug lowercases a name and replaces the characters not allowed in MQTT discovery identifiers
+func slug(name string) string {
+	return strings.Trim(nonSlugRegex.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
@@ -0,0 +1,157 @@
+package homeassistant
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+func TestApplyRisk(t *testing.T) {
+	now := time.Date(2025, 12, 4, 6, 0, 0, 0, time.UTC)
+	predicted := floodrisk.Window{Start: now.Add(-time.Hour), End: now.Add(time.Hour), Level: floodrisk.LevelModerate, Score: 45, Message: "predicted"}
+	later := floodrisk.Window{Start: now.Add(5 * time.Hour), End: now.Add(7 * time.Hour), Level: floodrisk.LevelHigh, Score: 70, Message: "later"}
+	observed := floodrisk.Window{
+		Start:    now.Add(-30 * time.Minute),
+		End:      now.Add(15 * time.Minute),
+		Level:    floodrisk.LevelExtreme,
+		Score:    100,
+		Message:  "observed",
+		Rule:     floodrisk.RuleObservedFlooding,
+		Observed: &models.SensorReading{LevelM: 3.1, Flooded: true},
+	}
+
+	tests := []struct {
+		name         string
+		windows      []floodrisk.Window
+		wantLevel    string
+		wantMessage  string
+		wantObserved bool
+	}{
+		{"no window", nil, floodrisk.LevelNone, "", false},
+		{"window spanning now", []floodrisk.Window{predicted, later}, floodrisk.LevelModerate, "predicted", false},
+		{"upcoming window only", []floodrisk.Window{later}, floodrisk.LevelNone, "", false},
+		{"observed overrides predicted", []floodrisk.Window{observed, predicted}, floodrisk.LevelExtreme, "observed", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := State{RiskLevel: floodrisk.LevelNone}
+			applyRisk(&state, tt.windows, now)
+
+			if state.RiskLevel != tt.wantLevel || state.RiskMessage != tt.wantMessage {
+				t.Errorf("applyRisk() level = %s, message = %q, want %s, %q", state.RiskLevel, state.RiskMessage, tt.wantLevel, tt.wantMessage)
+			}
+			if state.ObservedFlooding != tt.wantObserved {
+				t.Errorf("applyRisk() observed flooding = %v, want %v", state.ObservedFlooding, tt.wantObserved)
+			}
+		})
+	}
+}
+
+func TestHomeEntities(t *testing.T) {
+	keys := func(entities []entity) map[string]entity {
+		result := make(map[string]entity)
+		for _, e := range entities {
+			result[e.key] = e
+		}
+		return result
+	}
+
+	without := keys(homeEntities(false))
+	if _, ok := without["observed_flooding"]; ok {
+		t.Error("homeEntities(false) includes observed_flooding, want it only for homes with a sensor")
+	}
+	if _, ok := without["risk_level"]; !ok {
+		t.Error("homeEntities(false) misses risk_level")
+	}
+
+	with := keys(homeEntities(true))
+	if _, ok := with["observed_flooding"]; !ok {
+		t.Error("homeEntities(true) misses observed_flooding")
+	}
+	if len(with) != len(entities) {
+		t.Errorf("homeEntities(true) returned %d entities, want %d", len(with), len(entities))
+	}
+}
+
+func TestDiscoveryConfig(t *testing.T) {
+	device := map[string]interface{}{"name": "Tidal flood My Home"}
+	stateTopic := "home-tidal-flood-warning/my_home/state"
+
+	tests := []struct {
+		key             string
+		wantDeviceClass string // empty for none
+	}{
+		{"risk_level", ""},
+		{"heavy_rain", ""},
+		{"observed_flooding", "moisture"},
+		{"next_high_tide", "timestamp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			var e entity
+			for _, candidate := range entities {
+				if candidate.key == tt.key {
+					e = candidate
+				}
+			}
+
+			discovery := discoveryConfig(e, "home_tidal_flood_warning", "my_home_"+tt.key, stateTopic, "home-tidal-flood-warning/status", device)
+
+			deviceClass, ok := discovery["device_class"]
+			if tt.wantDeviceClass == "" && ok {
+				t.Errorf("device_class = %v, want none", deviceClass)
+			}
+			if tt.wantDeviceClass != "" && deviceClass != tt.wantDeviceClass {
+				t.Errorf("device_class = %v, want %s", deviceClass, tt.wantDeviceClass)
+			}
+			if discovery["state_topic"] != stateTopic || discovery["unique_id"] != "home_tidal_flood_warning_my_home_"+tt.key {
+				t.Errorf("state_topic = %v, unique_id = %v", discovery["state_topic"], discovery["unique_id"])
+			}
+			if _, ok := discovery["json_attributes_topic"]; ok != e.attributes {
+				t.Errorf("json_attributes_topic set = %v, want %v", ok, e.attributes)
+			}
+		})
+	}
+}
+
+func TestStaleTopics(t *testing.T) {
+	const (
+		sekupang = "homeassistant/sensor/home_tidal_flood_warning/sekupang_risk_level/config"
+		nongsa   = "homeassistant/sensor/home_tidal_flood_warning/nongsa_risk_level/config"
+		level    = "homeassistant/sensor/home_tidal_flood_warning/nongsa_water_level/config"
+		batam    = "homeassistant/sensor/home_tidal_flood_warning/batam_risk_level/config"
+	)
+
+	tests := []struct {
+		name     string
+		retained []string
+		expected []string
+		want     []string
+	}{
+		{"nothing retained", nil, []string{sekupang}, []string{}},
+		{"all expected", []string{sekupang, nongsa}, []string{sekupang, nongsa}, []string{}},
+		{"removed home", []string{sekupang, nongsa, level}, []string{sekupang}, []string{nongsa, level}},
+		{"station replaced by homes", []string{batam}, []string{sekupang, nongsa}, []string{batam}},
+	}
+
+	set := func(topics []string) map[string]bool {
+		result := make(map[string]bool)
+		for _, topic := range topics {
+			result[topic] = true
+		}
+		return result
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staleTopics(set(tt.retained), set(tt.expected)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("staleTopics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
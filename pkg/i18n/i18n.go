@@ -0,0 +1,158 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Supported languages
+const (
+	EN = "en"
+	ID = "id"
+	// Default is used when no supported language is requested
+	Default = EN
+)
+
+// catalogs hold the messages of every language by key, the format verbs follow fmt
+var catalogs = map[string]map[string]string{
+	EN: {
+		"risk.no_tide":  "No tidal flood risk: No high tide matching the risk rules during or near alert period",
+		"risk.no_rain":  "No heavy rain expected",
+		"risk.unknown":  "Unable to determine tidal flood risk",
+		"risk.observed": "FLOODING OBSERVED: The water level sensor reads %.2fm, above the %.2fm flood threshold",
+
+		"level.none":     "None",
+		"level.low":      "Low",
+		"level.moderate": "Moderate",
+		"level.high":     "High",
+		"level.extreme":  "Extreme",
+		"level.unknown":  "Unknown",
+
+		"card.all_clear":      "All Clear",
+		"card.no_alerts":      "No active weather alerts",
+		"card.no_alerts_for":  "No active weather alerts for %s",
+		"card.all_clear_text": "There are currently no weather warnings or alerts in effect. Stay safe and enjoy your day!",
+		"card.effective":      "Effective",
+		"card.expires":        "Expires",
+		"card.risk_title":     "%s Risk - Tidal Flood",
+		"card.high_tide":      "High Tide",
+		"card.height":         "Height",
+		"card.score":          "Score",
+		"card.tide_summary":   "High tide %s  Height %.1f m  Score %d/100",
+		"card.observed_event": "Flooding Observed",
+
+		"list.active_alert":  "1 active alert",
+		"list.active_alerts": "%d active alerts",
+		"list.title_for":     "%s for %s",
+		"list.highest_risk":  "Highest flood risk: %s",
+		"list.no_risk":       "No tidal flood risk",
+		"list.showing":       "%s - showing %d of %d",
+
+		"chart.title":   "Tide %s",
+		"chart.period":  "%s to %s",
+		"chart.flood":   "Flood %.2f m",
+		"chart.now":     "now",
+		"chart.no_data": "No tide data is available for this period yet.",
+	},
+	ID: {
+		"risk.no_tide":  "Tidak ada risiko banjir rob: Tidak ada air pasang yang memenuhi aturan risiko selama atau sekitar periode peringatan",
+		"risk.no_rain":  "Tidak ada prakiraan hujan lebat",
+		"risk.unknown":  "Risiko banjir rob tidak dapat ditentukan",
+		"risk.observed": "BANJIR TERPANTAU: Sensor ketinggian air membaca %.2fm, di atas ambang banjir %.2fm",
+
+		"level.none":     "Tidak Ada",
+		"level.low":      "Rendah",
+		"level.moderate": "Sedang",
+		"level.high":     "Tinggi",
+		"level.extreme":  "Ekstrem",
+		"level.unknown":  "Tidak Diketahui",
+
+		"card.all_clear":      "Aman",
+		"card.no_alerts":      "Tidak ada peringatan cuaca aktif",
+		"card.no_alerts_for":  "Tidak ada peringatan cuaca aktif untuk %s",
+		"card.all_clear_text": "Saat ini tidak ada peringatan cuaca yang berlaku. Tetap waspada dan selamat beraktivitas!",
+		"card.effective":      "Berlaku",
+		"card.expires":        "Berakhir",
+		"card.risk_title":     "Risiko %s - Banjir Rob",
+		"card.high_tide":      "Pasang Tinggi",
+		"card.height":         "Tinggi",
+		"card.score":          "Skor",
+		"card.tide_summary":   "Pasang %s  Tinggi %.1f m  Skor %d/100",
+		"card.observed_event": "Banjir Terpantau",
+
+		"list.active_alert":  "1 peringatan aktif",
+		"list.active_alerts": "%d peringatan aktif",
+		"list.title_for":     "%s untuk %s",
+		"list.highest_risk":  "Risiko banjir tertinggi: %s",
+		"list.no_risk":       "Tidak ada risiko banjir rob",
+		"list.showing":       "%s - menampilkan %d dari %d",
+
+		"chart.title":   "Pasang Surut %s",
+		"chart.period":  "%s s.d. %s",
+		"chart.flood":   "Banjir %.2f m",
+		"chart.now":     "sekarang",
+		"chart.no_data": "Belum ada data pasang surut untuk periode ini.",
+	},
+}
+
+// T returns the message of a key in the language, formatted with the arguments.
+// Missing messages fall back to the default language, then to the key itself.
+func T(lang, key string, args ...interface{}) string {
+	message, ok := catalogs[lang][key]
+	if !ok {
+		if message, ok = catalogs[Default][key]; !ok {
+			return key
+		}
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Supported reports whether the language has a catalog
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Languages returns the supported languages, for error messages
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Negotiate picks the supported language an Accept-Language header prefers most, or the default.
+// Regional variants match their base language ("id-ID" is "id").
+func Negotiate(acceptLanguage string) string {
+	best, bestQuality := Default, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !Supported(lang) {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		if quality > bestQuality {
+			best, bestQuality = lang, quality
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,66 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{"empty", "", EN},
+		{"single", "id", ID},
+		{"region stripped", "id-ID", ID},
+		{"upper case region", "ID-id,en;q=0.5", ID},
+		{"higher quality wins", "en;q=0.1, id", ID},
+		{"unsupported first", "fr, id;q=0.5", ID},
+		{"order breaks ties", "id;q=0.8, en;q=0.8", ID},
+		{"quality above the first", "en-US;q=0.7, id;q=0.9", ID},
+		{"unparseable quality ignored", "id;q=abc, en;q=0.2", EN},
+		{"zero quality ignored", "id;q=0", EN},
+		{"only unsupported", "fr, de;q=0.9", EN},
+		{"wildcard", "*", EN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.acceptLanguage); got != tt.want {
+				t.Errorf("Negotiate(%q) = %s, want %s", tt.acceptLanguage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		name string
+		lang string
+		key  string
+		args []interface{}
+		want string
+	}{
+		{"english", EN, "card.observed_event", nil, "Flooding Observed"},
+		{"indonesian", ID, "card.observed_event", nil, "Banjir Terpantau"},
+		{"unsupported falls back to default", "fr", "card.observed_event", nil, "Flooding Observed"},
+		{"unknown key", ID, "card.unknown", nil, "card.unknown"},
+		{"formatted", EN, "risk.observed", []interface{}{3.1, 2.8}, "FLOODING OBSERVED: The water level sensor reads 3.10m, above the 2.80m flood threshold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("T(%s, %s) = %q, want %q", tt.lang, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for key := range catalogs[Default] {
+		for _, lang := range Languages() {
+			if _, ok := catalogs[lang][key]; !ok {
+				t.Errorf("%s misses the %s message", lang, key)
+			}
+		}
+	}
+}
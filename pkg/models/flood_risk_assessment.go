@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shadowbane/weather-alert/pkg/helpers"
+
+	"gorm.io/gorm"
+)
+
+// FloodRiskAssessmentLegacyIndex is the unique index used before assessments were made per home
+const FloodRiskAssessmentLegacyIndex = "idx_flood_risk_assessment_alert_location"
+
+// FloodRiskAssessment stores the tidal flood risk computed for an alert at a tide station,
+// either for the station itself or for a home using it
+type FloodRiskAssessment struct {
+	ID            string `json:"id" gorm:"type:char(26);primaryKey;autoIncrement:false"`
+	AlertDetailID string `json:"alert_detail_id" gorm:"type:char(26);uniqueIndex:idx_flood_risk_assessment_alert_target"`
+	Location      string `json:"location" gorm:"type:varchar(255);uniqueIndex:idx_flood_risk_assessment_alert_target"`
+	// HomeID is the home the assessment was computed for, empty for the station assessment
+	HomeID string `json:"home_id" gorm:"type:varchar(26);default:'';uniqueIndex:idx_flood_risk_assessment_alert_target"`
+	// TideDataID is the high tide matched by the rule, tide data is replaced on every fetch
+	// so the tide time and height are kept alongside
+	TideDataID  *string    `json:"tide_data_id" gorm:"type:char(26)"`
+	TideTime    *time.Time `json:"tide_time" gorm:"type:timestamp"`
+	TideHeightM float64    `json:"tide_height_m"`
+	// WaterLevelM and WaterLevelAt are the highest predicted water level inside the window of the rule that fired
+	WaterLevelM  float64    `json:"water_level_m"`
+	WaterLevelAt *time.Time `json:"water_level_at" gorm:"type:timestamp"`
+	// SurgeM and AnomalyM are added to the astronomical heights above for the rule thresholds
+	SurgeM    float64 `json:"surge_m"`
+	AnomalyM  float64 `json:"anomaly_m"`
+	HasRisk   bool    `json:"has_risk"`
+	Level     string  `json:"level" gorm:"index;type:varchar(20)"`
+	Score     int     `json:"score"`
+	HeavyRain bool    `json:"heavy_rain"`
+	// RainIntensity is the rain intensity announced by the alert (none, light, moderate, heavy, very_heavy, extreme)
+	RainIntensity string    `json:"rain_intensity" gorm:"type:varchar(20);default:'none'"`
+	Rule          string    `json:"rule" gorm:"type:varchar(255)"`
+	Message       string    `json:"message" gorm:"type:text"`
+	RuleVersion   string    `json:"rule_version" gorm:"type:varchar(255)"`
+	ComputedAt    time.Time `json:"computed_at" gorm:"index;type:timestamp"`
+	CreatedAt     time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (a *FloodRiskAssessment) TableName() string {
+	return "flood_risk_assessments"
+}
+
+// BeforeCreate will set a ULID rather than numeric ID.
+func (a *FloodRiskAssessment) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == "" {
+		a.ID = helpers.NewULID()
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/geo"
+	"github.com/shadowbane/weather-alert/pkg/helpers"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// Home is a household location with its own tide station and flood threshold
+type Home struct {
+	ID        string  `json:"id" gorm:"type:char(26);primaryKey;autoIncrement:false"`
+	Name      string  `json:"name" gorm:"uniqueIndex;type:varchar(255)"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Area is an optional polygon in the CAP "lat,lon lat,lon ..." format, replacing the point for alert matching
+	Area string `json:"area" gorm:"type:text"`
+	// Station is the tide station name, empty to pick it from the keywords
+	Station string `json:"station" gorm:"type:varchar(255)"`
+	// FloodThresholdM is the tide height (meters) flooding the home, 0 uses the risk rules as they are
+	FloodThresholdM float64 `json:"flood_threshold_m"`
+	// Keywords are BMKG alert location names covering the home (matched in the alert description)
+	Keywords  []string  `json:"keywords" gorm:"serializer:json;type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (h *Home) TableName() string {
+	return "homes"
+}
+
+// BeforeCreate will set a ULID rather than numeric ID.
+func (h *Home) BeforeCreate(tx *gorm.DB) (err error) {
+	if h.ID == "" {
+		h.ID = helpers.NewULID()
+	}
+	return nil
+}
+
+// Point returns the home coordinate
+func (h *Home) Point() geo.Point {
+	return geo.Point{Lat: h.Latitude, Lon: h.Longitude}
+}
+
+// Polygon returns the home area, nil when the home is a single point or the area is invalid
+func (h *Home) Polygon() geo.Polygon {
+	if h.Area == "" {
+		return nil
+	}
+
+	polygons, err := geo.ParsePolygons(h.Area)
+	if err != nil || len(polygons) == 0 {
+		return nil
+	}
+	return polygons[0]
+}
+
+// MatchesAlert reports whether the alert covers the home: its polygon contains the home point
+// (or intersects the home area), or its description mentions one of the home keywords
+func (h *Home) MatchesAlert(alert weathermodels.AlertDetail) bool {
+	description := strings.ToLower(alert.Description)
+	for _, keyword := range h.Keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(description, keyword) {
+			return true
+		}
+	}
+
+	polygons, err := geo.ParsePolygons(alert.Polygon)
+	if err != nil {
+		return false
+	}
+
+	area := h.Polygon()
+	for _, polygon := range polygons {
+		if area != nil && polygon.Intersects(area) {
+			return true
+		}
+		if area == nil && polygon.Contains(h.Point()) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shadowbane/weather-alert/pkg/helpers"
+
+	"gorm.io/gorm"
+)
+
+// RiskNotificationState stores the last risk level of a flood risk window delivered to a sink,
+// so a transition is never notified twice (also across restarts) and a failed delivery is retried
+type RiskNotificationState struct {
+	ID       string `json:"id" gorm:"type:char(26);primaryKey;autoIncrement:false"`
+	Location string `json:"location" gorm:"index;type:varchar(255)"`
+	// HomeID is set for the flooding observed at a home, empty for the station windows
+	HomeID string `json:"home_id" gorm:"type:varchar(26);default:'';index"`
+	Sink   string `json:"sink" gorm:"index;type:varchar(50)"`
+	// Observed states follow a sensor reading rather than a high tide, TideTime being the start of the flooding
+	Observed   bool      `json:"observed"`
+	TideTime   time.Time `json:"tide_time" gorm:"index;type:timestamp"`
+	Level      string    `json:"level" gorm:"type:varchar(20)"`
+	Score      int       `json:"score"`
+	NotifiedAt time.Time `json:"notified_at" gorm:"type:timestamp"`
+	CreatedAt  time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (s *RiskNotificationState) TableName() string {
+	return "risk_notification_states"
+}
+
+// BeforeCreate will set a ULID rather than numeric ID.
+func (s *RiskNotificationState) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = helpers.NewULID()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shadowbane/weather-alert/pkg/helpers"
+
+	"gorm.io/gorm"
+)
+
+// SeaLevelAnomaly is a manually reported observed-minus-predicted water level of a tide station,
+// added to the predicted tide heights while it is valid
+type SeaLevelAnomaly struct {
+	ID       string `json:"id" gorm:"type:char(26);primaryKey;autoIncrement:false"`
+	Location string `json:"location" gorm:"index;type:varchar(255)"`
+	// AnomalyM is the observed minus the predicted water level in meters, negative when the sea is lower
+	AnomalyM   float64   `json:"anomaly_m"`
+	Note       string    `json:"note" gorm:"type:text"`
+	ValidFrom  time.Time `json:"valid_from" gorm:"index;type:timestamp"`
+	ValidUntil time.Time `json:"valid_until" gorm:"index;type:timestamp"`
+	CreatedAt  time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (a *SeaLevelAnomaly) TableName() string {
+	return "sea_level_anomalies"
+}
+
+// BeforeCreate will set a ULID rather than numeric ID.
+func (a *SeaLevelAnomaly) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == "" {
+		a.ID = helpers.NewULID()
+	}
+	return nil
+}
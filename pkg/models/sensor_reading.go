@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shadowbane/weather-alert/pkg/helpers"
+
+	"gorm.io/gorm"
+)
+
+// SensorReading is a water level published by a sensor, kept as a time series
+type SensorReading struct {
+	ID string `json:"id" gorm:"type:char(26);primaryKey;autoIncrement:false"`
+	// Sensor is the MQTT topic of the sensor
+	Sensor string    `json:"sensor" gorm:"index;type:varchar(255)"`
+	HomeID string    `json:"home_id" gorm:"type:varchar(26);index:idx_sensor_reading_home_time"`
+	ReadAt time.Time `json:"read_at" gorm:"type:timestamp;index:idx_sensor_reading_home_time"`
+	// LevelM is the reading converted to the tide datum, RawLevelM the published value
+	LevelM    float64 `json:"level_m"`
+	RawLevelM float64 `json:"raw_level_m"`
+	// ThresholdM is the home flood threshold at the time of the reading, Flooded whether the level exceeded it
+	ThresholdM float64   `json:"threshold_m"`
+	Flooded    bool      `json:"flooded" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"type:timestamp"`
+}
+
+func (r *SensorReading) TableName() string {
+	return "sensor_readings"
+}
+
+// BeforeCreate will set a ULID rather than numeric ID.
+func (r *SensorReading) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = helpers.NewULID()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shadowbane/weather-alert/pkg/helpers"
+
+	"gorm.io/gorm"
+)
+
+// WaterObservation is a water level checked on site, e.g. the drain near a home
+type WaterObservation struct {
+	ID string `json:"id" gorm:"type:char(26);primaryKey;autoIncrement:false"`
+	// HomeID is the home the observation was made at, empty when only the station is known
+	HomeID string `json:"home_id" gorm:"index;type:varchar(26);default:''"`
+	// Location is the tide station the observation is compared with
+	Location   string    `json:"location" gorm:"index;type:varchar(255)"`
+	ObservedAt time.Time `json:"observed_at" gorm:"index;type:timestamp"`
+	LevelM     float64   `json:"level_m"`
+	Flooded    bool      `json:"flooded"`
+	PhotoURL   string    `json:"photo_url" gorm:"type:text"`
+	Note       string    `json:"note" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at" gorm:"type:timestamp"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"type:timestamp"`
+}
+
+func (o *WaterObservation) TableName() string {
+	return "water_observations"
+}
+
+// BeforeCreate will set a ULID rather than numeric ID.
+func (o *WaterObservation) BeforeCreate(tx *gorm.DB) (err error) {
+	if o.ID == "" {
+		o.ID = helpers.NewULID()
+	}
+	return nil
+}
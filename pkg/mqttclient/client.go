@@ -0,0 +1,138 @@
+package mqttclient
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"go.uber.org/zap"
+)
+
+// Connection settings of the MQTT client
+const (
+	connectTimeout       = 10 * time.Second
+	connectRetryInterval = 30 * time.Second
+	disconnectQuiesce    = 250
+	QoS                  = 1
+)
+
+// Availability payloads, retained on the availability topic (the broker publishes offline when the connection drops)
+const (
+	PayloadOnline  = "online"
+	PayloadOffline = "offline"
+)
+
+// Client is the MQTT connection shared by the components of the service.
+// The handlers registered with OnConnect run on every (re)connection.
+type Client struct {
+	client            mqtt.Client
+	availabilityTopic string
+
+	mu       sync.Mutex
+	handlers []func()
+}
+
+// New creates a new Client for the configured broker, nil when no broker is configured
+func New(cfg config.MQTTConfig) *Client {
+	if cfg.BrokerURL == "" {
+		return nil
+	}
+
+	c := &Client{availabilityTopic: cfg.BaseTopic + "/status"}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(connectRetryInterval).
+		SetWill(c.availabilityTopic, PayloadOffline, QoS, true).
+		SetOnConnectHandler(func(mqtt.Client) { c.connected() }).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			zap.S().Warnf("Lost connection to MQTT broker: %v", err)
+		})
+	c.client = mqtt.NewClient(options)
+
+	return c
+}
+
+// AvailabilityTopic returns the topic carrying whether the service is online
+func (c *Client) AvailabilityTopic() string {
+	return c.availabilityTopic
+}
+
+// OnConnect registers a handler run after every (re)connection, to subscribe and publish the retained states.
+// Handlers must be registered before the client starts.
+func (c *Client) OnConnect(handler func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler)
+}
+
+// Start connects to the broker, an unreachable broker is retried in the background
+func (c *Client) Start() {
+	token := c.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		zap.S().Warnf("MQTT broker not reachable yet, retrying every %s", connectRetryInterval)
+		return
+	}
+	if err := token.Error(); err != nil {
+		zap.S().Errorf("Failed to connect to MQTT broker: %v", err)
+	}
+}
+
+// Stop marks the service offline and disconnects from the broker
+func (c *Client) Stop() {
+	if c.client.IsConnected() {
+		if err := c.Publish(c.availabilityTopic, true, []byte(PayloadOffline)); err != nil {
+			zap.S().Warnf("Failed to publish MQTT availability: %v", err)
+		}
+	}
+	c.client.Disconnect(disconnectQuiesce)
+}
+
+// Subscribe subscribes to a topic, the handler receives the topic and payload of every message
+func (c *Client) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	token := c.client.Subscribe(topic, QoS, func(_ mqtt.Client, message mqtt.Message) {
+		handler(message.Topic(), message.Payload())
+	})
+	return wait(token, "subscribe to", topic)
+}
+
+// Publish publishes a payload on a topic, retained messages are delivered to later subscribers
+func (c *Client) Publish(topic string, retained bool, payload []byte) error {
+	return wait(c.client.Publish(topic, QoS, retained, payload), "publish on", topic)
+}
+
+// connected publishes the availability and runs the connection handlers
+func (c *Client) connected() {
+	zap.S().Info("Connected to MQTT broker")
+
+	if err := c.Publish(c.availabilityTopic, true, []byte(PayloadOnline)); err != nil {
+		zap.S().Warnf("Failed to publish MQTT availability: %v", err)
+	}
+
+	c.mu.Lock()
+	handlers := append([]func(){}, c.handlers...)
+	c.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// wait waits for a token to complete and describes its failure
+func wait(token mqtt.Token, action, topic string) error {
+	if !token.WaitTimeout(connectTimeout) {
+		return fmt.Errorf("timed out trying to %s %s", action, topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to %s %s: %w", action, topic, err)
+	}
+	return nil
+}
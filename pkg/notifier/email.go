@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"fmt"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// EmailSink sends events as plain text emails over SMTP
+type EmailSink struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+// NewEmailSink creates a new EmailSink instance
+func NewEmailSink(host string, port int, username, password, from string, to []string) *EmailSink {
+	return &EmailSink{
+		addr:     host + ":" + strconv.Itoa(port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+	}
+}
+
+// Name returns the sink name
+func (s *EmailSink) Name() string {
+	return "email"
+}
+
+// Send emails the event to all recipients
+func (s *EmailSink) Send(event Event) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		auth = smtp.PlainAuth("", s.username, s.password, s.host)
+	}
+
+	if err := smtp.SendMail(s.addr, auth, s.from, s.to, s.message(event)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	return nil
+}
+
+// message renders the event as a plain text email with its headers
+func (s *EmailSink) message(event Event) []byte {
+	return []byte(strings.Join([]string{
+		"From: " + s.from,
+		"To: " + strings.Join(s.to, ", "),
+		"Subject: " + formatSubject(event),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"Content-Transfer-Encoding: 8bit",
+		"",
+		FormatMessage(event),
+	}, "\r\n"))
+}
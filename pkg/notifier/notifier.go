@@ -0,0 +1,413 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	weathermodels "github.com/shadowbane/weather-alert/pkg/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Transitions of a flood risk window level
+const (
+	TransitionRaised  = "raised"
+	TransitionLowered = "lowered"
+	TransitionCleared = "cleared"
+)
+
+// stateMatchTolerance is how far a tide time may move between fetches and still be the same window
+const stateMatchTolerance = time.Hour
+
+// stateRetention is how long notification states are kept after their tide passed
+const stateRetention = 24 * time.Hour
+
+// Event is emitted when the risk level of a flood risk window changes
+type Event struct {
+	Transition string `json:"transition"`
+	Location   string `json:"location"`
+	// Home is set for the flooding observed by the sensor of a home, Location being its station
+	Home          string    `json:"home,omitempty"`
+	Observed      bool      `json:"observed,omitempty"`
+	Level         string    `json:"level"`
+	PreviousLevel string    `json:"previous_level"`
+	Score         int       `json:"score"`
+	Message       string    `json:"message"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	TideTime      time.Time `json:"tide_time"`
+	TideHeightM   float64   `json:"tide_height_m"`
+	AlertEvent    string    `json:"alert_event,omitempty"`
+	AlertHeadline string    `json:"alert_headline,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+
+	// Timezone of the station, used for human-readable messages
+	Timezone *time.Location `json:"-"`
+}
+
+// Sink delivers flood risk events to an external service
+type Sink interface {
+	// Name identifies the sink in logs
+	Name() string
+	// Send delivers a single event
+	Send(event Event) error
+}
+
+// Notifier watches the flood risk windows and notifies the sinks when a window changes level
+type Notifier struct {
+	db    *gorm.DB
+	cfg   *config.Config
+	rules *floodrisk.RuleSet
+	tides *floodrisk.TideCache
+	sinks []Sink
+	mu    sync.Mutex
+}
+
+// New creates a new Notifier for the alerts of the configured provinces
+func New(db *gorm.DB, cfg *config.Config, rules *floodrisk.RuleSet, tides *floodrisk.TideCache, sinks ...Sink) *Notifier {
+	return &Notifier{
+		db:    db,
+		cfg:   cfg,
+		rules: rules,
+		tides: tides,
+		sinks: sinks,
+	}
+}
+
+// NewSinks creates the sinks enabled in the notification settings
+func NewSinks(cfg config.NotifyConfig) []Sink {
+	sinks := make([]Sink, 0)
+
+	if cfg.WebhookURL != "" {
+		sinks = append(sinks, NewWebhookSink(cfg.WebhookURL, cfg.WebhookSecret))
+	}
+	if cfg.TelegramBotToken != "" && cfg.TelegramChatID != "" {
+		sinks = append(sinks, NewTelegramSink(cfg.TelegramBotToken, cfg.TelegramChatID))
+	}
+	if cfg.SMTPHost != "" && len(cfg.SMTPTo) > 0 {
+		sinks = append(sinks, NewEmailSink(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPTo))
+	}
+
+	return sinks
+}
+
+// Evaluate recomputes the flood risk windows of every station and emits an event for each level change.
+// Runs are serialized, as both fetchers trigger it.
+func (n *Notifier) Evaluate() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now().UTC()
+	to := now.Add(time.Duration(n.cfg.GetNotifyConfig().HorizonHours) * time.Hour)
+
+	var alerts []weathermodels.AlertDetail
+	if err := n.rules.TimelineAlertsQuery(n.db, n.cfg.GetProvinces(), now, to).Order("sent DESC").Find(&alerts).Error; err != nil {
+		zap.S().Errorf("Failed to query alerts for notifications: %v", err)
+		return
+	}
+
+	// Every alert belongs to the station configured for its area
+	stationAlerts := make(map[string][]weathermodels.AlertDetail)
+	for _, alert := range alerts {
+		station := n.cfg.GetTideStationFor(alert.Description)
+		stationAlerts[station.Name] = append(stationAlerts[station.Name], alert)
+	}
+
+	for _, station := range n.cfg.GetTideStations() {
+		windows, err := n.rules.Timeline(n.tides, station.Name, stationAlerts[station.Name], now, to)
+		if err != nil {
+			zap.S().Errorf("Failed to evaluate flood risk for %s: %v", station.Name, err)
+			continue
+		}
+		n.evaluateTarget(station, nil, windows, now)
+	}
+
+	// Homes with a water level sensor are notified of the flooding they observe, their predicted risk is the one of their station
+	for _, home := range n.sensorHomes() {
+		observed, err := floodrisk.ObservedWindow(n.db, n.cfg, home, now)
+		if err != nil {
+			zap.S().Errorf("Failed to evaluate the observed flooding of %s: %v", home.Name, err)
+			continue
+		}
+		n.evaluateTarget(floodrisk.HomeStation(n.cfg, home), &home, floodrisk.WithObserved(nil, observed, now, to), now)
+	}
+
+	// Forget windows long gone, observed windows once cleared
+	if err := n.db.Where("tide_time < ? AND (observed = ? OR level = ?)", now.Add(-stateRetention), false, floodrisk.LevelNone).
+		Delete(&models.RiskNotificationState{}).Error; err != nil {
+		zap.S().Errorf("Failed to clean up notification states: %v", err)
+	}
+}
+
+// sensorHomes returns the homes with a water level sensor
+func (n *Notifier) sensorHomes() []models.Home {
+	names := make([]string, 0)
+	for _, sensor := range n.cfg.GetMQTTConfig().Sensors {
+		names = append(names, strings.ToLower(sensor.Home))
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	var homes []models.Home
+	if err := n.db.Where("LOWER(name) IN ?", names).Find(&homes).Error; err != nil {
+		zap.S().Errorf("Failed to query homes with a water level sensor: %v", err)
+		return nil
+	}
+	return homes
+}
+
+// evaluateTarget compares the windows of a station, or of a home using it, with the last states delivered to every sink.
+// Observed states are kept until the flooding ends, even once their time passed.
+func (n *Notifier) evaluateTarget(station config.TideStation, home *models.Home, windows []floodrisk.Window, now time.Time) {
+	homeID := ""
+	if home != nil {
+		homeID = home.ID
+	}
+
+	var states []models.RiskNotificationState
+	if err := n.db.Where("location = ? AND home_id = ?", station.Name, homeID).
+		Where("tide_time >= ? OR (observed = ? AND level <> ?)", now.Add(-n.rules.MaxBuffer()-stateMatchTolerance), true, floodrisk.LevelNone).
+		Find(&states).Error; err != nil {
+		zap.S().Errorf("Failed to query notification states for %s: %v", station.Name, err)
+		return
+	}
+
+	// Each sink keeps its own states, so a failed delivery is retried on the next run without repeating the others
+	for _, sink := range n.sinks {
+		sinkStates := make([]models.RiskNotificationState, 0)
+		for _, state := range states {
+			if state.Sink == sink.Name() {
+				sinkStates = append(sinkStates, state)
+			}
+		}
+		n.evaluateSink(sink, station, home, windows, sinkStates, now)
+	}
+}
+
+// evaluateSink sends the level changes of the windows to a sink, a state is only saved once delivered
+func (n *Notifier) evaluateSink(sink Sink, station config.TideStation, home *models.Home, windows []floodrisk.Window, states []models.RiskNotificationState, now time.Time) {
+	matched := make(map[string]bool)
+
+	for _, window := range windows {
+		level := n.notifiableLevel(window.Level)
+		state := findState(states, windowTime(window), window.Observed != nil, matched)
+
+		previous := floodrisk.LevelNone
+		if state != nil {
+			previous = state.Level
+			matched[state.ID] = true
+		}
+
+		if level == previous {
+			continue
+		}
+
+		event := Event{
+			Transition:    transition(previous, level),
+			Location:      station.Name,
+			Home:          homeName(home),
+			Observed:      window.Observed != nil,
+			Level:         level,
+			PreviousLevel: previous,
+			Score:         window.Score,
+			Message:       window.Message,
+			Start:         window.Start,
+			End:           window.End,
+			TideTime:      windowTime(window),
+			TideHeightM:   window.Tide.HeightM,
+			OccurredAt:    now,
+			Timezone:      station.Timezone,
+		}
+		if window.Observed != nil {
+			event.TideHeightM = window.Observed.LevelM
+		}
+		if window.Alert != nil {
+			event.AlertEvent = window.Alert.Event
+			event.AlertHeadline = window.Alert.Headline
+		}
+
+		if !n.send(sink, event) {
+			continue
+		}
+
+		if state == nil {
+			state = &models.RiskNotificationState{
+				Location: station.Name,
+				HomeID:   homeIDOf(home),
+				Sink:     sink.Name(),
+				Observed: window.Observed != nil,
+			}
+		}
+		state.TideTime = windowTime(window)
+		state.Level = level
+		state.Score = window.Score
+		state.NotifiedAt = now
+		if err := n.db.Save(state).Error; err != nil {
+			zap.S().Errorf("Failed to save notification state: %v", err)
+		}
+	}
+
+	// Upcoming windows that disappeared (alert withdrawn, tide revised) and observed flooding that ended are cleared
+	for i := range states {
+		state := &states[i]
+		if matched[state.ID] || state.Level == floodrisk.LevelNone || (state.TideTime.Before(now) && !state.Observed) {
+			continue
+		}
+
+		if !n.send(sink, Event{
+			Transition:    TransitionCleared,
+			Location:      station.Name,
+			Home:          homeName(home),
+			Observed:      state.Observed,
+			Level:         floodrisk.LevelNone,
+			PreviousLevel: state.Level,
+			Message:       "Flood risk cleared",
+			TideTime:      state.TideTime,
+			OccurredAt:    now,
+			Timezone:      station.Timezone,
+		}) {
+			continue
+		}
+
+		state.Level = floodrisk.LevelNone
+		state.Score = 0
+		state.NotifiedAt = now
+		if err := n.db.Save(state).Error; err != nil {
+			zap.S().Errorf("Failed to save notification state: %v", err)
+		}
+	}
+}
+
+// notifiableLevel maps levels below the configured minimum to none
+func (n *Notifier) notifiableLevel(level string) string {
+	if floodrisk.LevelRank(level) < floodrisk.LevelRank(n.cfg.GetNotifyConfig().MinLevel) {
+		return floodrisk.LevelNone
+	}
+	return level
+}
+
+// send delivers the event to a sink and reports whether it succeeded, failures are logged and retried on the next run
+func (n *Notifier) send(sink Sink, event Event) bool {
+	if err := sink.Send(event); err != nil {
+		zap.S().Errorf("Failed to send flood risk event to %s: %v", sink.Name(), err)
+		return false
+	}
+
+	zap.S().Infof("Flood risk %s at %s sent to %s: %s -> %s", event.Transition, event.Location, sink.Name(), event.PreviousLevel, event.Level)
+	return true
+}
+
+// findState returns the unmatched state of the window with the closest tide time within the tolerance,
+// observed windows only match observed states
+func findState(states []models.RiskNotificationState, tideTime time.Time, observed bool, matched map[string]bool) *models.RiskNotificationState {
+	var found *models.RiskNotificationState
+	var closest time.Duration
+
+	for i := range states {
+		if matched[states[i].ID] || states[i].Observed != observed {
+			continue
+		}
+
+		diff := states[i].TideTime.Sub(tideTime).Abs()
+		if diff <= stateMatchTolerance && (found == nil || diff < closest) {
+			found = &states[i]
+			closest = diff
+		}
+	}
+
+	return found
+}
+
+// windowTime identifies a window in the notification states: the time of its high tide,
+// or the start of the flooding for observed windows
+func windowTime(window floodrisk.Window) time.Time {
+	if window.Observed != nil {
+		return window.Start
+	}
+	return window.Tide.TideTime
+}
+
+// homeName returns the name of the home, empty for stations
+func homeName(home *models.Home) string {
+	if home == nil {
+		return ""
+	}
+	return home.Name
+}
+
+// homeIDOf returns the ID of the home, empty for stations
+func homeIDOf(home *models.Home) string {
+	if home == nil {
+		return ""
+	}
+	return home.ID
+}
+
+// transition names the change from one level to another
+func transition(previous, level string) string {
+	switch {
+	case level == floodrisk.LevelNone:
+		return TransitionCleared
+	case floodrisk.LevelRank(level) > floodrisk.LevelRank(previous):
+		return TransitionRaised
+	default:
+		return TransitionLowered
+	}
+}
+
+// FormatMessage renders the event as plain text for chat and email sinks
+func FormatMessage(event Event) string {
+	loc := event.Timezone
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var b strings.Builder
+	if event.Transition == TransitionCleared {
+		fmt.Fprintf(&b, "✅ Flood risk cleared at %s (was %s)\n", place(event), strings.ToUpper(event.PreviousLevel))
+		if event.Observed {
+			fmt.Fprintf(&b, "Flooding observed since: %s\n", event.TideTime.In(loc).Format("2006-01-02 15:04 MST"))
+		} else {
+			fmt.Fprintf(&b, "High tide: %s\n", event.TideTime.In(loc).Format("2006-01-02 15:04 MST"))
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "🌊 Flood risk %s to %s at %s (score %d/100)\n",
+		event.Transition, strings.ToUpper(event.Level), place(event), event.Score)
+	fmt.Fprintf(&b, "%s\n", event.Message)
+	if event.Observed {
+		fmt.Fprintf(&b, "Water level: %.2f m observed since %s\n", event.TideHeightM, event.TideTime.In(loc).Format("2006-01-02 15:04 MST"))
+	} else {
+		fmt.Fprintf(&b, "High tide: %.1f m at %s\n", event.TideHeightM, event.TideTime.In(loc).Format("2006-01-02 15:04 MST"))
+	}
+	fmt.Fprintf(&b, "Window: %s - %s\n", event.Start.In(loc).Format("2006-01-02 15:04"), event.End.In(loc).Format("15:04 MST"))
+	if event.AlertEvent != "" {
+		fmt.Fprintf(&b, "Alert: %s\n", event.AlertEvent)
+	}
+
+	return b.String()
+}
+
+// formatSubject renders a short subject line for the event
+func formatSubject(event Event) string {
+	if event.Transition == TransitionCleared {
+		return fmt.Sprintf("[Flood Risk] Cleared at %s", place(event))
+	}
+	return fmt.Sprintf("[Flood Risk] %s at %s", strings.ToUpper(event.Level), place(event))
+}
+
+// place names where the event happens: the home, or the station
+func place(event Event) string {
+	if event.Home != "" {
+		return event.Home
+	}
+	return event.Location
+}
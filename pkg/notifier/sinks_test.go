@@ -0,0 +1,228 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEvent is a raised event at a WIB station
+func testEvent() Event {
+	wib := time.FixedZone("WIB", 7*60*60)
+	tide := time.Date(2025, 12, 4, 14, 30, 0, 0, time.UTC)
+
+	return Event{
+		Transition:    TransitionRaised,
+		Location:      "Sekupang",
+		Level:         "high",
+		PreviousLevel: "none",
+		Score:         62,
+		Message:       "High tide during heavy rain",
+		Start:         tide.Add(-time.Hour),
+		End:           tide.Add(time.Hour),
+		TideTime:      tide,
+		TideHeightM:   2.9,
+		AlertEvent:    "Hujan Lebat",
+		OccurredAt:    tide.Add(-6 * time.Hour),
+		Timezone:      wib,
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Fixed digests, the second one is the well-known HMAC-SHA256 example
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		{"s3cret", `{"level":"high"}`, "sha256=0ceb472dfd81acca6792ca07a1b70305214079598a5e191bec037e160468f81c"},
+		{"key", "The quick brown fox jumps over the lazy dog", "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.secret, func(t *testing.T) {
+			if got := sign(tt.secret, []byte(tt.body)); got != tt.want {
+				t.Errorf("sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if sign("other", []byte(`{"level":"high"}`)) == tests[0].want {
+		t.Error("sign() with another secret returned the same signature")
+	}
+}
+
+func TestWebhookSinkRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"signed", "s3cret"},
+		{"unsigned", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewWebhookSink("https://example.com/hook", tt.secret).request(testEvent())
+			if err != nil {
+				t.Fatalf("request() error = %v", err)
+			}
+
+			if req.Method != http.MethodPost || req.URL.String() != "https://example.com/hook" {
+				t.Errorf("request = %s %s, want POST https://example.com/hook", req.Method, req.URL)
+			}
+			if got := req.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %s, want application/json", got)
+			}
+			if got := req.Header.Get("X-Flood-Risk-Transition"); got != TransitionRaised {
+				t.Errorf("X-Flood-Risk-Transition = %s, want %s", got, TransitionRaised)
+			}
+
+			body, _ := io.ReadAll(req.Body)
+			var decoded map[string]any
+			if err := json.Unmarshal(body, &decoded); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if decoded["location"] != "Sekupang" || decoded["level"] != "high" {
+				t.Errorf("body = %s, want the event", body)
+			}
+
+			signature := req.Header.Get("X-Signature-256")
+			if tt.secret == "" {
+				if signature != "" {
+					t.Errorf("X-Signature-256 = %s, want none without secret", signature)
+				}
+				return
+			}
+			if signature != sign(tt.secret, body) {
+				t.Errorf("X-Signature-256 = %s, want the signature of the body", signature)
+			}
+		})
+	}
+}
+
+func TestWebhookSinkSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"ok", http.StatusOK, false},
+		{"no content", http.StatusNoContent, false},
+		{"server error", http.StatusInternalServerError, true},
+		{"unauthorized", http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verified bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mac := hmac.New(sha256.New, []byte("s3cret"))
+				mac.Write(body)
+				verified = hmac.Equal([]byte(r.Header.Get("X-Signature-256")), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := NewWebhookSink(server.URL, "s3cret").Send(testEvent())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !verified {
+				t.Error("receiver could not verify the signature")
+			}
+		})
+	}
+}
+
+func TestTelegramSinkRequest(t *testing.T) {
+	req, err := NewTelegramSink("123:abc", "-100200").request(testEvent())
+	if err != nil {
+		t.Fatalf("request() error = %v", err)
+	}
+
+	if want := "https://api.telegram.org/bot123:abc/sendMessage"; req.URL.String() != want {
+		t.Errorf("URL = %s, want %s", req.URL, want)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %s, want application/json", got)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body["chat_id"] != "-100200" {
+		t.Errorf("chat_id = %s, want -100200", body["chat_id"])
+	}
+	if body["text"] != FormatMessage(testEvent()) {
+		t.Errorf("text = %q, want the formatted message", body["text"])
+	}
+}
+
+func TestTelegramSinkSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sink := NewTelegramSink("123:abc", "-100200")
+	sink.apiURL = server.URL + "/bot"
+
+	err := sink.Send(testEvent())
+	if err == nil {
+		t.Fatal("Send() error = nil, want the status code")
+	}
+	if strings.Contains(err.Error(), "123:abc") {
+		t.Errorf("Send() error %q leaks the bot token", err)
+	}
+}
+
+func TestEmailSinkMessage(t *testing.T) {
+	sink := NewEmailSink("smtp.example.com", 587, "user", "pass", "alerts@example.com", []string{"a@example.com", "b@example.com"})
+	msg := string(sink.message(testEvent()))
+
+	headers, body, found := strings.Cut(msg, "\r\n\r\n")
+	if !found {
+		t.Fatalf("message has no header separator: %q", msg)
+	}
+
+	for _, header := range []string{
+		"From: alerts@example.com",
+		"To: a@example.com, b@example.com",
+		"Subject: [Flood Risk] HIGH at Sekupang",
+		"Content-Type: text/plain; charset=UTF-8",
+	} {
+		if !strings.Contains(headers+"\r\n", header+"\r\n") {
+			t.Errorf("headers %q lack %q", headers, header)
+		}
+	}
+	if body != FormatMessage(testEvent()) {
+		t.Errorf("body = %q, want the formatted message", body)
+	}
+}
+
+func TestFormatMessage(t *testing.T) {
+	raised := FormatMessage(testEvent())
+	for _, want := range []string{"raised to HIGH at Sekupang (score 62/100)", "High tide: 2.9 m at 2025-12-04 21:30 WIB", "Window: 2025-12-04 20:30 - 22:30 WIB", "Alert: Hujan Lebat"} {
+		if !strings.Contains(raised, want) {
+			t.Errorf("FormatMessage() = %q, want it to contain %q", raised, want)
+		}
+	}
+
+	event := testEvent()
+	event.Transition = TransitionCleared
+	event.PreviousLevel = "high"
+	cleared := FormatMessage(event)
+	if !strings.Contains(cleared, "Flood risk cleared at Sekupang (was HIGH)") {
+		t.Errorf("FormatMessage() = %q, want the cleared message", cleared)
+	}
+}
@@ -0,0 +1,26 @@
+package notifier
+
+import (
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/events"
+)
+
+// StreamSink publishes events as risk.changed on the server-sent events stream
+type StreamSink struct {
+	broker *events.Broker
+}
+
+// NewStreamSink creates a new StreamSink instance
+func NewStreamSink(broker *events.Broker) *StreamSink {
+	return &StreamSink{broker: broker}
+}
+
+// Name returns the sink name
+func (s *StreamSink) Name() string {
+	return "stream"
+}
+
+// Send publishes the event to the stream subscribers
+func (s *StreamSink) Send(event Event) error {
+	s.broker.Publish(events.TypeRiskChanged, event)
+	return nil
+}
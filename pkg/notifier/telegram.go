@@ -0,0 +1,75 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// telegramAPIURL is the Telegram Bot API base URL
+const telegramAPIURL = "https://api.telegram.org/bot"
+
+// TelegramSink sends events as messages through the Telegram Bot API
+type TelegramSink struct {
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+// NewTelegramSink creates a new TelegramSink instance
+func NewTelegramSink(token, chatID string) *TelegramSink {
+	return &TelegramSink{
+		apiURL: telegramAPIURL,
+		token:  token,
+		chatID: chatID,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the sink name
+func (s *TelegramSink) Name() string {
+	return "telegram"
+}
+
+// Send sends the event as a text message to the configured chat
+func (s *TelegramSink) Send(event Event) error {
+	req, err := s.request(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error contains the URL, don't leak the bot token to the logs
+		return fmt.Errorf("failed to send telegram message")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram returned status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// request builds the sendMessage request of the event
+func (s *TelegramSink) request(event Event) (*http.Request, error) {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": s.chatID,
+		"text":    FormatMessage(event),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.apiURL+s.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create telegram request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
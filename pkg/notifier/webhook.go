@@ -0,0 +1,82 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookSink posts events as JSON to a URL.
+// When a secret is set, the body is signed with HMAC-SHA256 in the X-Signature-256 header ("sha256=<hex>").
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink creates a new WebhookSink instance
+func NewWebhookSink(url, secret string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the sink name
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send posts the event to the webhook URL
+func (s *WebhookSink) Send(event Event) error {
+	req, err := s.request(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// request builds the signed webhook request of the event
+func (s *WebhookSink) request(event Event) (*http.Request, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Flood-Risk-Transition", event.Transition)
+
+	if s.secret != "" {
+		req.Header.Set("X-Signature-256", sign(s.secret, body))
+	}
+
+	return req, nil
+}
+
+// sign returns the "sha256=<hex>" HMAC-SHA256 signature of the body
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
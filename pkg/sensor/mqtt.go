@@ -0,0 +1,200 @@
+package sensor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/mqttclient"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// payload is the JSON form of a reading, sensors may also publish the bare level ("1.23")
+type payload struct {
+	LevelM *float64   `json:"level_m"`
+	Time   *time.Time `json:"time"`
+}
+
+// store finds the home of a sensor and keeps its readings
+type store interface {
+	FindHome(name string) (models.Home, error)
+	SaveReading(reading *models.SensorReading) error
+}
+
+// dbStore is the store of the database
+type dbStore struct {
+	db *gorm.DB
+}
+
+// FindHome returns the home by name, case-insensitively
+func (d dbStore) FindHome(name string) (models.Home, error) {
+	var home models.Home
+	err := d.db.Where("LOWER(name) = ?", strings.ToLower(name)).First(&home).Error
+	return home, err
+}
+
+// SaveReading stores the reading
+func (d dbStore) SaveReading(reading *models.SensorReading) error {
+	return d.db.Create(reading).Error
+}
+
+// Subscriber stores the readings of the water level sensors published on MQTT
+// and flags the readings above the flood threshold of their home
+type Subscriber struct {
+	store   store
+	rules   *floodrisk.RuleSet
+	sensors map[string]config.Sensor
+	hooks   []func(models.SensorReading)
+	// floodedHooks run when a sensor starts or stops observing flooding
+	floodedHooks []func(models.SensorReading)
+
+	mu sync.Mutex
+	// flooded keeps the last flooded flag per sensor to log transitions only
+	flooded map[string]bool
+}
+
+// New creates a new Subscriber for the configured sensors, subscribed on every connection of the client.
+// Returns nil when no broker or no sensor is configured.
+func New(db *gorm.DB, cfg *config.Config, rules *floodrisk.RuleSet, client *mqttclient.Client) *Subscriber {
+	mqttCfg := cfg.GetMQTTConfig()
+	if client == nil || len(mqttCfg.Sensors) == 0 {
+		return nil
+	}
+
+	s := &Subscriber{
+		store:   dbStore{db: db},
+		rules:   rules,
+		sensors: make(map[string]config.Sensor),
+		flooded: make(map[string]bool),
+	}
+	for _, sensor := range mqttCfg.Sensors {
+		s.sensors[sensor.Topic] = sensor
+	}
+
+	client.OnConnect(func() { s.subscribe(client) })
+
+	return s
+}
+
+// OnReading registers a hook run after every stored reading
+// Hooks must be registered before the MQTT client starts
+func (s *Subscriber) OnReading(hook func(models.SensorReading)) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// OnFloodedChange registers a hook run when a reading starts or ends the flooding observed by its sensor.
+// Hooks must be registered before the MQTT client starts
+func (s *Subscriber) OnFloodedChange(hook func(models.SensorReading)) {
+	s.floodedHooks = append(s.floodedHooks, hook)
+}
+
+// subscribe subscribes to the topic of every sensor
+func (s *Subscriber) subscribe(client *mqttclient.Client) {
+	for topic := range s.sensors {
+		err := client.Subscribe(topic, func(topic string, payload []byte) {
+			if _, err := s.Ingest(topic, payload); err != nil {
+				zap.S().Warnf("Failed to ingest sensor reading on %s: %v", topic, err)
+			}
+		})
+		if err != nil {
+			zap.S().Errorf("Failed to subscribe to water level sensor: %v", err)
+			continue
+		}
+		zap.S().Infof("Subscribed to water level sensor %s", topic)
+	}
+}
+
+// Ingest stores a reading published on the topic of a sensor and runs the hooks.
+// It is called for every MQTT message, and can be called directly to feed readings without a broker.
+func (s *Subscriber) Ingest(topic string, message []byte) (models.SensorReading, error) {
+	sensor, ok := s.sensors[topic]
+	if !ok {
+		return models.SensorReading{}, fmt.Errorf("unknown sensor topic '%s'", topic)
+	}
+
+	rawLevel, readAt, err := parsePayload(message)
+	if err != nil {
+		return models.SensorReading{}, err
+	}
+
+	home, err := s.store.FindHome(sensor.Home)
+	if err != nil {
+		return models.SensorReading{}, fmt.Errorf("failed to find home '%s': %w", sensor.Home, err)
+	}
+
+	threshold := home.FloodThresholdM
+	if threshold <= 0 {
+		threshold = s.rules.MinTideHeight()
+	}
+
+	reading := models.SensorReading{
+		Sensor:     topic,
+		HomeID:     home.ID,
+		ReadAt:     readAt,
+		LevelM:     rawLevel + sensor.OffsetM,
+		RawLevelM:  rawLevel,
+		ThresholdM: threshold,
+	}
+	reading.Flooded = reading.LevelM > threshold
+
+	if err := s.store.SaveReading(&reading); err != nil {
+		return models.SensorReading{}, fmt.Errorf("failed to store sensor reading: %w", err)
+	}
+
+	s.mu.Lock()
+	changed := s.flooded[topic] != reading.Flooded
+	if changed {
+		if reading.Flooded {
+			zap.S().Warnf("Sensor %s observes flooding at %s: %.2fm above the %.2fm threshold", topic, home.Name, reading.LevelM, threshold)
+		} else {
+			zap.S().Infof("Sensor %s level at %s back below the threshold: %.2fm", topic, home.Name, reading.LevelM)
+		}
+		s.flooded[topic] = reading.Flooded
+	}
+	s.mu.Unlock()
+
+	for _, hook := range s.hooks {
+		hook(reading)
+	}
+	if changed {
+		for _, hook := range s.floodedHooks {
+			hook(reading)
+		}
+	}
+
+	return reading, nil
+}
+
+// parsePayload reads the level and time of a reading, either a bare number or {"level_m": 1.23, "time": "..."}.
+// Readings without a time are taken now, levels must be finite.
+func parsePayload(message []byte) (float64, time.Time, error) {
+	now := time.Now().UTC()
+	text := strings.TrimSpace(string(message))
+
+	if level, err := strconv.ParseFloat(text, 64); err == nil {
+		// NaN and infinities parse, but are no water level
+		if math.IsNaN(level) || math.IsInf(level, 0) {
+			return 0, time.Time{}, fmt.Errorf("invalid level '%s', expected a finite number", text)
+		}
+		return level, now, nil
+	}
+
+	var p payload
+	if err := json.Unmarshal([]byte(text), &p); err != nil || p.LevelM == nil {
+		return 0, time.Time{}, fmt.Errorf("invalid payload '%s', expected a number or {\"level_m\": ...}", text)
+	}
+
+	if p.Time != nil {
+		return *p.LevelM, p.Time.UTC(), nil
+	}
+	return *p.LevelM, now, nil
+}
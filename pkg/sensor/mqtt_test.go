@@ -0,0 +1,215 @@
+package sensor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/config"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/floodrisk"
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/models"
+)
+
+// fakeStore keeps the homes and the saved readings in memory
+type fakeStore struct {
+	homes    []models.Home
+	readings []models.SensorReading
+}
+
+func (f *fakeStore) FindHome(name string) (models.Home, error) {
+	for _, home := range f.homes {
+		if strings.EqualFold(home.Name, name) {
+			return home, nil
+		}
+	}
+	return models.Home{}, errors.New("record not found")
+}
+
+func (f *fakeStore) SaveReading(reading *models.SensorReading) error {
+	f.readings = append(f.readings, *reading)
+	return nil
+}
+
+// testSubscriber subscribes a sensor 0.5m below the tide datum at a home with a 2.8m threshold,
+// and a sensor at a home without threshold falling back to the 2.5m of the rules
+func testSubscriber() (*Subscriber, *fakeStore) {
+	store := &fakeStore{homes: []models.Home{
+		{ID: "01HOMESEKUPANG000000000000", Name: "Sekupang", FloodThresholdM: 2.8},
+		{ID: "01HOMENONGSA00000000000000", Name: "Nongsa"},
+	}}
+
+	s := &Subscriber{
+		store: store,
+		rules: &floodrisk.RuleSet{Rules: []floodrisk.Rule{{Name: "high", TideHeightAbove: 2.7}, {Name: "low", TideHeightAbove: 2.5}}},
+		sensors: map[string]config.Sensor{
+			"sensors/sekupang": {Topic: "sensors/sekupang", Home: "sekupang", OffsetM: 0.5},
+			"sensors/nongsa":   {Topic: "sensors/nongsa", Home: "Nongsa"},
+		},
+		flooded: make(map[string]bool),
+	}
+	return s, store
+}
+
+func TestParsePayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		level   float64
+		readAt  time.Time // zero for now
+		wantErr bool
+	}{
+		{"bare number", "1.23", 1.23, time.Time{}, false},
+		{"bare number with whitespace", " 2.5\n", 2.5, time.Time{}, false},
+		{"negative", "-0.2", -0.2, time.Time{}, false},
+		{"json", `{"level_m": 1.5}`, 1.5, time.Time{}, false},
+		{"json with time", `{"level_m": 2.9, "time": "2025-12-04T06:00:00+07:00"}`, 2.9, time.Date(2025, 12, 3, 23, 0, 0, 0, time.UTC), false},
+		{"json without level", `{"time": "2025-12-04T06:00:00Z"}`, 0, time.Time{}, true},
+		{"text", "high", 0, time.Time{}, true},
+		{"empty", "", 0, time.Time{}, true},
+		{"NaN", "NaN", 0, time.Time{}, true},
+		{"infinity", "Inf", 0, time.Time{}, true},
+		{"positive infinity", "+Inf", 0, time.Time{}, true},
+		{"negative infinity", "-infinity", 0, time.Time{}, true},
+		{"json out of range", `{"level_m": 1e999}`, 0, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().UTC()
+			level, readAt, err := parsePayload([]byte(tt.message))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePayload(%q) error = %v, wantErr %v", tt.message, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if level != tt.level {
+				t.Errorf("parsePayload(%q) level = %.2f, want %.2f", tt.message, level, tt.level)
+			}
+			if readAt.Location() != time.UTC {
+				t.Errorf("parsePayload(%q) time in %s, want UTC", tt.message, readAt.Location())
+			}
+			if tt.readAt.IsZero() {
+				if readAt.Before(before) || readAt.After(time.Now().UTC()) {
+					t.Errorf("parsePayload(%q) time = %s, want now", tt.message, readAt)
+				}
+			} else if !readAt.Equal(tt.readAt) {
+				t.Errorf("parsePayload(%q) time = %s, want %s", tt.message, readAt, tt.readAt)
+			}
+		})
+	}
+}
+
+func TestIngest(t *testing.T) {
+	tests := []struct {
+		name          string
+		topic         string
+		message       string
+		wantHome      string
+		wantLevel     float64
+		wantThreshold float64
+		wantFlooded   bool
+		wantErr       bool
+	}{
+		{"offset applied below threshold", "sensors/sekupang", "2.2", "01HOMESEKUPANG000000000000", 2.7, 2.8, false, false},
+		{"offset applied above threshold", "sensors/sekupang", "2.4", "01HOMESEKUPANG000000000000", 2.9, 2.8, true, false},
+		{"at threshold is not flooded", "sensors/sekupang", "2.3", "01HOMESEKUPANG000000000000", 2.8, 2.8, false, false},
+		{"rules threshold fallback", "sensors/nongsa", "2.6", "01HOMENONGSA00000000000000", 2.6, 2.5, true, false},
+		{"unknown topic", "sensors/unknown", "2.6", "", 0, 0, false, true},
+		{"invalid payload", "sensors/sekupang", "high", "", 0, 0, false, true},
+		{"infinite level", "sensors/sekupang", "Inf", "", 0, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := testSubscriber()
+
+			reading, err := s.Ingest(tt.topic, []byte(tt.message))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ingest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(store.readings) != 0 {
+					t.Errorf("Ingest() stored %d readings, want none", len(store.readings))
+				}
+				return
+			}
+
+			if len(store.readings) != 1 {
+				t.Fatalf("Ingest() stored %d readings, want 1", len(store.readings))
+			}
+			if reading.Sensor != tt.topic || reading.HomeID != tt.wantHome {
+				t.Errorf("Ingest() sensor = %s, home = %s, want %s, %s", reading.Sensor, reading.HomeID, tt.topic, tt.wantHome)
+			}
+			if diff := reading.LevelM - tt.wantLevel; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Ingest() level = %.2f, want %.2f", reading.LevelM, tt.wantLevel)
+			}
+			if reading.ThresholdM != tt.wantThreshold {
+				t.Errorf("Ingest() threshold = %.2f, want %.2f", reading.ThresholdM, tt.wantThreshold)
+			}
+			if reading.Flooded != tt.wantFlooded {
+				t.Errorf("Ingest() flooded = %v, want %v", reading.Flooded, tt.wantFlooded)
+			}
+		})
+	}
+}
+
+func TestIngestUnknownHome(t *testing.T) {
+	s, store := testSubscriber()
+	s.sensors["sensors/batu-ampar"] = config.Sensor{Topic: "sensors/batu-ampar", Home: "Batu Ampar"}
+
+	if _, err := s.Ingest("sensors/batu-ampar", []byte("1.0")); err == nil {
+		t.Fatal("Ingest() error = nil, want an error for a sensor of an unknown home")
+	}
+	if len(store.readings) != 0 {
+		t.Errorf("Ingest() stored %d readings, want none", len(store.readings))
+	}
+}
+
+func TestIngestHooks(t *testing.T) {
+	s, _ := testSubscriber()
+
+	var readings, changes []models.SensorReading
+	s.OnReading(func(reading models.SensorReading) { readings = append(readings, reading) })
+	s.OnFloodedChange(func(reading models.SensorReading) { changes = append(changes, reading) })
+
+	// Dry, flooding starts, stays flooded, ends, another sensor floods
+	messages := []struct {
+		topic   string
+		message string
+	}{
+		{"sensors/sekupang", "2.0"},
+		{"sensors/sekupang", "2.5"},
+		{"sensors/sekupang", "2.6"},
+		{"sensors/sekupang", "2.1"},
+		{"sensors/nongsa", "2.6"},
+	}
+	for _, m := range messages {
+		if _, err := s.Ingest(m.topic, []byte(m.message)); err != nil {
+			t.Fatalf("Ingest(%s, %s) error = %v", m.topic, m.message, err)
+		}
+	}
+
+	if len(readings) != len(messages) {
+		t.Errorf("reading hook ran %d times, want %d", len(readings), len(messages))
+	}
+
+	want := []struct {
+		topic   string
+		flooded bool
+	}{
+		{"sensors/sekupang", true},
+		{"sensors/sekupang", false},
+		{"sensors/nongsa", true},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("flooded hook ran %d times, want %d", len(changes), len(want))
+	}
+	for i, w := range want {
+		if changes[i].Sensor != w.topic || changes[i].Flooded != w.flooded {
+			t.Errorf("change %d = %s flooded %v, want %s flooded %v", i, changes[i].Sensor, changes[i].Flooded, w.topic, w.flooded)
+		}
+	}
+}
@@ -1,19 +1,21 @@
 package controllertraits
 
 import (
-	"fmt"
-	"html"
+	"embed"
+	"html/template"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
 	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
 )
 
 // TidalFloodRisk holds tidal flood risk data for card rendering
 type TidalFloodRisk struct {
 	HasRisk     bool
-	RiskLevel   string // "none", "moderate", "high"
+	RiskLevel   string // "none", "low", "moderate", "high", "extreme"
+	RiskScore   int    // 0-100
 	TideTime    time.Time
 	TideHeightM float64
 	Message     string
@@ -29,34 +31,44 @@ type AlertCardData struct {
 	Timezone        string
 	FloodRisk       *TidalFloodRisk
 	Location        string
+	// Lang is the language of the card labels, see the i18n package
+	Lang string
 }
 
-// GetEventIcon returns an appropriate icon/emoji for the weather event
+// GetEventIcon returns an appropriate icon/emoji for the weather event, in English or Indonesian
 func GetEventIcon(event string) string {
 	eventLower := strings.ToLower(event)
+	contains := func(keywords ...string) bool {
+		for _, keyword := range keywords {
+			if strings.Contains(eventLower, keyword) {
+				return true
+			}
+		}
+		return false
+	}
 
 	switch {
-	case strings.Contains(eventLower, "thunderstorm"):
+	case contains("thunderstorm") || (contains("hujan") && contains("petir")):
 		return "⛈️"
-	case strings.Contains(eventLower, "thunder") || strings.Contains(eventLower, "lightning"):
+	case contains("thunder", "lightning", "petir", "kilat"):
 		return "⚡"
-	case strings.Contains(eventLower, "rain") || strings.Contains(eventLower, "shower"):
+	case contains("rain", "shower", "hujan"):
 		return "🌧️"
-	case strings.Contains(eventLower, "wind") || strings.Contains(eventLower, "gale"):
+	case contains("wind", "gale", "angin"):
 		return "💨"
-	case strings.Contains(eventLower, "wave") || strings.Contains(eventLower, "surge"):
+	case contains("wave", "surge", "gelombang"):
 		return "🌊"
-	case strings.Contains(eventLower, "flood"):
+	case contains("flood", "banjir"):
 		return "🌊"
-	case strings.Contains(eventLower, "heat") || strings.Contains(eventLower, "hot"):
+	case contains("heat", "hot", "panas"):
 		return "🔥"
-	case strings.Contains(eventLower, "fog") || strings.Contains(eventLower, "haze") || strings.Contains(eventLower, "smoke"):
+	case contains("fog", "haze", "smoke", "kabut", "asap"):
 		return "🌫️"
-	case strings.Contains(eventLower, "storm") || strings.Contains(eventLower, "extreme") || strings.Contains(eventLower, "severe"):
+	case contains("storm", "extreme", "severe", "badai", "ekstrem"):
 		return "⛈️"
-	case strings.Contains(eventLower, "cyclone") || strings.Contains(eventLower, "typhoon") || strings.Contains(eventLower, "hurricane"):
+	case contains("cyclone", "typhoon", "hurricane", "siklon"):
 		return "🌀"
-	case strings.Contains(eventLower, "tornado"):
+	case contains("tornado", "puting beliung"):
 		return "🌪️"
 	default:
 		return "⚠️"
@@ -69,208 +81,197 @@ func formatCardTime(t time.Time, timezone string) string {
 	return formatted.Format("2006-01-02 15:04")
 }
 
-// renderFloodRiskBadge returns the HTML for the flood risk badge (light mode)
-func renderFloodRiskBadge(risk *TidalFloodRisk, timezone string) string {
-	if risk == nil || !risk.HasRisk {
-		return ""
-	}
+//go:embed templates/*.html
+var templateFiles embed.FS
 
-	var bgColor, borderColor, textColor, icon string
-	switch risk.RiskLevel {
-	case "high":
-		bgColor = "#fef2f2"
-		borderColor = "#fca5a5"
-		textColor = "#dc2626"
-		icon = "🌊"
-	case "moderate":
-		bgColor = "#fffbeb"
-		borderColor = "#fcd34d"
-		textColor = "#d97706"
-		icon = "⚠️"
-	default:
-		return ""
-	}
+// cardTemplates holds the card templates, the theme values are trusted CSS
+var cardTemplates = template.Must(template.New("cards").Funcs(template.FuncMap{
+	"css": func(value string) template.CSS { return template.CSS(value) },
+	"t":   i18n.T,
+}).ParseFS(templateFiles, "templates/*.html"))
+
+// riskLevelIcons are the badge icons per risk level
+var riskLevelIcons = map[string]string{
+	"extreme":  "🚨",
+	"high":     "🌊",
+	"moderate": "⚠️",
+	"low":      "ℹ️",
+}
+
+// alertCardView is the data of the alert_card template
+type alertCardView struct {
+	Theme       Theme
+	Lang        string
+	Icon        string
+	Event       string
+	Province    string
+	Description string
+	Effective   string
+	Expires     string
+	Badge       *floodRiskBadgeView
+}
+
+// floodRiskBadgeView is the data of the flood_risk_badge template
+type floodRiskBadgeView struct {
+	Theme       Theme
+	Lang        string
+	Colors      LevelColors
+	Icon        string
+	Level       string
+	Title       string
+	Message     string
+	TideTime    string
+	TideHeightM float64
+	Score       int
+}
+
+// noAlertCardView is the data of the no_alert_card template
+type noAlertCardView struct {
+	Theme    Theme
+	Lang     string
+	Subtitle string
+}
 
-	tideTimeStr := formatCardTime(risk.TideTime, timezone)
-
-	return fmt.Sprintf(`
-  <div style="margin-top:12px;padding:10px;background:%s;border:1px solid %s;border-radius:8px;">
-    <div style="display:flex;align-items:center;gap:6px;">
-      <span style="font-size:20px;">%s</span>
-      <span style="font-size:12px;font-weight:600;color:%s;text-transform:uppercase;">%s Risk - Tidal Flood</span>
-    </div>
-    <div style="font-size:11px;color:#64748b;margin-top:6px;">%s</div>
-    <div style="display:flex;gap:16px;margin-top:8px;">
-      <div>
-        <div style="font-size:9px;color:#94a3b8;text-transform:uppercase;">High Tide</div>
-        <div style="font-size:11px;font-weight:500;color:#334155;">%s</div>
-      </div>
-      <div>
-        <div style="font-size:9px;color:#94a3b8;text-transform:uppercase;">Height</div>
-        <div style="font-size:11px;font-weight:500;color:#334155;">%.1f m</div>
-      </div>
-    </div>
-  </div>`, bgColor, borderColor, icon, textColor, risk.RiskLevel, html.EscapeString(risk.Message), tideTimeStr, risk.TideHeightM)
+// titleLocation title-cases a location: "some area" -> "Some Area"
+func titleLocation(location string) string {
+	return strings.Title(strings.ToLower(location)) // deprecated but simple [web:107][web:114]
 }
 
-// renderFloodRiskBadgeDark returns the HTML for the flood risk badge (dark mode)
-func renderFloodRiskBadgeDark(risk *TidalFloodRisk, timezone string) string {
+// newFloodRiskBadgeView returns the badge of a flood risk, nil when there is no risk to show
+func newFloodRiskBadgeView(risk *TidalFloodRisk, theme Theme, timezone, lang string) *floodRiskBadgeView {
 	if risk == nil || !risk.HasRisk {
-		return ""
+		return nil
 	}
 
-	var bgColor, borderColor, textColor, icon string
-	switch risk.RiskLevel {
-	case "high":
-		bgColor = "#450a0a"
-		borderColor = "#991b1b"
-		textColor = "#fca5a5"
-		icon = "🌊"
-	case "moderate":
-		bgColor = "#451a03"
-		borderColor = "#92400e"
-		textColor = "#fcd34d"
-		icon = "⚠️"
-	default:
-		return ""
+	colors, ok := theme.Levels[risk.RiskLevel]
+	if !ok {
+		return nil
 	}
 
-	tideTimeStr := formatCardTime(risk.TideTime, timezone)
-
-	return fmt.Sprintf(`
-  <div style="margin-top:12px;padding:10px;background:%s;border:1px solid %s;border-radius:8px;">
-    <div style="display:flex;align-items:center;gap:6px;">
-      <span style="font-size:20px;">%s</span>
-      <span style="font-size:12px;font-weight:600;color:%s;text-transform:uppercase;">%s Risk - Tidal Flood</span>
-    </div>
-    <div style="font-size:11px;color:#94a3b8;margin-top:6px;">%s</div>
-    <div style="display:flex;gap:16px;margin-top:8px;">
-      <div>
-        <div style="font-size:9px;color:#64748b;text-transform:uppercase;">High Tide</div>
-        <div style="font-size:11px;font-weight:500;color:#e2e8f0;">%s</div>
-      </div>
-      <div>
-        <div style="font-size:9px;color:#64748b;text-transform:uppercase;">Height</div>
-        <div style="font-size:11px;font-weight:500;color:#e2e8f0;">%.1f m</div>
-      </div>
-    </div>
-  </div>`, bgColor, borderColor, icon, textColor, risk.RiskLevel, html.EscapeString(risk.Message), tideTimeStr, risk.TideHeightM)
+	return &floodRiskBadgeView{
+		Theme:       theme,
+		Lang:        lang,
+		Colors:      colors,
+		Icon:        riskLevelIcons[risk.RiskLevel],
+		Level:       risk.RiskLevel,
+		Title:       i18n.T(lang, "card.risk_title", levelName(risk.RiskLevel, lang)),
+		Message:     risk.Message,
+		TideTime:    formatCardTime(risk.TideTime, timezone),
+		TideHeightM: risk.TideHeightM,
+		Score:       risk.RiskScore,
+	}
 }
 
-// RenderHTMLCard renders a single alert as an HTML card
-func RenderHTMLCard(data AlertCardData) string {
-	icon := GetEventIcon(data.Event)
-	effective := formatCardTime(data.Effective, data.Timezone)
-	expires := formatCardTime(data.Expires, data.Timezone)
-	province := html.EscapeString(data.AreaDescription)
+// newAlertCardView returns the alert_card template data of an alert
+func newAlertCardView(data AlertCardData, theme Theme) alertCardView {
+	province := data.AreaDescription
 	if data.Location != "" {
-		// simple title-case: "some area" -> "Some Area"
-		titleLocation := strings.Title(strings.ToLower(data.Location)) // deprecated but simple [web:107][web:114]
-		province += " - " + html.EscapeString(titleLocation)
+		province += " - " + titleLocation(data.Location)
 	}
 
-	description := html.EscapeString(data.Description)
-	event := html.EscapeString(data.Event)
-	riskBadge := renderFloodRiskBadge(data.FloodRisk, data.Timezone)
-
-	return fmt.Sprintf(`<div style="width:400px;border:1px solid #e5e7eb;border-radius:12px;padding:16px;font-family:system-ui,-apple-system,sans-serif;background:linear-gradient(135deg,#f8fafc 0%%,#e2e8f0 100%%);box-shadow:0 4px 6px -1px rgba(0,0,0,0.1);">
-  <div style="display:flex;align-items:flex-start;gap:12px;">
-    <span style="font-size:48px;flex-shrink:0;">%s</span>
-    <div style="min-width:0;flex:1;">
-      <div style="font-size:18px;font-weight:600;color:#1e293b;">%s</div>
-      <div style="font-size:14px;color:#64748b;">%s</div>
-    </div>
-  </div>
-  <div style="font-size:11px;color:#64748b;margin-top:8px;line-height:1.5;">%s</div>%s
-  <div style="border-top:1px solid #cbd5e1;margin-top:12px;padding-top:8px;display:flex;justify-content:space-between;">
-    <div>
-      <div style="font-size:10px;color:#94a3b8;text-transform:uppercase;">Effective</div>
-      <div style="font-size:12px;font-weight:500;color:#334155;">%s</div>
-    </div>
-    <div>
-      <div style="font-size:10px;color:#94a3b8;text-transform:uppercase;">Expires</div>
-      <div style="font-size:12px;font-weight:500;color:#334155;">%s</div>
-    </div>
-  </div>
-</div>`, icon, event, province, description, riskBadge, effective, expires)
+	return alertCardView{
+		Theme:       theme,
+		Lang:        data.Lang,
+		Icon:        GetEventIcon(data.Event),
+		Event:       data.Event,
+		Province:    province,
+		Description: data.Description,
+		Effective:   formatCardTime(data.Effective, data.Timezone),
+		Expires:     formatCardTime(data.Expires, data.Timezone),
+		Badge:       newFloodRiskBadgeView(data.FloodRisk, theme, data.Timezone, data.Lang),
+	}
 }
 
-// RenderHTMLCardDark renders a single alert as an HTML card in dark mode
-func RenderHTMLCardDark(data AlertCardData) string {
-	icon := GetEventIcon(data.Event)
-	effective := formatCardTime(data.Effective, data.Timezone)
-	expires := formatCardTime(data.Expires, data.Timezone)
-	province := html.EscapeString(data.AreaDescription)
-	if data.Location != "" {
-		// simple title-case: "some area" -> "Some Area"
-		titleLocation := strings.Title(strings.ToLower(data.Location)) // deprecated but simple [web:107][web:114]
-		province += " - " + html.EscapeString(titleLocation)
+// RenderCard renders a single alert as an HTML card with the given theme
+func RenderCard(data AlertCardData, theme Theme) (string, error) {
+	var b strings.Builder
+	if err := cardTemplates.ExecuteTemplate(&b, "alert_card", newAlertCardView(data, theme)); err != nil {
+		return "", err
 	}
+	return b.String(), nil
+}
+
+// AlertListData holds the alerts of a stacked card list, most important first
+type AlertListData struct {
+	Cards []AlertCardData
+	// Total is the number of active alerts, more than the cards when the list is cut
+	Total    int
+	Location string
+	Lang     string
+}
 
-	description := html.EscapeString(data.Description)
-	event := html.EscapeString(data.Event)
-	riskBadge := renderFloodRiskBadgeDark(data.FloodRisk, data.Timezone)
-
-	return fmt.Sprintf(`<div style="width:400px;border:1px solid #374151;border-radius:12px;padding:16px;font-family:system-ui,-apple-system,sans-serif;background:linear-gradient(135deg,#1e293b 0%%,#0f172a 100%%);box-shadow:0 4px 6px -1px rgba(0,0,0,0.3);">
-  <div style="display:flex;align-items:flex-start;gap:12px;">
-    <span style="font-size:48px;flex-shrink:0;">%s</span>
-    <div style="min-width:0;flex:1;">
-      <div style="font-size:18px;font-weight:600;color:#f1f5f9;">%s</div>
-      <div style="font-size:14px;color:#94a3b8;">%s</div>
-    </div>
-  </div>
-  <div style="font-size:11px;color:#94a3b8;margin-top:8px;line-height:1.5;">%s</div>%s
-  <div style="border-top:1px solid #475569;margin-top:12px;padding-top:8px;display:flex;justify-content:space-between;">
-    <div>
-      <div style="font-size:10px;color:#64748b;text-transform:uppercase;">Effective</div>
-      <div style="font-size:12px;font-weight:500;color:#e2e8f0;">%s</div>
-    </div>
-    <div>
-      <div style="font-size:10px;color:#64748b;text-transform:uppercase;">Expires</div>
-      <div style="font-size:12px;font-weight:500;color:#e2e8f0;">%s</div>
-    </div>
-  </div>
-</div>`, icon, event, province, description, riskBadge, effective, expires)
+// alertListView is the data of the alert_list template
+type alertListView struct {
+	Theme   Theme
+	Lang    string
+	Title   string
+	Summary string
+	Cards   []alertCardView
 }
 
-// RenderNoAlertCard renders a card indicating no active alerts (light mode)
-func RenderNoAlertCard(location string) string {
-	locationText := ""
-	if location != "" {
-		titleLocation := strings.Title(strings.ToLower(location))
-		locationText = " for " + html.EscapeString(titleLocation)
+// listSummary returns the title and summary line of the list header.
+// The cards are sorted by flood risk, so the first one carries the highest level.
+func listSummary(list AlertListData) (string, string) {
+	title := i18n.T(list.Lang, "list.active_alerts", list.Total)
+	if list.Total == 1 {
+		title = i18n.T(list.Lang, "list.active_alert")
+	}
+	if list.Location != "" {
+		title = i18n.T(list.Lang, "list.title_for", title, titleLocation(list.Location))
+	}
+
+	summary := i18n.T(list.Lang, "list.no_risk")
+	if risk := list.Cards[0].FloodRisk; risk != nil && risk.HasRisk {
+		summary = i18n.T(list.Lang, "list.highest_risk", strings.ToUpper(levelName(risk.RiskLevel, list.Lang)))
+	}
+	if len(list.Cards) < list.Total {
+		summary = i18n.T(list.Lang, "list.showing", summary, len(list.Cards), list.Total)
 	}
 
-	return fmt.Sprintf(`<div style="width:400px;border:1px solid #e5e7eb;border-radius:12px;padding:16px;font-family:system-ui,-apple-system,sans-serif;background:linear-gradient(135deg,#f0fdf4 0%%,#dcfce7 100%%);box-shadow:0 4px 6px -1px rgba(0,0,0,0.1);">
-  <div style="display:flex;align-items:flex-start;gap:12px;">
-    <span style="font-size:48px;flex-shrink:0;">✅</span>
-    <div style="min-width:0;flex:1;">
-      <div style="font-size:18px;font-weight:600;color:#166534;">All Clear</div>
-      <div style="font-size:14px;color:#15803d;">No active weather alerts%s</div>
-    </div>
-  </div>
-  <div style="font-size:11px;color:#64748b;margin-top:8px;line-height:1.5;">There are currently no weather warnings or alerts in effect. Stay safe and enjoy your day!</div>
-</div>`, locationText)
+	return title, summary
 }
 
-// RenderNoAlertCardDark renders a card indicating no active alerts (dark mode)
-func RenderNoAlertCardDark(location string) string {
-	locationText := ""
-	if location != "" {
-		titleLocation := strings.Title(strings.ToLower(location))
-		locationText = " for " + html.EscapeString(titleLocation)
+// RenderCardList renders several alerts as stacked HTML cards under a summary header
+func RenderCardList(list AlertListData, theme Theme) (string, error) {
+	if len(list.Cards) == 0 {
+		return RenderNoAlert(list.Location, theme, list.Lang)
+	}
+
+	view := alertListView{Theme: theme, Lang: list.Lang, Cards: make([]alertCardView, len(list.Cards))}
+	view.Title, view.Summary = listSummary(list)
+	for i, card := range list.Cards {
+		view.Cards[i] = newAlertCardView(card, theme)
 	}
 
-	return fmt.Sprintf(`<div style="width:400px;border:1px solid #374151;border-radius:12px;padding:16px;font-family:system-ui,-apple-system,sans-serif;background:linear-gradient(135deg,#14532d 0%%,#052e16 100%%);box-shadow:0 4px 6px -1px rgba(0,0,0,0.3);">
-  <div style="display:flex;align-items:flex-start;gap:12px;">
-    <span style="font-size:48px;flex-shrink:0;">✅</span>
-    <div style="min-width:0;flex:1;">
-      <div style="font-size:18px;font-weight:600;color:#86efac;">All Clear</div>
-      <div style="font-size:14px;color:#4ade80;">No active weather alerts%s</div>
-    </div>
-  </div>
-  <div style="font-size:11px;color:#94a3b8;margin-top:8px;line-height:1.5;">There are currently no weather warnings or alerts in effect. Stay safe and enjoy your day!</div>
-</div>`, locationText)
+	var b strings.Builder
+	if err := cardTemplates.ExecuteTemplate(&b, "alert_list", view); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// noAlertSubtitle returns the subtitle of the "all clear" card
+func noAlertSubtitle(location, lang string) string {
+	if location == "" {
+		return i18n.T(lang, "card.no_alerts")
+	}
+	return i18n.T(lang, "card.no_alerts_for", titleLocation(location))
+}
+
+// levelName returns the translated name of a risk level
+func levelName(level, lang string) string {
+	return i18n.T(lang, "level."+level)
+}
+
+// RenderNoAlert renders a card indicating no active alerts with the given theme and language
+func RenderNoAlert(location string, theme Theme, lang string) (string, error) {
+	view := noAlertCardView{Theme: theme, Lang: lang, Subtitle: noAlertSubtitle(location, lang)}
+
+	var b strings.Builder
+	if err := cardTemplates.ExecuteTemplate(&b, "no_alert_card", view); err != nil {
+		return "", err
+	}
+	return b.String(), nil
 }
 
 // WriteHTMLResponse writes an HTML response
@@ -278,3 +279,15 @@ func WriteHTMLResponse(w http.ResponseWriter, content string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_, _ = w.Write([]byte(content))
 }
+
+// WriteSVGResponse writes an SVG image response
+func WriteSVGResponse(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	_, _ = w.Write([]byte(content))
+}
+
+// WritePNGResponse writes a PNG image response
+func WritePNGResponse(w http.ResponseWriter, content []byte) {
+	w.Header().Set("Content-Type", "image/png")
+	_, _ = w.Write(content)
+}
@@ -0,0 +1,361 @@
+package controllertraits
+
+import (
+	"image/color"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
+)
+
+// Image card sizes, in pixels
+const (
+	DefaultImageWidth = 400
+	MinImageSize      = 200
+	MaxImageSize      = 2000
+)
+
+// Glyph metrics of the bitmap font, text of scale s is s times larger
+const (
+	glyphWidth  = 7
+	glyphHeight = 13
+	glyphAscent = 11
+	lineSpacing = 3
+	cardPadding = 16
+	iconSize    = 48
+)
+
+// ImageOptions controls the size and colours of image cards
+type ImageOptions struct {
+	Width int
+	// Height of the card, 0 fits the content. Content that does not fit is cut.
+	Height int
+	// Monochrome renders black on white only, for e-ink panels
+	Monochrome bool
+}
+
+// drawOpKind is the kind of a drawing operation
+type drawOpKind int
+
+const (
+	opRect drawOpKind = iota
+	opText
+	opIcon
+)
+
+// drawOp is a single drawing operation of an image card, shared by the SVG and PNG renderers
+type drawOp struct {
+	kind drawOpKind
+	x, y int
+	// Rectangle size, stroke is ignored when transparent
+	w, h   int
+	fill   color.RGBA
+	stroke color.RGBA
+	radius int
+	// Text, y is the top of the line
+	text  string
+	scale int
+	bold  bool
+	// Icon: emoji for SVG, symbol for the bitmap font
+	emoji  string
+	symbol string
+}
+
+// imageCard is the laid out card
+type imageCard struct {
+	width  int
+	height int
+	ops    []drawOp
+}
+
+// imagePalette holds the parsed colours of a theme
+type imagePalette struct {
+	background color.RGBA
+	border     color.RGBA
+	title      color.RGBA
+	subtitle   color.RGBA
+	text       color.RGBA
+	divider    color.RGBA
+	label      color.RGBA
+	value      color.RGBA
+
+	clearBackground color.RGBA
+	clearTitle      color.RGBA
+	clearSubtitle   color.RGBA
+
+	badgeText  color.RGBA
+	badgeLabel color.RGBA
+	badgeValue color.RGBA
+	levels     map[string][3]color.RGBA
+}
+
+var (
+	black = color.RGBA{A: 255}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// hexColorRegex finds the first hex colour of a CSS value, gradients use their first stop
+var hexColorRegex = regexp.MustCompile(`#([0-9a-fA-F]{6}|[0-9a-fA-F]{3})\b`)
+
+// parseCSSColor returns the first hex colour of a CSS value, or the fallback
+func parseCSSColor(value string, fallback color.RGBA) color.RGBA {
+	match := hexColorRegex.FindStringSubmatch(value)
+	if match == nil {
+		return fallback
+	}
+
+	hex := match[1]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}
+}
+
+// newImagePalette parses the theme colours, monochrome maps everything to black on white
+func newImagePalette(theme Theme, monochrome bool) imagePalette {
+	if monochrome {
+		palette := imagePalette{
+			background: white, border: black, title: black, subtitle: black, text: black,
+			divider: black, label: black, value: black,
+			clearBackground: white, clearTitle: black, clearSubtitle: black,
+			badgeText: black, badgeLabel: black, badgeValue: black,
+			levels: make(map[string][3]color.RGBA),
+		}
+		for level := range theme.Levels {
+			palette.levels[level] = [3]color.RGBA{white, black, black}
+		}
+		return palette
+	}
+
+	palette := imagePalette{
+		background:      parseCSSColor(theme.Background, white),
+		border:          parseCSSColor(theme.Border, black),
+		title:           parseCSSColor(theme.Title, black),
+		subtitle:        parseCSSColor(theme.Subtitle, black),
+		text:            parseCSSColor(theme.Text, black),
+		divider:         parseCSSColor(theme.Divider, black),
+		label:           parseCSSColor(theme.Label, black),
+		value:           parseCSSColor(theme.Value, black),
+		clearBackground: parseCSSColor(theme.ClearBackground, white),
+		clearTitle:      parseCSSColor(theme.ClearTitle, black),
+		clearSubtitle:   parseCSSColor(theme.ClearSubtitle, black),
+		badgeText:       parseCSSColor(theme.BadgeText, black),
+		badgeLabel:      parseCSSColor(theme.BadgeLabel, black),
+		badgeValue:      parseCSSColor(theme.BadgeValue, black),
+		levels:          make(map[string][3]color.RGBA),
+	}
+	for level, colors := range theme.Levels {
+		palette.levels[level] = [3]color.RGBA{
+			parseCSSColor(colors.Background, white),
+			parseCSSColor(colors.Border, black),
+			parseCSSColor(colors.Text, black),
+		}
+	}
+	return palette
+}
+
+// GetEventSymbol returns a short ASCII symbol of the weather event, for displays without emoji
+func GetEventSymbol(event string) string {
+	symbols := map[string]string{
+		"⛈️": "STORM", "⚡": "LIGHT", "🌧️": "RAIN", "💨": "WIND", "🌊": "WAVE",
+		"🔥": "HEAT", "🌫️": "FOG", "🌀": "CYCL", "🌪️": "TORN",
+	}
+	if symbol, ok := symbols[GetEventIcon(event)]; ok {
+		return symbol
+	}
+	return "!"
+}
+
+// cardLayout places the card content from top to bottom
+type cardLayout struct {
+	card imageCard
+	y    int
+}
+
+// textLine adds a single line of text, cut to the available width
+func (l *cardLayout) textLine(x int, text string, c color.RGBA, scale int, bold bool, maxWidth int) {
+	runes := []rune(text)
+	if maxChars := maxWidth / (glyphWidth * scale); len(runes) > maxChars && maxChars > 3 {
+		runes = append(runes[:maxChars-3], []rune("...")...)
+	}
+
+	l.card.ops = append(l.card.ops, drawOp{kind: opText, x: x, y: l.y, fill: c, text: string(runes), scale: scale, bold: bold})
+	l.y += glyphHeight*scale + lineSpacing
+}
+
+// paragraph adds word-wrapped text
+func (l *cardLayout) paragraph(x int, text string, c color.RGBA, scale int, maxWidth int) {
+	for _, line := range wrapText(text, maxWidth/(glyphWidth*scale)) {
+		l.textLine(x, line, c, scale, false, maxWidth)
+	}
+}
+
+// wrapText splits text into lines of at most width characters, breaking on spaces when possible
+func wrapText(text string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+
+	lines := make([]string, 0)
+	line := make([]rune, 0, width)
+	for _, word := range strings.Fields(text) {
+		wordRunes := []rune(word)
+		for len(wordRunes) > width {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = line[:0]
+			}
+			lines = append(lines, string(wordRunes[:width]))
+			wordRunes = wordRunes[width:]
+		}
+
+		if len(line) > 0 && len(line)+1+len(wordRunes) > width {
+			lines = append(lines, string(line))
+			line = line[:0]
+		}
+		if len(line) > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, wordRunes...)
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
+
+// finish sizes the card to the options and adds the background behind the content
+func (l *cardLayout) finish(options ImageOptions, background, border color.RGBA) imageCard {
+	l.card.height = l.y + cardPadding
+	if options.Height > 0 {
+		l.card.height = options.Height
+	}
+
+	backgroundOp := drawOp{kind: opRect, w: l.card.width, h: l.card.height, fill: background, stroke: border, radius: 12}
+	l.card.ops = append([]drawOp{backgroundOp}, l.card.ops...)
+	return l.card
+}
+
+// header adds the icon, title and subtitle
+func (l *cardLayout) header(emoji, symbol, title, subtitle string, titleColor, subtitleColor, iconColor color.RGBA) {
+	l.card.ops = append(l.card.ops, drawOp{
+		kind: opIcon, x: cardPadding, y: cardPadding, w: iconSize, h: iconSize,
+		fill: iconColor, emoji: emoji, symbol: symbol,
+	})
+
+	textX := cardPadding + iconSize + 12
+	textWidth := l.card.width - textX - cardPadding
+	l.y = cardPadding
+	for _, line := range wrapText(title, textWidth/(glyphWidth*2)) {
+		l.textLine(textX, line, titleColor, 2, true, textWidth)
+	}
+	l.paragraph(textX, subtitle, subtitleColor, 1, textWidth)
+
+	if l.y < cardPadding+iconSize {
+		l.y = cardPadding + iconSize
+	}
+	l.y += 8
+}
+
+// layoutAlertCard lays out the same content as the HTML alert card
+func layoutAlertCard(data AlertCardData, theme Theme, options ImageOptions) imageCard {
+	palette := newImagePalette(theme, options.Monochrome)
+	l := &cardLayout{card: imageCard{width: options.Width}}
+	contentWidth := options.Width - 2*cardPadding
+
+	province := data.AreaDescription
+	if data.Location != "" {
+		province += " - " + titleLocation(data.Location)
+	}
+
+	l.header(GetEventIcon(data.Event), GetEventSymbol(data.Event), data.Event, province, palette.title, palette.subtitle, palette.title)
+	l.paragraph(cardPadding, data.Description, palette.text, 1, contentWidth)
+
+	if badge := newFloodRiskBadgeView(data.FloodRisk, theme, data.Timezone, data.Lang); badge != nil {
+		colors := palette.levels[badge.Level]
+		l.y += 8
+		top := l.y
+		badgeIndex := len(l.card.ops)
+
+		l.y += 10
+		innerX := cardPadding + 10
+		innerWidth := contentWidth - 20
+		l.textLine(innerX, strings.ToUpper(badge.Title), colors[2], 1, true, innerWidth)
+		l.paragraph(innerX, badge.Message, palette.badgeText, 1, innerWidth)
+		l.paragraph(innerX, i18n.T(data.Lang, "card.tide_summary", badge.TideTime, badge.TideHeightM, badge.Score),
+			palette.badgeValue, 1, innerWidth)
+		l.y += 7
+
+		// The badge box goes behind its text
+		box := drawOp{kind: opRect, x: cardPadding, y: top, w: contentWidth, h: l.y - top, fill: colors[0], stroke: colors[1], radius: 8}
+		l.card.ops = append(l.card.ops[:badgeIndex], append([]drawOp{box}, l.card.ops[badgeIndex:]...)...)
+	}
+
+	l.y += 12
+	l.card.ops = append(l.card.ops, drawOp{kind: opRect, x: cardPadding, y: l.y, w: contentWidth, h: 1, fill: palette.divider})
+	l.y += 9
+
+	half := contentWidth / 2
+	top := l.y
+	l.textLine(cardPadding, strings.ToUpper(i18n.T(data.Lang, "card.effective")), palette.label, 1, false, half)
+	l.textLine(cardPadding, formatCardTime(data.Effective, data.Timezone), palette.value, 1, true, half)
+	l.y = top
+	l.textLine(cardPadding+half, strings.ToUpper(i18n.T(data.Lang, "card.expires")), palette.label, 1, false, half)
+	l.textLine(cardPadding+half, formatCardTime(data.Expires, data.Timezone), palette.value, 1, true, half)
+
+	return l.finish(options, palette.background, palette.border)
+}
+
+// layoutNoAlertCard lays out the same content as the HTML "all clear" card
+func layoutNoAlertCard(location string, theme Theme, lang string, options ImageOptions) imageCard {
+	palette := newImagePalette(theme, options.Monochrome)
+	l := &cardLayout{card: imageCard{width: options.Width}}
+
+	l.header("✅", "OK", i18n.T(lang, "card.all_clear"), noAlertSubtitle(location, lang),
+		palette.clearTitle, palette.clearSubtitle, palette.clearTitle)
+	l.paragraph(cardPadding, i18n.T(lang, "card.all_clear_text"), palette.text, 1, options.Width-2*cardPadding)
+
+	return l.finish(options, palette.clearBackground, palette.border)
+}
+
+// cardListGap is the space between the stacked cards, in pixels
+const cardListGap = 8
+
+// layoutCardList stacks the alert cards under a compact summary header
+func layoutCardList(list AlertListData, theme Theme, options ImageOptions) imageCard {
+	if len(list.Cards) == 0 {
+		return layoutNoAlertCard(list.Location, theme, list.Lang, options)
+	}
+
+	palette := newImagePalette(theme, options.Monochrome)
+	title, summary := listSummary(list)
+
+	header := &cardLayout{card: imageCard{width: options.Width}, y: 8}
+	header.textLine(cardPadding, title, palette.title, 1, true, options.Width-2*cardPadding)
+	header.textLine(cardPadding, summary, palette.subtitle, 1, false, options.Width-2*cardPadding)
+	header.y += 5 - cardPadding
+	stacked := header.finish(ImageOptions{Width: options.Width}, palette.background, palette.border)
+
+	cardOptions := ImageOptions{Width: options.Width, Monochrome: options.Monochrome}
+	for _, data := range list.Cards {
+		card := layoutAlertCard(data, theme, cardOptions)
+		offset := stacked.height + cardListGap
+		for _, op := range card.ops {
+			op.y += offset
+			stacked.ops = append(stacked.ops, op)
+		}
+		stacked.height = offset + card.height
+	}
+
+	if options.Height > 0 {
+		stacked.height = options.Height
+	}
+	return stacked
+}
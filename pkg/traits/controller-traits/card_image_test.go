@@ -0,0 +1,83 @@
+package controllertraits
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRenderSVG(t *testing.T) {
+	card, list := testCards()
+	options := ImageOptions{Width: 320, Height: 240}
+
+	for name, theme := range DefaultThemes() {
+		renders := map[string]string{
+			"card":     RenderSVGCard(card, theme, options),
+			"list":     RenderSVGCardList(list, theme, options),
+			"no alert": RenderSVGNoAlert("sekupang", theme, "en", options),
+		}
+
+		for kind, svg := range renders {
+			t.Run(name+" "+kind, func(t *testing.T) {
+				if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="320" height="240"`) {
+					t.Errorf("SVG starts with %.80q, want a 320x240 root", svg)
+				}
+				if !strings.HasSuffix(strings.TrimSpace(svg), "</svg>") {
+					t.Error("SVG is not closed")
+				}
+				if strings.Contains(svg, "<Bintan>") {
+					t.Error("SVG did not escape the alert description")
+				}
+			})
+		}
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	card, list := testCards()
+	theme := DefaultThemes()[ThemeDark]
+
+	tests := []struct {
+		name    string
+		options ImageOptions
+		render  func(ImageOptions) ([]byte, error)
+	}{
+		{"card", ImageOptions{Width: 320, Height: 240}, func(o ImageOptions) ([]byte, error) { return RenderPNGCard(card, theme, o) }},
+		{"card monochrome", ImageOptions{Width: 296, Height: 128, Monochrome: true}, func(o ImageOptions) ([]byte, error) { return RenderPNGCard(card, theme, o) }},
+		{"list", ImageOptions{Width: 480, Height: 600}, func(o ImageOptions) ([]byte, error) { return RenderPNGCardList(list, theme, o) }},
+		{"no alert", ImageOptions{Width: 250, Height: 122, Monochrome: true}, func(o ImageOptions) ([]byte, error) {
+			return RenderPNGNoAlert("sekupang", theme, "id", o)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := tt.render(tt.options)
+			if err != nil {
+				t.Fatalf("render error = %v", err)
+			}
+
+			img, err := png.Decode(bytes.NewReader(content))
+			if err != nil {
+				t.Fatalf("png.Decode() error = %v", err)
+			}
+			if size := img.Bounds().Size(); size.X != tt.options.Width || size.Y != tt.options.Height {
+				t.Errorf("PNG is %dx%d, want %dx%d", size.X, size.Y, tt.options.Width, tt.options.Height)
+			}
+		})
+	}
+
+	// Without height the card fits its content
+	content, err := RenderPNGCard(card, theme, ImageOptions{Width: DefaultImageWidth})
+	if err != nil {
+		t.Fatalf("RenderPNGCard() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if size := img.Bounds().Size(); size.X != DefaultImageWidth || size.Y <= 0 {
+		t.Errorf("PNG is %dx%d, want %d wide", size.X, size.Y, DefaultImageWidth)
+	}
+}
@@ -0,0 +1,137 @@
+package controllertraits
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// pngSymbolScale is the text scale of the icon symbol, reduced for long symbols to fit the icon box
+func pngSymbolScale(symbol string, size int) int {
+	for scale := 3; scale > 1; scale-- {
+		if len(symbol)*glyphWidth*scale <= size-8 {
+			return scale
+		}
+	}
+	return 1
+}
+
+// latin1 replaces the characters the bitmap font cannot draw
+func latin1(text string) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if r > 0xff {
+			runes[i] = '?'
+		}
+	}
+	return string(runes)
+}
+
+// drawRect fills a rectangle and draws its one pixel border, transparent colours are skipped
+func drawRect(img draw.Image, op drawOp) {
+	bounds := image.Rect(op.x, op.y, op.x+op.w, op.y+op.h)
+	if op.fill.A > 0 {
+		draw.Draw(img, bounds, image.NewUniform(op.fill), image.Point{}, draw.Src)
+	}
+	if op.stroke.A == 0 {
+		return
+	}
+
+	stroke := image.NewUniform(op.stroke)
+	for _, edge := range []image.Rectangle{
+		image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+1),
+		image.Rect(bounds.Min.X, bounds.Max.Y-1, bounds.Max.X, bounds.Max.Y),
+		image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+1, bounds.Max.Y),
+		image.Rect(bounds.Max.X-1, bounds.Min.Y, bounds.Max.X, bounds.Max.Y),
+	} {
+		draw.Draw(img, edge, stroke, image.Point{}, draw.Src)
+	}
+}
+
+// drawText draws a line of the bitmap font, scaled up by whole pixels. Bold text is drawn twice, one pixel apart.
+func drawText(img draw.Image, x, y int, text string, c color.RGBA, scale int, bold bool) {
+	text = latin1(text)
+	mask := image.NewAlpha(image.Rect(0, 0, len([]rune(text))*glyphWidth, glyphHeight))
+	drawer := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(0, glyphAscent),
+	}
+	drawer.DrawString(text)
+
+	src := image.NewUniform(c)
+	passes := 1
+	if bold {
+		passes = 2
+	}
+
+	for pass := 0; pass < passes; pass++ {
+		for my := 0; my < mask.Rect.Dy(); my++ {
+			for mx := 0; mx < mask.Rect.Dx(); mx++ {
+				if mask.AlphaAt(mx, my).A < 128 {
+					continue
+				}
+				pixel := image.Rect(x+pass+mx*scale, y+my*scale, x+pass+(mx+1)*scale, y+(my+1)*scale)
+				draw.Draw(img, pixel, src, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+// renderPNG draws the card and encodes it, monochrome cards are encoded as two-colour images
+func renderPNG(card imageCard, monochrome bool) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, card.width, card.height))
+	if monochrome {
+		// Uncovered pixels, like the gaps of a card list, would turn black in the two-colour palette
+		draw.Draw(img, img.Bounds(), image.NewUniform(white), image.Point{}, draw.Src)
+	}
+
+	for _, op := range card.ops {
+		switch op.kind {
+		case opRect:
+			drawRect(img, op)
+		case opText:
+			drawText(img, op.x, op.y, op.text, op.fill, op.scale, op.bold)
+		case opIcon:
+			drawRect(img, drawOp{x: op.x, y: op.y, w: op.w, h: op.h, fill: color.RGBA{}, stroke: op.fill})
+			scale := pngSymbolScale(op.symbol, op.w)
+			textWidth := len(op.symbol) * glyphWidth * scale
+			drawText(img, op.x+(op.w-textWidth)/2, op.y+(op.h-glyphHeight*scale)/2, op.symbol, op.fill, scale, true)
+		}
+	}
+
+	var out image.Image = img
+	if monochrome {
+		paletted := image.NewPaletted(img.Bounds(), color.Palette{white, black})
+		draw.Draw(paletted, paletted.Bounds(), img, image.Point{}, draw.Src)
+		out = paletted
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, out); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// RenderPNGCard renders a single alert as a PNG card
+func RenderPNGCard(data AlertCardData, theme Theme, options ImageOptions) ([]byte, error) {
+	return renderPNG(layoutAlertCard(data, theme, options), options.Monochrome)
+}
+
+// RenderPNGNoAlert renders the "all clear" card as PNG
+func RenderPNGNoAlert(location string, theme Theme, lang string, options ImageOptions) ([]byte, error) {
+	return renderPNG(layoutNoAlertCard(location, theme, lang, options), options.Monochrome)
+}
+
+// RenderPNGCardList renders several alerts as stacked PNG cards under a summary header
+func RenderPNGCardList(list AlertListData, theme Theme, options ImageOptions) ([]byte, error) {
+	return renderPNG(layoutCardList(list, theme, options), options.Monochrome)
+}
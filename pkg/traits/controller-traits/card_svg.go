@@ -0,0 +1,65 @@
+package controllertraits
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"strings"
+)
+
+// svgFontSize is the monospace font size whose advance matches the bitmap font (0.6em per character)
+const svgFontSize = float64(glyphWidth) / 0.6
+
+// svgColor formats a colour for SVG attributes
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// renderSVG writes the drawing operations as an SVG document
+func renderSVG(card imageCard) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		card.width, card.height, card.width, card.height)
+	fmt.Fprintf(&b, `<clipPath id="card"><rect width="%d" height="%d"/></clipPath><g clip-path="url(#card)" font-family="monospace">`,
+		card.width, card.height)
+
+	for _, op := range card.ops {
+		switch op.kind {
+		case opRect:
+			stroke := ""
+			if op.stroke.A > 0 {
+				stroke = fmt.Sprintf(` stroke="%s"`, svgColor(op.stroke))
+			}
+			fmt.Fprintf(&b, `<rect x="%d.5" y="%d.5" width="%d" height="%d" rx="%d" fill="%s"%s/>`,
+				op.x, op.y, max(op.w-1, 1), max(op.h-1, 1), op.radius, svgColor(op.fill), stroke)
+		case opText:
+			weight := ""
+			if op.bold {
+				weight = ` font-weight="bold"`
+			}
+			fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="%.2f" fill="%s"%s xml:space="preserve">%s</text>`,
+				op.x, op.y+glyphAscent*op.scale, svgFontSize*float64(op.scale), svgColor(op.fill), weight, html.EscapeString(op.text))
+		case opIcon:
+			fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="%d" text-anchor="middle" dominant-baseline="central">%s</text>`,
+				op.x+op.w/2, op.y+op.h/2, op.h*3/4, html.EscapeString(op.emoji))
+		}
+	}
+
+	b.WriteString(`</g></svg>`)
+	return b.String()
+}
+
+// RenderSVGCard renders a single alert as an SVG card
+func RenderSVGCard(data AlertCardData, theme Theme, options ImageOptions) string {
+	return renderSVG(layoutAlertCard(data, theme, options))
+}
+
+// RenderSVGNoAlert renders the "all clear" card as SVG
+func RenderSVGNoAlert(location string, theme Theme, lang string, options ImageOptions) string {
+	return renderSVG(layoutNoAlertCard(location, theme, lang, options))
+}
+
+// RenderSVGCardList renders several alerts as stacked SVG cards under a summary header
+func RenderSVGCardList(list AlertListData, theme Theme, options ImageOptions) string {
+	return renderSVG(layoutCardList(list, theme, options))
+}
@@ -0,0 +1,95 @@
+package controllertraits
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCards returns an alert with a flood risk and the list it heads, with Indonesian labels
+func testCards() (AlertCardData, AlertListData) {
+	effective := time.Date(2025, 12, 4, 2, 0, 0, 0, time.UTC)
+	card := AlertCardData{
+		Event:           "Hujan Lebat",
+		Effective:       effective,
+		Expires:         effective.Add(6 * time.Hour),
+		AreaDescription: "Kepulauan Riau",
+		Description:     "Hujan lebat disertai kilat di Batam & Sekupang <Bintan>",
+		Timezone:        "Asia/Jakarta",
+		Location:        "sekupang",
+		Lang:            "id",
+		FloodRisk: &TidalFloodRisk{
+			HasRisk:     true,
+			RiskLevel:   "high",
+			RiskScore:   72,
+			TideTime:    effective.Add(4 * time.Hour),
+			TideHeightM: 2.9,
+			Message:     "High tide during heavy rain",
+		},
+	}
+
+	dry := card
+	dry.Event = "Angin Kencang"
+	dry.FloodRisk = &TidalFloodRisk{RiskLevel: "none"}
+
+	return card, AlertListData{Cards: []AlertCardData{card, dry}, Total: 3, Location: "sekupang", Lang: "id"}
+}
+
+func TestRenderHTMLThemes(t *testing.T) {
+	card, list := testCards()
+
+	for name, theme := range DefaultThemes() {
+		renders := []struct {
+			kind   string
+			colour string // a theme colour the output uses
+			render func() (string, error)
+		}{
+			{"card", theme.Title, func() (string, error) { return RenderCard(card, theme) }},
+			{"list", theme.Title, func() (string, error) { return RenderCardList(list, theme) }},
+			{"no alert", theme.ClearTitle, func() (string, error) { return RenderNoAlert("sekupang", theme, "en") }},
+		}
+
+		for _, r := range renders {
+			t.Run(name+" "+r.kind, func(t *testing.T) {
+				html, err := r.render()
+				if err != nil {
+					t.Fatalf("render error = %v", err)
+				}
+				if strings.Contains(html, "ZgotmplZ") {
+					t.Errorf("render output contains ZgotmplZ, a theme value was rejected by the template")
+				}
+				if !strings.Contains(html, r.colour) {
+					t.Errorf("render output misses the %s theme colour %s", name, r.colour)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderCardEscapesText(t *testing.T) {
+	card, _ := testCards()
+
+	html, err := RenderCard(card, DefaultThemes()[ThemeLight])
+	if err != nil {
+		t.Fatalf("RenderCard() error = %v", err)
+	}
+	if strings.Contains(html, "<Bintan>") {
+		t.Error("RenderCard() did not escape the alert description")
+	}
+}
+
+func TestRenderCardListEmpty(t *testing.T) {
+	theme := DefaultThemes()[ThemeLight]
+
+	list, err := RenderCardList(AlertListData{Location: "sekupang", Lang: "en"}, theme)
+	if err != nil {
+		t.Fatalf("RenderCardList() error = %v", err)
+	}
+	noAlert, err := RenderNoAlert("sekupang", theme, "en")
+	if err != nil {
+		t.Fatalf("RenderNoAlert() error = %v", err)
+	}
+	if list != noAlert {
+		t.Error("RenderCardList() without cards differs from the no alert card")
+	}
+}
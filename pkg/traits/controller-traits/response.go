@@ -0,0 +1,15 @@
+package controllertraits
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// WriteJSONResponse writes a non-paginated JSON response wrapped in a "data" envelope
+func WriteJSONResponse(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": data,
+	})
+}
@@ -0,0 +1,228 @@
+package controllertraits
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Built-in theme names
+const (
+	ThemeLight        = "light"
+	ThemeDark         = "dark"
+	ThemeHighContrast = "high-contrast"
+)
+
+// LevelColors are the flood risk badge colours of a risk level
+type LevelColors struct {
+	Background string `json:"background"`
+	Border     string `json:"border"`
+	Text       string `json:"text"`
+}
+
+// Theme holds the CSS colours of the cards, every value is used as-is in style attributes
+type Theme struct {
+	Name string `json:"name"`
+
+	// Alert card
+	Background string `json:"background"`
+	Border     string `json:"border"`
+	Shadow     string `json:"shadow"`
+	Title      string `json:"title"`
+	Subtitle   string `json:"subtitle"`
+	Text       string `json:"text"`
+	Divider    string `json:"divider"`
+	Label      string `json:"label"`
+	Value      string `json:"value"`
+
+	// All clear card
+	ClearBackground string `json:"clear_background"`
+	ClearTitle      string `json:"clear_title"`
+	ClearSubtitle   string `json:"clear_subtitle"`
+
+	// Flood risk badge, Levels is keyed by risk level (low, moderate, high, extreme)
+	BadgeText  string                 `json:"badge_text"`
+	BadgeLabel string                 `json:"badge_label"`
+	BadgeValue string                 `json:"badge_value"`
+	Levels     map[string]LevelColors `json:"levels"`
+}
+
+// ThemeSet holds the themes available to the cards by name
+type ThemeSet map[string]Theme
+
+// DefaultThemes returns the built-in light, dark and high-contrast themes
+func DefaultThemes() ThemeSet {
+	return ThemeSet{
+		ThemeLight: {
+			Name:            ThemeLight,
+			Background:      "linear-gradient(135deg,#f8fafc 0%,#e2e8f0 100%)",
+			Border:          "#e5e7eb",
+			Shadow:          "0 4px 6px -1px rgba(0,0,0,0.1)",
+			Title:           "#1e293b",
+			Subtitle:        "#64748b",
+			Text:            "#64748b",
+			Divider:         "#cbd5e1",
+			Label:           "#94a3b8",
+			Value:           "#334155",
+			ClearBackground: "linear-gradient(135deg,#f0fdf4 0%,#dcfce7 100%)",
+			ClearTitle:      "#166534",
+			ClearSubtitle:   "#15803d",
+			BadgeText:       "#64748b",
+			BadgeLabel:      "#94a3b8",
+			BadgeValue:      "#334155",
+			Levels: map[string]LevelColors{
+				"extreme":  {Background: "#fdf2f8", Border: "#f0abfc", Text: "#a21caf"},
+				"high":     {Background: "#fef2f2", Border: "#fca5a5", Text: "#dc2626"},
+				"moderate": {Background: "#fffbeb", Border: "#fcd34d", Text: "#d97706"},
+				"low":      {Background: "#eff6ff", Border: "#93c5fd", Text: "#2563eb"},
+			},
+		},
+		ThemeDark: {
+			Name:            ThemeDark,
+			Background:      "linear-gradient(135deg,#1e293b 0%,#0f172a 100%)",
+			Border:          "#374151",
+			Shadow:          "0 4px 6px -1px rgba(0,0,0,0.3)",
+			Title:           "#f1f5f9",
+			Subtitle:        "#94a3b8",
+			Text:            "#94a3b8",
+			Divider:         "#475569",
+			Label:           "#64748b",
+			Value:           "#e2e8f0",
+			ClearBackground: "linear-gradient(135deg,#14532d 0%,#052e16 100%)",
+			ClearTitle:      "#86efac",
+			ClearSubtitle:   "#4ade80",
+			BadgeText:       "#94a3b8",
+			BadgeLabel:      "#64748b",
+			BadgeValue:      "#e2e8f0",
+			Levels: map[string]LevelColors{
+				"extreme":  {Background: "#4a044e", Border: "#a21caf", Text: "#f0abfc"},
+				"high":     {Background: "#450a0a", Border: "#991b1b", Text: "#fca5a5"},
+				"moderate": {Background: "#451a03", Border: "#92400e", Text: "#fcd34d"},
+				"low":      {Background: "#172554", Border: "#1e40af", Text: "#93c5fd"},
+			},
+		},
+		ThemeHighContrast: {
+			Name:            ThemeHighContrast,
+			Background:      "#000000",
+			Border:          "#ffffff",
+			Shadow:          "none",
+			Title:           "#ffffff",
+			Subtitle:        "#ffff00",
+			Text:            "#ffffff",
+			Divider:         "#ffffff",
+			Label:           "#ffff00",
+			Value:           "#ffffff",
+			ClearBackground: "#000000",
+			ClearTitle:      "#00ff00",
+			ClearSubtitle:   "#ffffff",
+			BadgeText:       "#ffffff",
+			BadgeLabel:      "#ffff00",
+			BadgeValue:      "#ffffff",
+			Levels: map[string]LevelColors{
+				"extreme":  {Background: "#000000", Border: "#ff00ff", Text: "#ff00ff"},
+				"high":     {Background: "#000000", Border: "#ff3333", Text: "#ff3333"},
+				"moderate": {Background: "#000000", Border: "#ffff00", Text: "#ffff00"},
+				"low":      {Background: "#000000", Border: "#00ffff", Text: "#00ffff"},
+			},
+		},
+	}
+}
+
+// LoadThemes returns the built-in themes plus the JSON themes of a directory (nothing more when dir is empty).
+// A theme file only needs the colours it changes: it starts from the theme named in "extends" (default light).
+// The theme name defaults to the file name without extension. Invalid files are skipped with a warning.
+func LoadThemes(dir string) (ThemeSet, error) {
+	themes := DefaultThemes()
+	if dir == "" {
+		return themes, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return themes, err
+	}
+
+	for _, path := range paths {
+		theme, err := loadTheme(path, themes)
+		if err != nil {
+			zap.S().Warnf("Skipping card theme %s: %v", path, err)
+			continue
+		}
+
+		themes[theme.Name] = theme
+		zap.S().Infof("Loaded card theme %s", theme.Name)
+	}
+
+	return themes, nil
+}
+
+// loadTheme reads a single theme file on top of the theme it extends
+func loadTheme(path string, themes ThemeSet) (Theme, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, err
+	}
+
+	var header struct {
+		Extends string                     `json:"extends"`
+		Levels  map[string]json.RawMessage `json:"levels"`
+	}
+	if err := json.Unmarshal(content, &header); err != nil {
+		return Theme{}, err
+	}
+	if header.Extends == "" {
+		header.Extends = ThemeLight
+	}
+
+	base, ok := themes[header.Extends]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme '%s' in extends", header.Extends)
+	}
+
+	// Copy the level colours so the base theme is left untouched
+	theme := base
+	theme.Name = ""
+	theme.Levels = make(map[string]LevelColors, len(base.Levels))
+	for level, colors := range base.Levels {
+		theme.Levels[level] = colors
+	}
+
+	if err := json.Unmarshal(content, &theme); err != nil {
+		return Theme{}, err
+	}
+
+	// A level only needs the colours it changes, its other colours come from the base theme
+	for level, raw := range header.Levels {
+		colors := base.Levels[level]
+		if err := json.Unmarshal(raw, &colors); err != nil {
+			return Theme{}, err
+		}
+		theme.Levels[level] = colors
+	}
+	if theme.Name == "" {
+		theme.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	return theme, nil
+}
+
+// Get returns the theme with the given name
+func (s ThemeSet) Get(name string) (Theme, bool) {
+	theme, ok := s[name]
+	return theme, ok
+}
+
+// Names returns the theme names, for error messages
+func (s ThemeSet) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,88 @@
+package controllertraits
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadThemes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// Extends dark, changes a single level colour and the title
+		"night.json": `{"extends": "dark", "title": "#ffffff", "levels": {"high": {"text": "#ff0000"}}}`,
+		// Named theme extending the default light theme
+		"kiosk.json": `{"name": "lobby", "background": "#fafafa"}`,
+		// Extends a theme loaded from the same directory, in glob order
+		"night2.json": `{"extends": "night", "subtitle": "#eeeeee"}`,
+		"broken.json": `{"extends": `,
+		"orphan.json": `{"extends": "sepia"}`,
+		"notes.txt":   `{"extends": "dark"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	themes, err := LoadThemes(dir)
+	if err != nil {
+		t.Fatalf("LoadThemes() error = %v", err)
+	}
+
+	defaults := DefaultThemes()
+	dark := defaults[ThemeDark]
+
+	night, ok := themes.Get("night")
+	if !ok {
+		t.Fatalf("LoadThemes() misses night, got %v", themes.Names())
+	}
+	if night.Title != "#ffffff" || night.Background != dark.Background {
+		t.Errorf("night title = %s, background = %s, want #ffffff, %s", night.Title, night.Background, dark.Background)
+	}
+	// Only the text of the high level changes, its other colours and the other levels come from dark
+	if high := night.Levels["high"]; high.Text != "#ff0000" || high.Background != dark.Levels["high"].Background || high.Border != dark.Levels["high"].Border {
+		t.Errorf("night high = %+v, want the dark colours with a #ff0000 text", high)
+	}
+	if night.Levels["low"] != dark.Levels["low"] {
+		t.Errorf("night low = %+v, want %+v", night.Levels["low"], dark.Levels["low"])
+	}
+
+	// The base theme is left untouched
+	if themes[ThemeDark].Levels["high"] != dark.Levels["high"] {
+		t.Errorf("dark high = %+v after loading night, want %+v", themes[ThemeDark].Levels["high"], dark.Levels["high"])
+	}
+
+	if lobby, ok := themes.Get("lobby"); !ok || lobby.Background != "#fafafa" || lobby.Title != defaults[ThemeLight].Title {
+		t.Errorf("lobby = %+v, %v, want the light theme with a #fafafa background", lobby, ok)
+	}
+	if _, ok := themes.Get("kiosk"); ok {
+		t.Error("LoadThemes() named a theme after its file despite its name")
+	}
+
+	if night2, ok := themes.Get("night2"); !ok || night2.Subtitle != "#eeeeee" || night2.Title != "#ffffff" {
+		t.Errorf("night2 = %+v, %v, want night with a #eeeeee subtitle", night2, ok)
+	}
+	if themes["night"].Levels["high"].Text != "#ff0000" {
+		t.Error("loading night2 changed the levels of night")
+	}
+
+	for _, skipped := range []string{"broken", "orphan", "notes"} {
+		if _, ok := themes.Get(skipped); ok {
+			t.Errorf("LoadThemes() loaded %s, want it skipped", skipped)
+		}
+	}
+	if len(themes) != len(defaults)+3 {
+		t.Errorf("LoadThemes() returned %v, want the built-in themes plus night, lobby and night2", themes.Names())
+	}
+}
+
+func TestLoadThemesWithoutDir(t *testing.T) {
+	themes, err := LoadThemes("")
+	if err != nil {
+		t.Fatalf("LoadThemes() error = %v", err)
+	}
+	if len(themes) != len(DefaultThemes()) {
+		t.Errorf("LoadThemes() returned %v, want the built-in themes", themes.Names())
+	}
+}
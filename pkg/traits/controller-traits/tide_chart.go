@@ -0,0 +1,205 @@
+package controllertraits
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/shadowbane/home-tidal-flood-warning/pkg/i18n"
+	basetraits "github.com/shadowbane/weather-alert/pkg/traits/controller-traits"
+)
+
+// Tide chart geometry, in SVG units
+const (
+	chartWidth  = 400
+	chartHeight = 180
+	plotLeft    = 36
+	plotRight   = 392
+	plotTop     = 10
+	plotBottom  = 156
+)
+
+// TideChartPoint is a point of the tide curve
+type TideChartPoint struct {
+	Time    time.Time
+	HeightM float64
+}
+
+// TideChartAlert is the period of an alert, shaded on the chart
+type TideChartAlert struct {
+	Event string
+	Start time.Time
+	End   time.Time
+}
+
+// TideChartData holds the data needed to render a tide chart
+type TideChartData struct {
+	Station  string
+	Location string
+	From     time.Time
+	To       time.Time
+	Now      time.Time
+	Points   []TideChartPoint
+	// ThresholdM is the flood threshold line, 0 hides it
+	ThresholdM float64
+	Alerts     []TideChartAlert
+	Timezone   string
+	Lang       string
+}
+
+// chartTick is an axis label with its position
+type chartTick struct {
+	Position float64
+	Label    string
+}
+
+// chartBand is a shaded alert period
+type chartBand struct {
+	X     float64
+	Width float64
+	Event string
+}
+
+// tideChartView is the data of the tide_chart template, coordinates are precomputed
+type tideChartView struct {
+	Theme      Theme
+	Lang       string
+	Colors     tideChartColors
+	Title      string
+	Subtitle   string
+	Width      int
+	Height     int
+	PlotLeft   int
+	PlotRight  int
+	PlotTop    int
+	PlotBottom int
+	PlotHeight int
+	Curve      string
+	Area       string
+	Threshold  *chartTick
+	NowX       float64
+	HasNow     bool
+	Bands      []chartBand
+	XTicks     []chartTick
+	YTicks     []chartTick
+}
+
+// tideChartColors are the chart colours taken from the theme levels
+type tideChartColors struct {
+	Curve     string
+	Fill      string
+	Threshold string
+	Alert     string
+}
+
+// RenderTideChart renders the tide curve as an HTML card with an inline SVG chart
+func RenderTideChart(data TideChartData, theme Theme) (string, error) {
+	view := tideChartView{
+		Theme: theme,
+		Lang:  data.Lang,
+		Colors: tideChartColors{
+			Curve:     theme.Levels["low"].Text,
+			Fill:      theme.Levels["low"].Border,
+			Threshold: theme.Levels["high"].Text,
+			Alert:     theme.Levels["moderate"].Border,
+		},
+		Title:      i18n.T(data.Lang, "chart.title", titleLocation(data.Station)),
+		Subtitle:   i18n.T(data.Lang, "chart.period", formatCardTime(data.From, data.Timezone), formatCardTime(data.To, data.Timezone)),
+		Width:      chartWidth,
+		Height:     chartHeight,
+		PlotLeft:   plotLeft,
+		PlotRight:  plotRight,
+		PlotTop:    plotTop,
+		PlotBottom: plotBottom,
+		PlotHeight: plotBottom - plotTop,
+	}
+	if data.Location != "" {
+		view.Title += " - " + titleLocation(data.Location)
+	}
+
+	if len(data.Points) > 0 {
+		addTideChartPlot(&view, data)
+	}
+
+	var b strings.Builder
+	if err := cardTemplates.ExecuteTemplate(&b, "tide_chart", view); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// addTideChartPlot computes the curve, threshold, alert bands and axes of the chart
+func addTideChartPlot(view *tideChartView, data TideChartData) {
+	low, high := data.Points[0].HeightM, data.Points[0].HeightM
+	for _, point := range data.Points {
+		low = math.Min(low, point.HeightM)
+		high = math.Max(high, point.HeightM)
+	}
+	if data.ThresholdM > 0 {
+		low = math.Min(low, data.ThresholdM)
+		high = math.Max(high, data.ThresholdM)
+	}
+	low = math.Floor(low*2)/2 - 0.25
+	high = math.Ceil(high*2)/2 + 0.25
+
+	span := data.To.Sub(data.From).Seconds()
+	x := func(t time.Time) float64 {
+		return plotLeft + (plotRight-plotLeft)*t.Sub(data.From).Seconds()/span
+	}
+	y := func(height float64) float64 {
+		return plotBottom - (plotBottom-plotTop)*(height-low)/(high-low)
+	}
+
+	var curve strings.Builder
+	for i, point := range data.Points {
+		command := "L"
+		if i == 0 {
+			command = "M"
+		}
+		fmt.Fprintf(&curve, "%s%.1f,%.1f ", command, x(point.Time), y(point.HeightM))
+	}
+	view.Curve = strings.TrimSpace(curve.String())
+	view.Area = fmt.Sprintf("%s L%.1f,%d L%.1f,%d Z", view.Curve,
+		x(data.Points[len(data.Points)-1].Time), plotBottom, x(data.Points[0].Time), plotBottom)
+
+	if data.ThresholdM > 0 {
+		view.Threshold = &chartTick{Position: y(data.ThresholdM), Label: i18n.T(data.Lang, "chart.flood", data.ThresholdM)}
+	}
+
+	if !data.Now.Before(data.From) && !data.Now.After(data.To) {
+		view.NowX, view.HasNow = x(data.Now), true
+	}
+
+	for _, alert := range data.Alerts {
+		start, end := alert.Start, alert.End
+		if start.Before(data.From) {
+			start = data.From
+		}
+		if end.After(data.To) {
+			end = data.To
+		}
+		if !end.After(start) {
+			continue
+		}
+		view.Bands = append(view.Bands, chartBand{X: x(start), Width: x(end) - x(start), Event: alert.Event})
+	}
+
+	// Hour labels every 6 hours in the display timezone, height labels every half meter
+	loc := basetraits.FormatTimeWithTimezone(data.From, data.Timezone).Location()
+	tick := data.From.In(loc).Truncate(time.Hour)
+	for tick.Hour()%6 != 0 || tick.Before(data.From) {
+		tick = tick.Add(time.Hour)
+	}
+	for ; !tick.After(data.To); tick = tick.Add(6 * time.Hour) {
+		view.XTicks = append(view.XTicks, chartTick{Position: x(tick), Label: tick.Format("15:04")})
+	}
+
+	step := 0.5
+	if high-low > 4 {
+		step = 1
+	}
+	for height := math.Ceil(low/step) * step; height <= high; height += step {
+		view.YTicks = append(view.YTicks, chartTick{Position: y(height), Label: fmt.Sprintf("%.1f", height)})
+	}
+}
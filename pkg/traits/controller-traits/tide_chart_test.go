@@ -0,0 +1,102 @@
+package controllertraits
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testChart is a 24 hour chart with a tide every hour and a flood threshold
+func testChart() TideChartData {
+	from := time.Date(2025, 12, 4, 0, 0, 0, 0, time.UTC)
+	data := TideChartData{
+		Station:    "Sekupang",
+		From:       from,
+		To:         from.Add(24 * time.Hour),
+		Now:        from.Add(6 * time.Hour),
+		ThresholdM: 2.8,
+		Timezone:   "UTC",
+		Lang:       "en",
+	}
+	for hour := 0; hour <= 24; hour++ {
+		data.Points = append(data.Points, TideChartPoint{Time: from.Add(time.Duration(hour) * time.Hour), HeightM: 1.6 + float64(hour%12)/10})
+	}
+	return data
+}
+
+func TestRenderTideChartThemes(t *testing.T) {
+	for name, theme := range DefaultThemes() {
+		t.Run(name, func(t *testing.T) {
+			html, err := RenderTideChart(testChart(), theme)
+			if err != nil {
+				t.Fatalf("RenderTideChart() error = %v", err)
+			}
+			if strings.Contains(html, "ZgotmplZ") {
+				t.Error("RenderTideChart() output contains ZgotmplZ, a theme value was rejected by the template")
+			}
+			if !strings.Contains(html, `d="M`) || !strings.Contains(html, theme.Title) {
+				t.Errorf("RenderTideChart() misses the curve or the %s title colour", name)
+			}
+		})
+	}
+}
+
+func TestRenderTideChartEmptyCurve(t *testing.T) {
+	data := testChart()
+	data.Points = nil
+
+	html, err := RenderTideChart(data, DefaultThemes()[ThemeLight])
+	if err != nil {
+		t.Fatalf("RenderTideChart() error = %v", err)
+	}
+	if strings.Contains(html, "ZgotmplZ") {
+		t.Error("RenderTideChart() output contains ZgotmplZ")
+	}
+	if strings.Contains(html, `d="M`) {
+		t.Error("RenderTideChart() drew a curve without points")
+	}
+}
+
+func TestTideChartAlertBands(t *testing.T) {
+	data := testChart()
+	hour := func(h float64) time.Time { return data.From.Add(time.Duration(h * float64(time.Hour))) }
+	x := func(h float64) float64 { return plotLeft + (plotRight-plotLeft)*h/24 }
+
+	tests := []struct {
+		name  string
+		alert TideChartAlert
+		want  *chartBand // nil when outside of the chart
+	}{
+		{"inside", TideChartAlert{Event: "inside", Start: hour(6), End: hour(12)}, &chartBand{X: x(6), Width: x(12) - x(6)}},
+		{"clipped start", TideChartAlert{Event: "start", Start: hour(-6), End: hour(3)}, &chartBand{X: x(0), Width: x(3) - x(0)}},
+		{"clipped end", TideChartAlert{Event: "end", Start: hour(20), End: hour(30)}, &chartBand{X: x(20), Width: x(24) - x(20)}},
+		{"spanning the chart", TideChartAlert{Event: "all", Start: hour(-1), End: hour(25)}, &chartBand{X: plotLeft, Width: plotRight - plotLeft}},
+		{"before", TideChartAlert{Event: "before", Start: hour(-6), End: hour(-1)}, nil},
+		{"after", TideChartAlert{Event: "after", Start: hour(25), End: hour(30)}, nil},
+		{"ending at the start", TideChartAlert{Event: "edge", Start: hour(-2), End: hour(0)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data.Alerts = []TideChartAlert{tt.alert}
+			view := tideChartView{}
+			addTideChartPlot(&view, data)
+
+			if tt.want == nil {
+				if len(view.Bands) != 0 {
+					t.Errorf("bands = %+v, want none", view.Bands)
+				}
+				return
+			}
+
+			if len(view.Bands) != 1 {
+				t.Fatalf("bands = %+v, want one", view.Bands)
+			}
+			band := view.Bands[0]
+			if fmt.Sprintf("%.3f %.3f", band.X, band.Width) != fmt.Sprintf("%.3f %.3f", tt.want.X, tt.want.Width) || band.Event != tt.alert.Event {
+				t.Errorf("band = %+v, want x %.1f width %.1f", band, tt.want.X, tt.want.Width)
+			}
+		})
+	}
+}